@@ -5,15 +5,22 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned"
 	"github.com/Azure/aad-pod-identity/pkg/metrics"
 	"github.com/Azure/aad-pod-identity/pkg/mic"
 	"github.com/Azure/aad-pod-identity/pkg/probes"
+	"github.com/Azure/aad-pod-identity/pkg/webhook"
 	"github.com/Azure/aad-pod-identity/version"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/klog"
 )
 
@@ -31,6 +38,12 @@ var (
 	clientQPS           float64
 	prometheusPort      string
 	immutableUserMSIs   string
+	dryRun              bool
+	gcConfig            mic.GarbageCollectionConfig
+	webhookPort         string
+	webhookCertFile     string
+	webhookKeyFile      string
+	podReadiness        bool
 )
 
 func main() {
@@ -50,6 +63,7 @@ func main() {
 	flag.StringVar(&leaderElectionCfg.Namespace, "leader-election-namespace", "default", "namespace to create leader election objects")
 	flag.StringVar(&leaderElectionCfg.Name, "leader-election-name", "aad-pod-identity-mic", "leader election name")
 	flag.DurationVar(&leaderElectionCfg.Duration, "leader-election-duration", time.Second*15, "leader election duration")
+	flag.StringVar(&leaderElectionCfg.LockType, "leader-election-lock-type", resourcelock.EndpointsResourceLock, "leader election lock type: endpoints, configmaps or leases")
 
 	//Probe port
 	flag.StringVar(&httpProbePort, "http-probe-port", "8080", "http liveliness probe port")
@@ -72,6 +86,23 @@ func main() {
 	//Identities that should be never removed from Azure AD (used defined managed identities)
 	flag.StringVar(&immutableUserMSIs, "immutable-user-msis", "", "prevent deletion of these IDs from the underlying VM/VMSS")
 
+	// Dry run logs the planned changes without updating the VM/VMSS identities or the assigned identities
+	flag.BoolVar(&dryRun, "dry-run", false, "Log the assigned identities and VM/VMSS identities each sync would change instead of changing them")
+
+	// Garbage collection removes the identities of pod identity left on the VM/VMSS without any assigned identity
+	flag.DurationVar(&gcConfig.Interval, "gc-interval", 0, "The interval at which the identities left on the VM/VMSS without any assigned identity are removed. 0 disables the garbage collection")
+	flag.Float64Var(&gcConfig.QPS, "gc-qps", 1, "The maximum number of VM/VMSS garbage collected per second")
+	flag.BoolVar(&gcConfig.ReportOnly, "gc-report-only", false, "Log the identities the garbage collection would remove instead of removing them")
+
+	// Validating webhook of the AzureIdentities, AzureIdentityBindings and AzurePodIdentityExceptions,
+	// and mutating webhook injecting the identities into the pods
+	flag.StringVar(&webhookPort, "webhook-port", "", "Port of the validating and mutating webhooks. The webhooks are disabled if not set")
+	flag.StringVar(&webhookCertFile, "webhook-cert-file", "", "Path to the TLS certificate of the webhooks")
+	flag.StringVar(&webhookKeyFile, "webhook-key-file", "", "Path to the TLS private key of the webhooks")
+
+	// Pod readiness sets a pod condition once the identities of the pod are assigned, for use as a readiness gate
+	flag.BoolVar(&podReadiness, "pod-readiness-condition", false, "Set the "+aadpodid.PodIdentityAssignedCondition+" condition of the pods once all their identities are assigned")
+
 	flag.Parse()
 	if versionInfo {
 		version.PrintVersionAndExit()
@@ -113,7 +144,7 @@ func main() {
 		immutableUserMSIsList = strings.Split(immutableUserMSIs, ",")
 	}
 
-	micClient, err := mic.NewMICClient(cloudconfig, config, forceNamespaced, syncRetryDuration, &leaderElectionCfg, enableScaleFeatures, createDeleteBatch, immutableUserMSIsList)
+	micClient, err := mic.NewMICClient(cloudconfig, config, forceNamespaced, syncRetryDuration, &leaderElectionCfg, enableScaleFeatures, createDeleteBatch, immutableUserMSIsList, dryRun, &gcConfig, podReadiness)
 	if err != nil {
 		klog.Fatalf("Could not get the MIC client: %+v", err)
 	}
@@ -123,13 +154,47 @@ func main() {
 	// and starts the sync loop.
 	probes.InitAndStart(httpProbePort, &micClient.SyncLoopStarted)
 
+	// The webhook is served by every MIC instance, not only the leader
+	if webhookPort != "" {
+		crdClientSet, err := versioned.NewForConfig(config)
+		if err != nil {
+			klog.Fatalf("Could not create the clientset of the webhook: %+v", err)
+		}
+		kubeClient, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			klog.Fatalf("Could not create the kubernetes client of the webhook: %+v", err)
+		}
+		// the readiness gate is only injected into the pods if mic sets its condition
+		server := webhook.NewServer(crdClientSet, kubeClient, forceNamespaced, podReadiness)
+		go func() {
+			klog.Fatalf("Webhook failed: %+v", server.ListenAndServeTLS(webhookPort, webhookCertFile, webhookKeyFile))
+		}()
+	}
+
 	// Register and expose metrics views
 	if err = metrics.RegisterAndExport(prometheusPort); err != nil {
 		klog.Fatalf("Could not register and export metrics: %+v", err)
 	}
 
-	// Starts the leader election loop
-	micClient.Run()
+	// On SIGTERM the sync loop is stopped and the leader election lock released, so
+	// another instance takes over without waiting for the lease to expire
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		klog.Infof("Received signal %s, shutting down", sig)
+		micClient.Shutdown()
+		klog.Flush()
+		os.Exit(0)
+	}()
+
+	// Starts the leader election loop, which returns once the leader lease is lost
+	if err := micClient.Run(); err != nil {
+		micClient.Shutdown()
+		klog.Errorf("MIC stopped: %v", err)
+		klog.Flush()
+		os.Exit(1)
+	}
 	klog.Info("AAD Pod identity controller initialized!!")
 	//Infinite loop :-)
 	select {}
@@ -24,6 +24,7 @@ const (
 	defaultlistPodIDsRetryAttemptsForCreated  = 16
 	defaultlistPodIDsRetryAttemptsForAssigned = 4
 	defaultlistPodIDsRetryIntervalInSeconds   = 5
+	defaultTokenCacheRefreshIntervalInSeconds = 60
 )
 
 var (
@@ -45,6 +46,8 @@ var (
 	enableScaleFeatures                = pflag.Bool("enableScaleFeatures", false, "Enable/Disable features for scale clusters")
 	blockInstanceMetadata              = pflag.Bool("block-instance-metadata", false, "Block instance metadata endpoints")
 	prometheusPort                     = pflag.String("prometheus-port", "9090", "Prometheus port for metrics")
+	enableTokenCache                   = pflag.Bool("enable-token-cache", true, "Enable/Disable caching of tokens acquired by NMI")
+	tokenCacheRefreshIntervalInSeconds = pflag.Int("token-cache-refresh-interval", defaultTokenCacheRefreshIntervalInSeconds, "Interval in seconds at which cached tokens close to expiry are refreshed")
 )
 
 func main() {
@@ -87,6 +90,8 @@ func main() {
 	s.ListPodIDsRetryAttemptsForCreated = *retryAttemptsForCreated
 	s.ListPodIDsRetryAttemptsForAssigned = *retryAttemptsForAssigned
 	s.ListPodIDsRetryIntervalInSeconds = *findIdentityRetryIntervalInSeconds
+	s.EnableTokenCache = *enableTokenCache
+	s.TokenCacheRefreshIntervalInSeconds = *tokenCacheRefreshIntervalInSeconds
 
 	// Health probe will always report success once its started. The contents
 	// will report "Active" once the iptables rules are set
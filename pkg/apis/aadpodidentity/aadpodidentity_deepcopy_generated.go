@@ -21,6 +21,7 @@ limitations under the License.
 package aadpodidentity
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -227,6 +228,16 @@ func (in *AzureIdentityBindingList) DeepCopyObject() runtime.Object {
 func (in *AzureIdentityBindingSpec) DeepCopyInto(out *AzureIdentityBindingSpec) {
 	*out = *in
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.LabelSelector != nil {
+		in, out := &in.LabelSelector, &out.LabelSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -244,6 +255,18 @@ func (in *AzureIdentityBindingSpec) DeepCopy() *AzureIdentityBindingSpec {
 func (in *AzureIdentityBindingStatus) DeepCopyInto(out *AzureIdentityBindingStatus) {
 	*out = *in
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]IdentityCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -295,6 +318,8 @@ func (in *AzureIdentitySpec) DeepCopyInto(out *AzureIdentitySpec) {
 	*out = *in
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.ClientPassword = in.ClientPassword
+	out.ClientCertificate = in.ClientCertificate
+	out.ClientCertificatePassword = in.ClientCertificatePassword
 	if in.Replicas != nil {
 		in, out := &in.Replicas, &out.Replicas
 		*out = new(int32)
@@ -317,6 +342,18 @@ func (in *AzureIdentitySpec) DeepCopy() *AzureIdentitySpec {
 func (in *AzureIdentityStatus) DeepCopyInto(out *AzureIdentityStatus) {
 	*out = *in
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]IdentityCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -431,3 +468,20 @@ func (in *AzurePodIdentityExceptionStatus) DeepCopy() *AzurePodIdentityException
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdentityCondition) DeepCopyInto(out *IdentityCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdentityCondition.
+func (in *IdentityCondition) DeepCopy() *IdentityCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(IdentityCondition)
+	in.DeepCopyInto(out)
+	return out
+}
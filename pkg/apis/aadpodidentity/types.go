@@ -3,6 +3,7 @@ package aadpodidentity
 import (
 	api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type EventType int
@@ -24,6 +25,13 @@ const (
 	CRDGroup    = "aadpodidentity.k8s.io"
 	CRDVersion  = "v1"
 	CRDLabelKey = "aadpodidbinding"
+	// BindingsAnnotationKey is the pod annotation listing comma-separated binding
+	// selectors, for pods matching more than one selector
+	BindingsAnnotationKey = "aadpodidentity.k8s.io/bindings"
+	// IdentityAnnotationKey is the pod annotation naming the AzureIdentity of the pod, as
+	// name or namespace/name, whose binding selector and environment variables are injected
+	// into the pod by the mutating webhook
+	IdentityAnnotationKey = "aadpodidentity.k8s.io/identity"
 
 	BehaviorKey = "aadpodidentity.k8s.io/Behavior"
 	// BehaviorNamespaced ...
@@ -34,6 +42,12 @@ const (
 	AssignedIDAssigned = "Assigned"
 	// AssignedIDUnAssigned status indicates identity has been unassigned from the node
 	AssignedIDUnAssigned = "Unassigned"
+	// AssignedIDFinalizer is the finalizer of assigned identities, removed by mic once
+	// the identity is removed from the node
+	AssignedIDFinalizer = "aadpodidentity.k8s.io/mic"
+	// PodIdentityAssignedCondition is the pod condition set by mic once all the identities
+	// of the pod are assigned to its node, to be used as a readiness gate of the pod
+	PodIdentityAssignedCondition = "aadpodidentity.k8s.io/identity-assigned"
 )
 
 /*** Global data structures ***/
@@ -67,7 +81,7 @@ type AzureAssignedIdentity struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec   AzureAssignedIdentitySpec   `json:"spec"`
-	Status AzureAssignedIdentityStatus `json:"Status"`
+	Status AzureAssignedIdentityStatus `json:"status"`
 }
 
 //AzurePodIdentityException contains the pod selectors for all pods that don't require
@@ -79,7 +93,7 @@ type AzurePodIdentityException struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	Spec   AzurePodIdentityExceptionSpec   `json:"spec"`
-	Status AzurePodIdentityExceptionStatus `json:"Status"`
+	Status AzurePodIdentityExceptionStatus `json:"status"`
 }
 
 /*** Lists ***/
@@ -122,21 +136,40 @@ type IdentityType int
 const (
 	UserAssignedMSI  IdentityType = 0
 	ServicePrincipal IdentityType = 1
+	// FederatedIdentity is an AAD application with a federated credential that
+	// trusts the service account tokens issued by the cluster
+	FederatedIdentity IdentityType = 2
 )
 
 type AzureIdentitySpec struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
-	// UserAssignedMSI or Service Principal
+	// UserAssignedMSI, Service Principal or Federated Identity
 	Type IdentityType `json:"type"`
 
 	// User assigned MSI resource id.
 	ResourceID string `json:"resourceid"`
-	//Both User Assigned MSI and SP can use this field.
+	//User Assigned MSI, SP and Federated Identity can use this field.
 	ClientID string `json:"clientid"`
+	// User assigned MSI object id.
+	ObjectID string `json:"objectid"`
 
 	//Used for service principal
 	ClientPassword api.SecretReference `json:"clientpassword"`
-	// Service principal tenant id.
+	// Key of the client secret in ClientPassword. Required if the secret
+	// contains more than one key.
+	ClientPasswordKey string `json:"clientpasswordkey"`
+	// Used for service principal with certificate credentials. The secret
+	// contains a PEM or PFX encoded certificate and private key.
+	ClientCertificate api.SecretReference `json:"clientcertificate"`
+	// Key of the certificate in ClientCertificate. Required if the secret
+	// contains more than one key and is not a kubernetes.io/tls secret.
+	ClientCertificateKey string `json:"clientcertificatekey"`
+	// Password of the PFX encoded certificate, if any.
+	ClientCertificatePassword api.SecretReference `json:"clientcertificatepassword"`
+	// Key of the password in ClientCertificatePassword. Required if the
+	// secret contains more than one key.
+	ClientCertificatePasswordKey string `json:"clientcertificatepasswordkey"`
+	// Service principal and federated identity tenant id.
 	TenantID string `json:"tenantid"`
 	// For service principal. Option param for specifying the  AD details.
 	ADResourceID string `json:"adresourceid"`
@@ -148,6 +181,35 @@ type AzureIdentitySpec struct {
 type AzureIdentityStatus struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	AvailableReplicas int32 `json:"availableReplicas"`
+	// Pods is the number of pods currently using the identity.
+	Pods int32 `json:"pods,omitempty"`
+	// Nodes are the nodes and VMSS the identity is assigned to.
+	Nodes []string `json:"nodes,omitempty"`
+	// LastError is the last error from Azure assigning the identity to or removing it from a node.
+	LastError string `json:"lastError,omitempty"`
+	// Conditions are the latest observations of the identity state.
+	Conditions []IdentityCondition `json:"conditions,omitempty"`
+}
+
+// IdentityConditionType is the type of a condition of an AzureIdentity or AzureIdentityBinding.
+type IdentityConditionType string
+
+const (
+	// IdentityReady is true when the identity is assigned to all the pods using it without errors.
+	IdentityReady IdentityConditionType = "Ready"
+	// IdentityAssigned is true when the identity is assigned to at least one pod.
+	IdentityAssigned IdentityConditionType = "Assigned"
+	// IdentityError is true when the last update of a node or VMSS with the identity failed.
+	IdentityError IdentityConditionType = "Error"
+)
+
+// IdentityCondition describes the state of an AzureIdentity or AzureIdentityBinding at a certain point.
+type IdentityCondition struct {
+	Type               IdentityConditionType `json:"type"`
+	Status             api.ConditionStatus   `json:"status"`
+	LastTransitionTime metav1.Time           `json:"lastTransitionTime,omitempty"`
+	Reason             string                `json:"reason,omitempty"`
+	Message            string                `json:"message,omitempty"`
 }
 
 /*** AzureIdentityBinding ***/
@@ -184,12 +246,29 @@ type AzureIdentityBindingSpec struct {
 	AzureIdentity     string `json:"azureidentity"`
 	Selector          string `json:"selector"`
 	// Weight is used to figure out which of the matching identities would be selected.
+	// Identities of bindings with a higher weight are selected first, ties are broken by identity name.
 	Weight int `json:"weight"`
+	// LabelSelector matches the pods by any of their labels. When Selector is also set,
+	// the pods need to match both. Selector is a shorthand for a LabelSelector matching
+	// the aadpodidbinding label.
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelselector,omitempty"`
+	// NamespaceSelector restricts the pods matched to the namespaces with matching labels.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceselector,omitempty"`
 }
 
 type AzureIdentityBindingStatus struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	AvailableReplicas int32 `json:"availableReplicas"`
+	// Pods is the number of pods currently matched by the binding.
+	Pods int32 `json:"pods,omitempty"`
+	// Nodes are the nodes and VMSS the identity of the binding is assigned to for its pods.
+	Nodes []string `json:"nodes,omitempty"`
+	// LastError is the last error from Azure assigning the identity of the binding to or removing it from a node.
+	LastError string `json:"lastError,omitempty"`
+	// Conditions are the latest observations of the binding state.
+	Conditions []IdentityCondition `json:"conditions,omitempty"`
 }
 
 /*** AzureAssignedIdentitySpec ***/
@@ -202,6 +281,9 @@ type AzureAssignedIdentitySpec struct {
 	Pod               string                `json:"pod"`
 	PodNamespace      string                `json:"podnamespace"`
 	NodeName          string                `json:"nodename"`
+	// PodUID is the uid of the pod, so an assigned identity is not used by
+	// another pod reusing the name of the pod.
+	PodUID types.UID `json:"poduid,omitempty"`
 
 	Replicas *int32 `json:"replicas"`
 }
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"encoding/json"
 	"reflect"
 
 	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
@@ -12,12 +13,21 @@ func ConvertV1BindingToInternalBinding(identityBinding AzureIdentityBinding) (re
 		TypeMeta:   identityBinding.TypeMeta,
 		ObjectMeta: identityBinding.ObjectMeta,
 		Spec: aadpodid.AzureIdentityBindingSpec{
-			ObjectMeta:    identityBinding.Spec.ObjectMeta,
-			AzureIdentity: identityBinding.Spec.AzureIdentity,
-			Selector:      identityBinding.Spec.Selector,
-			Weight:        identityBinding.Spec.Weight,
+			ObjectMeta:        identityBinding.Spec.ObjectMeta,
+			AzureIdentity:     identityBinding.Spec.AzureIdentity,
+			Selector:          identityBinding.Spec.Selector,
+			Weight:            identityBinding.Spec.Weight,
+			LabelSelector:     identityBinding.Spec.LabelSelector,
+			NamespaceSelector: identityBinding.Spec.NamespaceSelector,
+		},
+		Status: aadpodid.AzureIdentityBindingStatus{
+			ObjectMeta:        identityBinding.Status.ObjectMeta,
+			AvailableReplicas: identityBinding.Status.AvailableReplicas,
+			Pods:              identityBinding.Status.Pods,
+			Nodes:             identityBinding.Status.Nodes,
+			LastError:         identityBinding.Status.LastError,
+			Conditions:        convertV1ConditionsToInternalConditions(identityBinding.Status.Conditions),
 		},
-		Status: aadpodid.AzureIdentityBindingStatus(identityBinding.Status),
 	}
 }
 
@@ -26,17 +36,30 @@ func ConvertV1IdentityToInternalIdentity(identity AzureIdentity) (resIdentity aa
 		TypeMeta:   identity.TypeMeta,
 		ObjectMeta: identity.ObjectMeta,
 		Spec: aadpodid.AzureIdentitySpec{
-			ObjectMeta:     identity.Spec.ObjectMeta,
-			Type:           aadpodid.IdentityType(identity.Spec.Type),
-			ResourceID:     identity.Spec.ResourceID,
-			ClientID:       identity.Spec.ClientID,
-			ClientPassword: identity.Spec.ClientPassword,
-			TenantID:       identity.Spec.TenantID,
-			ADResourceID:   identity.Spec.ADResourceID,
-			ADEndpoint:     identity.Spec.ADEndpoint,
-			Replicas:       identity.Spec.Replicas,
+			ObjectMeta:                   identity.Spec.ObjectMeta,
+			Type:                         aadpodid.IdentityType(identity.Spec.Type),
+			ResourceID:                   identity.Spec.ResourceID,
+			ClientID:                     identity.Spec.ClientID,
+			ObjectID:                     identity.Spec.ObjectID,
+			ClientPassword:               identity.Spec.ClientPassword,
+			ClientPasswordKey:            identity.Spec.ClientPasswordKey,
+			ClientCertificate:            identity.Spec.ClientCertificate,
+			ClientCertificateKey:         identity.Spec.ClientCertificateKey,
+			ClientCertificatePassword:    identity.Spec.ClientCertificatePassword,
+			ClientCertificatePasswordKey: identity.Spec.ClientCertificatePasswordKey,
+			TenantID:                     identity.Spec.TenantID,
+			ADResourceID:                 identity.Spec.ADResourceID,
+			ADEndpoint:                   identity.Spec.ADEndpoint,
+			Replicas:                     identity.Spec.Replicas,
+		},
+		Status: aadpodid.AzureIdentityStatus{
+			ObjectMeta:        identity.Status.ObjectMeta,
+			AvailableReplicas: identity.Status.AvailableReplicas,
+			Pods:              identity.Status.Pods,
+			Nodes:             identity.Status.Nodes,
+			LastError:         identity.Status.LastError,
+			Conditions:        convertV1ConditionsToInternalConditions(identity.Status.Conditions),
 		},
-		Status: aadpodid.AzureIdentityStatus(identity.Status),
 	}
 }
 
@@ -54,6 +77,7 @@ func ConvertV1AssignedIdentityToInternalAssignedIdentity(assignedIdentity AzureA
 			Pod:              assignedIdentity.Spec.Pod,
 			PodNamespace:     assignedIdentity.Spec.PodNamespace,
 			NodeName:         assignedIdentity.Spec.NodeName,
+			PodUID:           assignedIdentity.Spec.PodUID,
 			Replicas:         assignedIdentity.Spec.Replicas,
 		},
 		Status: aadpodid.AzureAssignedIdentityStatus(assignedIdentity.Status),
@@ -72,17 +96,43 @@ func ConvertV1PodIdentityExceptionToInternalPodIdentityException(idException Azu
 	}
 }
 
+func convertV1ConditionsToInternalConditions(conditions []IdentityCondition) []aadpodid.IdentityCondition {
+	if conditions == nil {
+		return nil
+	}
+	out := make([]aadpodid.IdentityCondition, 0, len(conditions))
+	for _, condition := range conditions {
+		out = append(out, aadpodid.IdentityCondition{
+			Type:               aadpodid.IdentityConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	return out
+}
+
 func ConvertInternalBindingToV1Binding(identityBinding aadpodid.AzureIdentityBinding) (resIdentityBinding AzureIdentityBinding) {
 	out := AzureIdentityBinding{
 		TypeMeta:   identityBinding.TypeMeta,
 		ObjectMeta: identityBinding.ObjectMeta,
 		Spec: AzureIdentityBindingSpec{
-			ObjectMeta:    identityBinding.Spec.ObjectMeta,
-			AzureIdentity: identityBinding.Spec.AzureIdentity,
-			Selector:      identityBinding.Spec.Selector,
-			Weight:        identityBinding.Spec.Weight,
+			ObjectMeta:        identityBinding.Spec.ObjectMeta,
+			AzureIdentity:     identityBinding.Spec.AzureIdentity,
+			Selector:          identityBinding.Spec.Selector,
+			Weight:            identityBinding.Spec.Weight,
+			LabelSelector:     identityBinding.Spec.LabelSelector,
+			NamespaceSelector: identityBinding.Spec.NamespaceSelector,
+		},
+		Status: AzureIdentityBindingStatus{
+			ObjectMeta:        identityBinding.Status.ObjectMeta,
+			AvailableReplicas: identityBinding.Status.AvailableReplicas,
+			Pods:              identityBinding.Status.Pods,
+			Nodes:             identityBinding.Status.Nodes,
+			LastError:         identityBinding.Status.LastError,
+			Conditions:        convertInternalConditionsToV1Conditions(identityBinding.Status.Conditions),
 		},
-		Status: AzureIdentityBindingStatus(identityBinding.Status),
 	}
 
 	out.TypeMeta.SetGroupVersionKind(schema.GroupVersionKind{
@@ -98,17 +148,30 @@ func ConvertInternalIdentityToV1Identity(identity aadpodid.AzureIdentity) (resId
 		TypeMeta:   identity.TypeMeta,
 		ObjectMeta: identity.ObjectMeta,
 		Spec: AzureIdentitySpec{
-			ObjectMeta:     identity.Spec.ObjectMeta,
-			Type:           IdentityType(identity.Spec.Type),
-			ResourceID:     identity.Spec.ResourceID,
-			ClientID:       identity.Spec.ClientID,
-			ClientPassword: identity.Spec.ClientPassword,
-			TenantID:       identity.Spec.TenantID,
-			ADResourceID:   identity.Spec.ADResourceID,
-			ADEndpoint:     identity.Spec.ADEndpoint,
-			Replicas:       identity.Spec.Replicas,
+			ObjectMeta:                   identity.Spec.ObjectMeta,
+			Type:                         IdentityType(identity.Spec.Type),
+			ResourceID:                   identity.Spec.ResourceID,
+			ClientID:                     identity.Spec.ClientID,
+			ObjectID:                     identity.Spec.ObjectID,
+			ClientPassword:               identity.Spec.ClientPassword,
+			ClientPasswordKey:            identity.Spec.ClientPasswordKey,
+			ClientCertificate:            identity.Spec.ClientCertificate,
+			ClientCertificateKey:         identity.Spec.ClientCertificateKey,
+			ClientCertificatePassword:    identity.Spec.ClientCertificatePassword,
+			ClientCertificatePasswordKey: identity.Spec.ClientCertificatePasswordKey,
+			TenantID:                     identity.Spec.TenantID,
+			ADResourceID:                 identity.Spec.ADResourceID,
+			ADEndpoint:                   identity.Spec.ADEndpoint,
+			Replicas:                     identity.Spec.Replicas,
+		},
+		Status: AzureIdentityStatus{
+			ObjectMeta:        identity.Status.ObjectMeta,
+			AvailableReplicas: identity.Status.AvailableReplicas,
+			Pods:              identity.Status.Pods,
+			Nodes:             identity.Status.Nodes,
+			LastError:         identity.Status.LastError,
+			Conditions:        convertInternalConditionsToV1Conditions(identity.Status.Conditions),
 		},
-		Status: AzureIdentityStatus(identity.Status),
 	}
 
 	out.TypeMeta.SetGroupVersionKind(schema.GroupVersionKind{
@@ -133,6 +196,7 @@ func ConvertInternalAssignedIdentityToV1AssignedIdentity(assignedIdentity aadpod
 			Pod:              assignedIdentity.Spec.Pod,
 			PodNamespace:     assignedIdentity.Spec.PodNamespace,
 			NodeName:         assignedIdentity.Spec.NodeName,
+			PodUID:           assignedIdentity.Spec.PodUID,
 			Replicas:         assignedIdentity.Spec.Replicas,
 		},
 		Status: AzureAssignedIdentityStatus(assignedIdentity.Status),
@@ -146,4 +210,67 @@ func ConvertInternalAssignedIdentityToV1AssignedIdentity(assignedIdentity aadpod
 	return out
 }
 
+func convertInternalConditionsToV1Conditions(conditions []aadpodid.IdentityCondition) []IdentityCondition {
+	if conditions == nil {
+		return nil
+	}
+	out := make([]IdentityCondition, 0, len(conditions))
+	for _, condition := range conditions {
+		out = append(out, IdentityCondition{
+			Type:               IdentityConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	return out
+}
+
 // ConvertInternalPodIdentityExceptionToV1PodIdentityException is currently not needed, as AzurePodIdentityException are only listed and not created within the project
+
+// UnmarshalJSON decodes the assigned identity. Objects created before the status subresource was
+// introduced keep their status in the capitalised Status field, which is read when status is not set.
+func (a *AzureAssignedIdentity) UnmarshalJSON(data []byte) error {
+	type assignedIdentity AzureAssignedIdentity
+	aux := struct {
+		*assignedIdentity
+		Status       *AzureAssignedIdentityStatus `json:"status"`
+		LegacyStatus *AzureAssignedIdentityStatus `json:"Status"`
+	}{assignedIdentity: (*assignedIdentity)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	switch {
+	case aux.Status != nil:
+		a.Status = *aux.Status
+	case aux.LegacyStatus != nil:
+		a.Status = *aux.LegacyStatus
+	default:
+		a.Status = AzureAssignedIdentityStatus{}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the pod identity exception, reading the legacy Status field like
+// AzureAssignedIdentity does.
+func (e *AzurePodIdentityException) UnmarshalJSON(data []byte) error {
+	type podIdentityException AzurePodIdentityException
+	aux := struct {
+		*podIdentityException
+		Status       *AzurePodIdentityExceptionStatus `json:"status"`
+		LegacyStatus *AzurePodIdentityExceptionStatus `json:"Status"`
+	}{podIdentityException: (*podIdentityException)(e)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	switch {
+	case aux.Status != nil:
+		e.Status = *aux.Status
+	case aux.LegacyStatus != nil:
+		e.Status = *aux.LegacyStatus
+	default:
+		e.Status = AzurePodIdentityExceptionStatus{}
+	}
+	return nil
+}
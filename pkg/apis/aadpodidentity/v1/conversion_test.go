@@ -1,10 +1,13 @@
 package v1
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
 	"github.com/google/go-cmp/cmp"
+	api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -14,10 +17,14 @@ var selectorName string = "selectorName"
 var idTypeInternal aadpodid.IdentityType = aadpodid.UserAssignedMSI
 var idTypeV1 IdentityType = UserAssignedMSI
 var rID string = "resourceId"
+var oID string = "objectId"
 var assignedIDPod string = "assignedIDPod"
 var replicas int32 = 3
 var weight int = 1
 var podLabels = map[string]string{"testkey1": "testval1", "testkey2": "testval2"}
+var statusNodes = []string{"node0", "vmss0"}
+var lastError string = "lastError"
+var transitionTime = metav1.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
 
 func CreateV1Binding() (retV1Binding AzureIdentityBinding) {
 	return AzureIdentityBinding{
@@ -29,12 +36,26 @@ func CreateV1Binding() (retV1Binding AzureIdentityBinding) {
 			APIVersion: "aadpodidentity.k8s.io/v1",
 		},
 		Spec: AzureIdentityBindingSpec{
-			AzureIdentity: identityName,
-			Selector:      selectorName,
-			Weight:        weight,
+			AzureIdentity:     identityName,
+			Selector:          selectorName,
+			Weight:            weight,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: podLabels},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: podLabels},
 		},
 		Status: AzureIdentityBindingStatus{
 			AvailableReplicas: replicas,
+			Pods:              replicas,
+			Nodes:             statusNodes,
+			LastError:         lastError,
+			Conditions: []IdentityCondition{
+				{
+					Type:               IdentityReady,
+					Status:             api.ConditionFalse,
+					LastTransitionTime: transitionTime,
+					Reason:             "CloudProviderError",
+					Message:            lastError,
+				},
+			},
 		},
 	}
 }
@@ -51,10 +72,23 @@ func CreateV1Identity() (retV1Identity AzureIdentity) {
 		Spec: AzureIdentitySpec{
 			Type:       idTypeV1,
 			ResourceID: rID,
+			ObjectID:   oID,
 			Replicas:   &replicas,
 		},
 		Status: AzureIdentityStatus{
 			AvailableReplicas: replicas,
+			Pods:              replicas,
+			Nodes:             statusNodes,
+			LastError:         lastError,
+			Conditions: []IdentityCondition{
+				{
+					Type:               IdentityReady,
+					Status:             api.ConditionFalse,
+					LastTransitionTime: transitionTime,
+					Reason:             "CloudProviderError",
+					Message:            lastError,
+				},
+			},
 		},
 	}
 }
@@ -93,12 +127,26 @@ func CreateInternalBinding() (retV1Binding aadpodid.AzureIdentityBinding) {
 			APIVersion: "aadpodidentity.k8s.io/v1",
 		},
 		Spec: aadpodid.AzureIdentityBindingSpec{
-			AzureIdentity: identityName,
-			Selector:      selectorName,
-			Weight:        weight,
+			AzureIdentity:     identityName,
+			Selector:          selectorName,
+			Weight:            weight,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: podLabels},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: podLabels},
 		},
 		Status: aadpodid.AzureIdentityBindingStatus{
 			AvailableReplicas: replicas,
+			Pods:              replicas,
+			Nodes:             statusNodes,
+			LastError:         lastError,
+			Conditions: []aadpodid.IdentityCondition{
+				{
+					Type:               aadpodid.IdentityReady,
+					Status:             api.ConditionFalse,
+					LastTransitionTime: transitionTime,
+					Reason:             "CloudProviderError",
+					Message:            lastError,
+				},
+			},
 		},
 	}
 }
@@ -115,10 +163,23 @@ func CreateInternalIdentity() (retInternalIdentity aadpodid.AzureIdentity) {
 		Spec: aadpodid.AzureIdentitySpec{
 			Type:       idTypeInternal,
 			ResourceID: rID,
+			ObjectID:   oID,
 			Replicas:   &replicas,
 		},
 		Status: aadpodid.AzureIdentityStatus{
 			AvailableReplicas: replicas,
+			Pods:              replicas,
+			Nodes:             statusNodes,
+			LastError:         lastError,
+			Conditions: []aadpodid.IdentityCondition{
+				{
+					Type:               aadpodid.IdentityReady,
+					Status:             api.ConditionFalse,
+					LastTransitionTime: transitionTime,
+					Reason:             "CloudProviderError",
+					Message:            lastError,
+				},
+			},
 		},
 	}
 }
@@ -243,3 +304,51 @@ func TestConvertV1PodIdentityExceptionToInternalPodIdentityException(t *testing.
 		t.Errorf("Failed to convert from v1 to internal AzureAssignedIdentity")
 	}
 }
+
+func TestUnmarshalAssignedIdentityStatus(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		data   string
+		expect string
+	}{
+		{"status", `{"metadata":{"name":"a"},"status":{"status":"Assigned"}}`, "Assigned"},
+		{"legacy status", `{"metadata":{"name":"a"},"Status":{"status":"Created"}}`, "Created"},
+		{"status preferred over legacy status", `{"metadata":{"name":"a"},"Status":{"status":"Created"},"status":{"status":"Assigned"}}`, "Assigned"},
+		{"no status", `{"metadata":{"name":"a"}}`, ""},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			assignedID := AzureAssignedIdentity{Status: AzureAssignedIdentityStatus{Status: "stale"}}
+			if err := json.Unmarshal([]byte(tc.data), &assignedID); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if assignedID.Name != "a" {
+				t.Errorf("expected name a, got %q", assignedID.Name)
+			}
+			if assignedID.Status.Status != tc.expect {
+				t.Errorf("expected status %q, got %q", tc.expect, assignedID.Status.Status)
+			}
+		})
+	}
+
+	data, err := json.Marshal(CreateV1AssignedIdentity())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var roundTrip AzureAssignedIdentity
+	if err := json.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !cmp.Equal(CreateV1AssignedIdentity(), roundTrip) {
+		t.Errorf("AzureAssignedIdentity changed in a json round trip: %s", cmp.Diff(CreateV1AssignedIdentity(), roundTrip))
+	}
+}
+
+func TestUnmarshalPodIdentityExceptionLegacyStatus(t *testing.T) {
+	var exception AzurePodIdentityException
+	if err := json.Unmarshal([]byte(`{"spec":{"podLabels":{"app":"a"}},"Status":{"status":"ok"}}`), &exception); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if exception.Status.Status != "ok" || exception.Spec.PodLabels["app"] != "a" {
+		t.Errorf("unexpected pod identity exception %+v", exception)
+	}
+}
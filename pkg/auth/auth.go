@@ -1,16 +1,25 @@
 package auth
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/Azure/aad-pod-identity/pkg/metrics"
 	"github.com/Azure/aad-pod-identity/version"
 	adal "github.com/Azure/go-autorest/autorest/adal"
+	"golang.org/x/crypto/pkcs12"
 )
 
 const (
 	activeDirectoryEndpoint = "https://login.microsoftonline.com/"
+	// clientAssertionType is the type of the client assertion used to exchange
+	// a service account token for an AAD token
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
 )
 
 var reporter *metrics.Reporter
@@ -82,6 +91,66 @@ func GetServicePrincipalTokenFromMSIWithUserAssignedID(clientID, resource string
 	return &token, nil
 }
 
+// GetServicePrincipalTokenFromMSIWithObjectID return the token for the user assigned identity with the object id
+func GetServicePrincipalTokenFromMSIWithObjectID(objectID, resource string) (*adal.Token, error) {
+	return getServicePrincipalTokenFromMSIWithQueryParam("object_id", objectID, resource)
+}
+
+// GetServicePrincipalTokenFromMSIWithResourceID return the token for the user assigned identity with the resource id
+func GetServicePrincipalTokenFromMSIWithResourceID(resourceID, resource string) (*adal.Token, error) {
+	return getServicePrincipalTokenFromMSIWithQueryParam("msi_res_id", resourceID, resource)
+}
+
+// getServicePrincipalTokenFromMSIWithQueryParam returns the token for the user assigned
+// identity selected by the query parameter. adal only supports selecting the identity
+// by client id, so the parameter is added to the requests sent to IMDS.
+func getServicePrincipalTokenFromMSIWithQueryParam(param, value, resource string) (*adal.Token, error) {
+	begin := time.Now()
+	var err error
+
+	defer func() {
+		if err != nil {
+			reporter.ReportIMDSOperationError(metrics.AdalTokenFromMSIWithUserAssignedIDOperationName)
+			return
+		}
+		reporter.ReportIMDSOperationDuration(metrics.AdalTokenFromMSIWithUserAssignedIDOperationName, time.Since(begin))
+	}()
+
+	if value == "" {
+		err = fmt.Errorf("parameter %s cannot be empty", param)
+		return nil, err
+	}
+	// Get the MSI endpoint accoriding with the OS (Linux/Windows)
+	msiEndpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the MSI endpoint. Error: %v", err)
+	}
+	// Set up the configuration of the service principal
+	spt, err := adal.NewServicePrincipalTokenFromMSI(msiEndpoint, resource)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to acquire a token using the MSI VM extension. Error: %v", err)
+	}
+	spt.SetSender(newQueryParamSender(http.DefaultClient, param, value))
+
+	// obtain a fresh token
+	err = spt.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	token := spt.Token()
+	return &token, nil
+}
+
+// newQueryParamSender returns a sender that sets the query parameter on every request
+func newQueryParamSender(sender adal.Sender, param, value string) adal.Sender {
+	return adal.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		q.Set(param, value)
+		r.URL.RawQuery = q.Encode()
+		return sender.Do(r)
+	})
+}
+
 // GetServicePrincipalToken return the token for the assigned user
 func GetServicePrincipalToken(tenantID, clientID, secret, resource string) (*adal.Token, error) {
 	begin := time.Now()
@@ -112,6 +181,161 @@ func GetServicePrincipalToken(tenantID, clientID, secret, resource string) (*ada
 	return &token, nil
 }
 
+// GetServicePrincipalTokenWithCertificate return the token for the assigned user with the certificate
+func GetServicePrincipalTokenWithCertificate(tenantID, clientID string, certificate []byte, password, resource string) (*adal.Token, error) {
+	begin := time.Now()
+	var err error
+
+	defer func() {
+		if err != nil {
+			reporter.ReportIMDSOperationError(metrics.AdalTokenOperationName)
+			return
+		}
+		reporter.ReportIMDSOperationDuration(metrics.AdalTokenOperationName, time.Since(begin))
+	}()
+
+	cert, privateKey, err := ParseCertificate(certificate, password)
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateCertificate(cert, time.Now()); err != nil {
+		return nil, err
+	}
+	oauthConfig, err := adal.NewOAuthConfig(activeDirectoryEndpoint, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("creating the OAuth config: %v", err)
+	}
+	spt, err := adal.NewServicePrincipalTokenFromCertificate(*oauthConfig, clientID, cert, privateKey, resource)
+	if err != nil {
+		return nil, err
+	}
+	// obtain a fresh token
+	err = spt.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	token := spt.Token()
+	return &token, nil
+}
+
+// GetServicePrincipalTokenWithClientAssertion return the token for the application
+// with a federated credential by exchanging the client assertion
+func GetServicePrincipalTokenWithClientAssertion(tenantID, clientID, assertion, resource string) (*adal.Token, error) {
+	begin := time.Now()
+	var err error
+
+	defer func() {
+		if err != nil {
+			reporter.ReportIMDSOperationError(metrics.AdalTokenWithClientAssertionOperationName)
+			return
+		}
+		reporter.ReportIMDSOperationDuration(metrics.AdalTokenWithClientAssertionOperationName, time.Since(begin))
+	}()
+
+	if assertion == "" {
+		err = fmt.Errorf("client assertion cannot be empty")
+		return nil, err
+	}
+	oauthConfig, err := adal.NewOAuthConfig(activeDirectoryEndpoint, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("creating the OAuth config: %v", err)
+	}
+	spt, err := adal.NewServicePrincipalTokenWithSecret(*oauthConfig, clientID, resource, &clientAssertionSecret{assertion: assertion})
+	if err != nil {
+		return nil, err
+	}
+	// obtain a fresh token
+	err = spt.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	token := spt.Token()
+	return &token, nil
+}
+
+// clientAssertionSecret authenticates the token request with a client assertion
+type clientAssertionSecret struct {
+	assertion string
+}
+
+// SetAuthenticationValues sets the client assertion in the token request
+func (s *clientAssertionSecret) SetAuthenticationValues(spt *adal.ServicePrincipalToken, v *url.Values) error {
+	v.Set("client_assertion_type", clientAssertionType)
+	v.Set("client_assertion", s.assertion)
+	return nil
+}
+
+// ParseCertificate returns the certificate and the RSA private key from the PEM
+// or PFX encoded data. The password is only used to decode PFX data.
+func ParseCertificate(data []byte, password string) (*x509.Certificate, *rsa.PrivateKey, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("certificate data is empty")
+	}
+
+	var cert *x509.Certificate
+	var privateKey interface{}
+	var err error
+
+	if block, _ := pem.Decode(data); block != nil {
+		cert, privateKey, err = parsePEMCertificate(data)
+	} else {
+		privateKey, cert, err = pkcs12.Decode(data, password)
+		if err != nil {
+			err = fmt.Errorf("failed to decode pfx certificate: %v", err)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("certificate private key is not an RSA key")
+	}
+	return cert, rsaPrivateKey, nil
+}
+
+// parsePEMCertificate returns the first certificate and the private key in the PEM data
+func parsePEMCertificate(data []byte) (cert *x509.Certificate, privateKey interface{}, err error) {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if cert != nil {
+				continue
+			}
+			if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse pem certificate: %v", err)
+			}
+		case "RSA PRIVATE KEY":
+			if privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse pem private key: %v", err)
+			}
+		case "PRIVATE KEY":
+			if privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse pem private key: %v", err)
+			}
+		}
+	}
+	if cert == nil {
+		return nil, nil, fmt.Errorf("no certificate found in pem data")
+	}
+	if privateKey == nil {
+		return nil, nil, fmt.Errorf("no private key found in pem data")
+	}
+	return cert, privateKey, nil
+}
+
+// ValidateCertificate returns an error if the certificate is not valid at the given time
+func ValidateCertificate(cert *x509.Certificate, now time.Time) error {
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("certificate %s is not valid before %s", cert.Subject.CommonName, cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %s expired on %s", cert.Subject.CommonName, cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
 func init() {
 	err := adal.AddToUserAgent(version.GetUserAgent("NMI", version.NMIVersion))
 	if err != nil {
@@ -1,9 +1,19 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/Azure/aad-pod-identity/pkg/metrics"
+	adal "github.com/Azure/go-autorest/autorest/adal"
 )
 
 func TestGetServicePrincipalToken(t *testing.T) {
@@ -17,3 +27,119 @@ func TestGetServicePrincipalToken(t *testing.T) {
 		t.Fatal("should be error with empty secret")
 	}
 }
+
+func TestGetServicePrincipalTokenFromMSIWithEmptyID(t *testing.T) {
+	reporter, err := metrics.NewReporter()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	InitReporter(reporter)
+	if _, err = GetServicePrincipalTokenFromMSIWithObjectID("", "https://management.azure.com/"); err == nil {
+		t.Fatal("should be error with empty object id")
+	}
+	if _, err = GetServicePrincipalTokenFromMSIWithResourceID("", "https://management.azure.com/"); err == nil {
+		t.Fatal("should be error with empty resource id")
+	}
+}
+
+func TestQueryParamSender(t *testing.T) {
+	var rawQuery string
+	sender := newQueryParamSender(adal.SenderFunc(func(r *http.Request) (*http.Response, error) {
+		rawQuery = r.URL.RawQuery
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}), "object_id", "oid")
+
+	req, err := http.NewRequest(http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fvault.azure.net", nil)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if _, err = sender.Do(req); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	expected := "api-version=2018-02-01&object_id=oid&resource=https%3A%2F%2Fvault.azure.net"
+	if rawQuery != expected {
+		t.Fatalf("expected query %s, got %s", expected, rawQuery)
+	}
+}
+
+func newTestCertificate(t *testing.T, notBefore, notAfter time.Time) []byte {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(data, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})...)
+}
+
+func TestParseCertificate(t *testing.T) {
+	now := time.Now()
+	data := newTestCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+
+	cert, privateKey, err := ParseCertificate(data, "")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if cert.Subject.CommonName != "test" || privateKey == nil {
+		t.Fatalf("unexpected certificate %s", cert.Subject.CommonName)
+	}
+
+	if _, _, err = ParseCertificate(nil, ""); err == nil {
+		t.Fatal("should be error with empty certificate")
+	}
+	if _, _, err = ParseCertificate([]byte("not a certificate"), ""); err == nil {
+		t.Fatal("should be error with invalid pfx certificate")
+	}
+	if _, _, err = ParseCertificate(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), ""); err == nil {
+		t.Fatal("should be error without private key")
+	}
+}
+
+func TestValidateCertificate(t *testing.T) {
+	now := time.Now()
+	cert, _, err := ParseCertificate(newTestCertificate(t, now.Add(-time.Hour), now.Add(time.Hour)), "")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+
+	if err = ValidateCertificate(cert, now); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if err = ValidateCertificate(cert, now.Add(2*time.Hour)); err == nil {
+		t.Fatal("should be error with expired certificate")
+	}
+	if err = ValidateCertificate(cert, now.Add(-2*time.Hour)); err == nil {
+		t.Fatal("should be error with certificate that is not yet valid")
+	}
+}
+
+func TestGetServicePrincipalTokenWithEmptyClientAssertion(t *testing.T) {
+	reporter, err := metrics.NewReporter()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	InitReporter(reporter)
+	if _, err = GetServicePrincipalTokenWithClientAssertion("tid", "cid", "", "https://management.azure.com/"); err == nil {
+		t.Fatal("should be error with empty client assertion")
+	}
+}
+
+func TestClientAssertionSecret(t *testing.T) {
+	v := url.Values{}
+	secret := &clientAssertionSecret{assertion: "assertion"}
+	if err := secret.SetAuthenticationValues(nil, &v); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if v.Get("client_assertion_type") != clientAssertionType || v.Get("client_assertion") != "assertion" {
+		t.Fatalf("unexpected authentication values %v", v)
+	}
+}
@@ -21,6 +21,10 @@ import (
 	"k8s.io/klog"
 )
 
+// maxConflictRetries is the number of times an identity update is retried when the vm or vmss
+// was changed concurrently.
+const maxConflictRetries = 3
+
 // Client is a cloud provider client
 type Client struct {
 	VMClient   VMClientInt
@@ -161,7 +165,8 @@ func (c *Client) GetUserMSIs(name string, isvmss bool) ([]string, error) {
 	}
 	info := idH.IdentityInfo()
 	if info == nil {
-		return nil, fmt.Errorf("identity info is nil")
+		// the node or vmss has no identity, so no user assigned identities
+		return nil, nil
 	}
 	idList := info.GetUserIdentityList()
 	return idList, nil
@@ -169,66 +174,49 @@ func (c *Client) GetUserMSIs(name string, isvmss bool) ([]string, error) {
 
 // UpdateUserMSI will batch process the removal and addition of ids
 func (c *Client) UpdateUserMSI(addUserAssignedMSIIDs, removeUserAssignedMSIIDs []string, name string, isvmss bool) error {
-	idH, updateFunc, err := c.getIdentityResource(name, isvmss)
-	if err != nil {
-		return err
-	}
-
-	info := idH.IdentityInfo()
-	if info == nil {
-		info = idH.ResetIdentity()
-	}
+	return c.updateIdentity(name, isvmss, func(idH IdentityHolder) (bool, error) {
+		info := idH.IdentityInfo()
+		if info == nil {
+			info = idH.ResetIdentity()
+		}
 
-	requiresUpdate := false
-	// remove msi ids from the list
-	for _, userAssignedMSIID := range removeUserAssignedMSIIDs {
-		requiresUpdate = true
-		if err := info.RemoveUserIdentity(userAssignedMSIID); err != nil {
-			return fmt.Errorf("could not remove identity from node %s: %v", name, err)
+		requiresUpdate := false
+		// remove msi ids from the list
+		for _, userAssignedMSIID := range removeUserAssignedMSIIDs {
+			requiresUpdate = true
+			if err := info.RemoveUserIdentity(userAssignedMSIID); err != nil {
+				return false, fmt.Errorf("could not remove identity from node %s: %v", name, err)
+			}
 		}
-	}
-	// add new ids to the list
-	for _, userAssignedMSIID := range addUserAssignedMSIIDs {
-		addedToList := info.AppendUserIdentity(userAssignedMSIID)
-		if !addedToList {
-			klog.V(6).Infof("Identity %s already assigned to node %s. Skipping assignment.", userAssignedMSIID, name)
+		// add new ids to the list
+		for _, userAssignedMSIID := range addUserAssignedMSIIDs {
+			addedToList := info.AppendUserIdentity(userAssignedMSIID)
+			if !addedToList {
+				klog.V(6).Infof("Identity %s already assigned to node %s. Skipping assignment.", userAssignedMSIID, name)
+			}
+			requiresUpdate = requiresUpdate || addedToList
 		}
-		requiresUpdate = requiresUpdate || addedToList
-	}
-	if requiresUpdate {
-		klog.Infof("Updating user assigned MSIs on %s", name)
-		timeStarted := time.Now()
-		if err := updateFunc(); err != nil {
-			return err
+		if requiresUpdate {
+			klog.Infof("Updating user assigned MSIs on %s", name)
 		}
-		klog.V(6).Infof("UpdateUserMSI of %s completed in %s", name, time.Since(timeStarted))
-	}
-	return nil
+		return requiresUpdate, nil
+	})
 }
 
 //RemoveUserMSI - Use the underlying cloud api calls and remove the given user assigned MSI from the vm.
 func (c *Client) RemoveUserMSI(userAssignedMSIID, name string, isvmss bool) error {
-	idH, updateFunc, err := c.getIdentityResource(name, isvmss)
-	if err != nil {
-		return err
-	}
-
-	info := idH.IdentityInfo()
-	if info == nil {
-		klog.Errorf("Identity null for vm: %s ", name)
-		return fmt.Errorf("identity null for vm: %s ", name)
-	}
-
-	if err := info.RemoveUserIdentity(userAssignedMSIID); err != nil {
-		return fmt.Errorf("could not remove identity from node %s: %v", name, err)
-	}
-
-	if err := updateFunc(); err != nil {
-		klog.Error(err)
-		return err
-	}
+	return c.updateIdentity(name, isvmss, func(idH IdentityHolder) (bool, error) {
+		info := idH.IdentityInfo()
+		if info == nil {
+			klog.Errorf("Identity null for vm: %s ", name)
+			return false, fmt.Errorf("identity null for vm: %s ", name)
+		}
 
-	return nil
+		if err := info.RemoveUserIdentity(userAssignedMSIID); err != nil {
+			return false, fmt.Errorf("could not remove identity from node %s: %v", name, err)
+		}
+		return true, nil
+	})
 }
 
 // AssignUserMSI - Use the underlying cloud api call and add the given user assigned MSI to the vm
@@ -237,29 +225,48 @@ func (c *Client) AssignUserMSI(userAssignedMSIID, name string, isvmss bool) erro
 	// Update the assigned identity into the VM using the CreateOrUpdate
 
 	klog.Infof("Find %s in resource group: %s", name, c.Config.ResourceGroupName)
-	timeStarted := time.Now()
+	return c.updateIdentity(name, isvmss, func(idH IdentityHolder) (bool, error) {
+		info := idH.IdentityInfo()
+		if info == nil {
+			info = idH.ResetIdentity()
+		}
 
-	idH, updateFunc, err := c.getIdentityResource(name, isvmss)
-	if err != nil {
-		return err
-	}
-	klog.V(6).Infof("Get of %s completed in %s", name, time.Since(timeStarted))
+		if !info.AppendUserIdentity(userAssignedMSIID) {
+			klog.V(6).Infof("Identity %s already assigned to node %s. Skipping assignment.", userAssignedMSIID, name)
+			return false, nil
+		}
+		return true, nil
+	})
+}
 
-	info := idH.IdentityInfo()
-	if info == nil {
-		info = idH.ResetIdentity()
-	}
+// updateIdentity reads the identity of the vm or vmss, applies the change to it and writes it back if
+// apply reports that it requires an update. The write is conditional on the ETag of the read, so when the
+// resource was changed concurrently it is read again and the change is reapplied, up to maxConflictRetries times.
+func (c *Client) updateIdentity(name string, isvmss bool, apply func(idH IdentityHolder) (bool, error)) error {
+	for attempt := 0; ; attempt++ {
+		timeStarted := time.Now()
+		idH, updateFunc, err := c.getIdentityResource(name, isvmss)
+		if err != nil {
+			return err
+		}
+		klog.V(6).Infof("Get of %s completed in %s", name, time.Since(timeStarted))
+
+		requiresUpdate, err := apply(idH)
+		if err != nil || !requiresUpdate {
+			return err
+		}
 
-	if info.AppendUserIdentity(userAssignedMSIID) {
 		timeStarted = time.Now()
-		if err := updateFunc(); err != nil {
+		err = updateFunc()
+		if err == nil {
+			klog.V(6).Infof("Update of %s completed in %s", name, time.Since(timeStarted))
+			return nil
+		}
+		if statusCode, _ := responseOf(err); statusCode != http.StatusPreconditionFailed || attempt >= maxConflictRetries {
 			return err
 		}
-		klog.V(6).Infof("CreateOrUpdate of %s completed in %s", name, time.Since(timeStarted))
-	} else {
-		klog.V(6).Infof("Identity %s already assigned to node %s. Skipping assignment.", userAssignedMSIID, name)
+		klog.Warningf("%s was changed since it was read, retrying the identity update", name)
 	}
-	return nil
 }
 
 func (c *Client) getIdentityResource(name string, isvmss bool) (idH IdentityHolder, update func() error, retErr error) {
@@ -272,7 +279,7 @@ func (c *Client) getIdentityResource(name string, isvmss bool) (idH IdentityHold
 		}
 
 		update = func() error {
-			return c.VMSSClient.CreateOrUpdate(rg, name, vmss)
+			return c.VMSSClient.UpdateIdentities(rg, name, vmss)
 		}
 		idH = &vmssIdentityHolder{&vmss}
 		return idH, update, nil
@@ -283,13 +290,25 @@ func (c *Client) getIdentityResource(name string, isvmss bool) (idH IdentityHold
 		return nil, nil, err
 	}
 	update = func() error {
-		return c.VMClient.CreateOrUpdate(rg, name, vm)
+		return c.VMClient.UpdateIdentities(rg, name, vm)
 	}
 	idH = &vmIdentityHolder{&vm}
 
 	return idH, update, nil
 }
 
+// prepareIfMatch makes the request conditional on the ETag of the response the resource was read from, if any.
+func prepareIfMatch(req *http.Request, resp autorest.Response) (*http.Request, error) {
+	if resp.Response == nil {
+		return req, nil
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return req, nil
+	}
+	return autorest.Prepare(req, autorest.WithHeader("If-Match", etag))
+}
+
 const nestedResourceIDPatternText = `(?i)subscriptions/(.+)/resourceGroups/(.+)/providers/(.+?)/(.+?)/(.+?)/(.+)`
 const resourceIDPatternText = `(?i)subscriptions/(.+)/resourceGroups/(.+)/providers/(.+?)/(.+?)/(.+)`
 
@@ -2,10 +2,14 @@ package cloudprovider
 
 import (
 	"flag"
+	"fmt"
+	"net/http"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/Azure/aad-pod-identity/pkg/config"
+	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -144,6 +148,61 @@ func TestSimple(t *testing.T) {
 	}
 }
 
+func TestUpdateUserMSIConflict(t *testing.T) {
+	cloudClient := NewTestCloudClient(config.AzureConfig{})
+	vmClient := cloudClient.testVMClient
+
+	cloudClient.AssignUserMSI("ID0", "node0", false)
+
+	// another writer adds an identity between our read and our update
+	conflicts := 0
+	vmClient.beforeUpdate = func(nodeName string) {
+		if conflicts > 0 {
+			return
+		}
+		conflicts++
+		vm := vmClient.nodeMap[nodeName]
+		ids := append(*vm.Identity.IdentityIds, "other")
+		vm.Identity.IdentityIds = &ids
+		vmClient.etags[nodeName]++
+	}
+	if err := cloudClient.UpdateUserMSI([]string{"ID1"}, []string{"ID0"}, "node0", false); err != nil {
+		t.Fatalf("expected the update to be retried after the conflict, got %v", err)
+	}
+	if !cloudClient.CompareMSI("node0", false, []string{"other", "ID1"}) {
+		cloudClient.PrintMSI(t)
+		t.Fatalf("expected the concurrent change to be kept and the delta to be reapplied")
+	}
+
+	// retries are bounded when the vm keeps changing
+	updates := 0
+	vmClient.beforeUpdate = func(nodeName string) {
+		updates++
+		vmClient.etags[nodeName]++
+	}
+	err := cloudClient.AssignUserMSI("ID2", "node0", false)
+	if statusCode, _ := responseOf(err); statusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected precondition failed error, got %v", err)
+	}
+	if updates != maxConflictRetries+1 {
+		t.Fatalf("expected %d update attempts, got %d", maxConflictRetries+1, updates)
+	}
+}
+
+func TestPrepareIfMatch(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPatch, "https://management.azure.com/vm", nil)
+	req, err := prepareIfMatch(req, autorest.Response{})
+	if err != nil || req.Header.Get("If-Match") != "" {
+		t.Fatalf("expected no If-Match without a response, got %q (%v)", req.Header.Get("If-Match"), err)
+	}
+
+	resp := autorest.Response{Response: &http.Response{Header: http.Header{"Etag": []string{`"7"`}}}}
+	req, err = prepareIfMatch(req, resp)
+	if err != nil || req.Header.Get("If-Match") != `"7"` {
+		t.Fatalf("expected If-Match \"7\", got %q (%v)", req.Header.Get("If-Match"), err)
+	}
+}
+
 type TestCloudClient struct {
 	*Client
 	// testVMClient is test validation purpose.
@@ -155,6 +214,10 @@ type TestVMClient struct {
 	*VMClient
 	nodeMap map[string]*compute.VirtualMachine
 	err     *error
+	// etags holds the current ETag of each vm, changing on every update.
+	etags map[string]int
+	// beforeUpdate is called before updating the identities of a vm to simulate concurrent changes.
+	beforeUpdate func(nodeName string)
 }
 
 func (c *TestVMClient) SetError(err error) {
@@ -168,11 +231,24 @@ func (c *TestVMClient) UnSetError() {
 func (c *TestVMClient) Get(rgName string, nodeName string) (ret compute.VirtualMachine, err error) {
 	stored := c.nodeMap[nodeName]
 	if stored == nil {
-		vm := new(compute.VirtualMachine)
-		c.nodeMap[nodeName] = vm
-		return *vm, nil
+		stored = new(compute.VirtualMachine)
+		c.nodeMap[nodeName] = stored
 	}
-	return *stored, nil
+	ret = *stored
+	// copy the identity, like a read from ARM does, so that changes to it are only stored on update
+	if stored.Identity != nil {
+		identity := *stored.Identity
+		if identity.IdentityIds != nil {
+			ids := append([]string{}, *identity.IdentityIds...)
+			identity.IdentityIds = &ids
+		}
+		ret.Identity = &identity
+	}
+	ret.Response = autorest.Response{Response: &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": []string{strconv.Itoa(c.etags[nodeName])}},
+	}}
+	return ret, nil
 }
 
 func (c *TestVMClient) CreateOrUpdate(rg string, nodeName string, vm compute.VirtualMachine) error {
@@ -183,6 +259,27 @@ func (c *TestVMClient) CreateOrUpdate(rg string, nodeName string, vm compute.Vir
 	return nil
 }
 
+func (c *TestVMClient) UpdateIdentities(rg string, nodeName string, vm compute.VirtualMachine) error {
+	if c.err != nil {
+		return *c.err
+	}
+	if c.beforeUpdate != nil {
+		c.beforeUpdate(nodeName)
+	}
+	if vm.Response.Header.Get("ETag") != strconv.Itoa(c.etags[nodeName]) {
+		resp := &http.Response{StatusCode: http.StatusPreconditionFailed}
+		return autorest.NewErrorWithError(fmt.Errorf("etag mismatch"), "compute.VirtualMachinesClient", "Update", resp, "Failure sending request")
+	}
+	stored := c.nodeMap[nodeName]
+	if stored == nil {
+		stored = new(compute.VirtualMachine)
+		c.nodeMap[nodeName] = stored
+	}
+	stored.Identity = vm.Identity
+	c.etags[nodeName]++
+	return nil
+}
+
 func (c *TestVMClient) ListMSI() (ret map[string]*[]string) {
 	ret = make(map[string]*[]string)
 
@@ -240,6 +337,10 @@ func (c *TestVMSSClient) CreateOrUpdate(rg string, nodeName string, vm compute.V
 	return nil
 }
 
+func (c *TestVMSSClient) UpdateIdentities(rg string, nodeName string, vm compute.VirtualMachineScaleSet) error {
+	return c.CreateOrUpdate(rg, nodeName, vm)
+}
+
 func (c *TestVMSSClient) ListMSI() (ret map[string]*[]string) {
 	ret = make(map[string]*[]string)
 
@@ -327,9 +428,9 @@ func NewTestVMClient() *TestVMClient {
 	vmClient := &VMClient{}
 
 	return &TestVMClient{
-		vmClient,
-		nodeMap,
-		nil,
+		VMClient: vmClient,
+		nodeMap:  nodeMap,
+		etags:    make(map[string]int),
 	}
 }
 
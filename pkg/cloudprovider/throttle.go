@@ -0,0 +1,238 @@
+package cloudprovider
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/aad-pod-identity/pkg/config"
+	"github.com/Azure/aad-pod-identity/pkg/metrics"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog"
+)
+
+const (
+	// defaults match the ones of the kubernetes azure cloud provider
+	defaultRateLimitQPS    = 1.0
+	defaultRateLimitBucket = 5
+	defaultBackoffDuration = 5 * time.Second
+	defaultBackoffExponent = 1.5
+	defaultBackoffJitter   = 1.0
+
+	// maxBackoffDuration caps the exponential backoff of a single resource.
+	maxBackoffDuration = 5 * time.Minute
+)
+
+// throttle guards the calls made to Azure Resource Manager. Reads and writes are rate limited
+// by separate token buckets, and a VM or VMSS whose last call was throttled or failed with a
+// server error is backed off, honoring Retry-After, before it is called again.
+type throttle struct {
+	reader   flowcontrol.RateLimiter
+	writer   flowcontrol.RateLimiter
+	duration time.Duration
+	exponent float64
+	jitter   float64
+
+	mu        sync.Mutex
+	resources map[string]*resourceBackoff
+	now       func() time.Time
+	reporter  *metrics.Reporter
+}
+
+type resourceBackoff struct {
+	failures int
+	until    time.Time
+}
+
+// backoffError is returned for calls skipped because the resource is backing off.
+type backoffError struct {
+	resource   string
+	retryAfter time.Duration
+}
+
+func (e *backoffError) Error() string {
+	return fmt.Sprintf("%s is backing off after throttling or server errors, retry after %s", e.resource, e.retryAfter)
+}
+
+func newThrottle(config config.AzureConfig, reporter *metrics.Reporter) *throttle {
+	t := &throttle{
+		reader:    flowcontrol.NewFakeAlwaysRateLimiter(),
+		writer:    flowcontrol.NewFakeAlwaysRateLimiter(),
+		duration:  defaultBackoffDuration,
+		exponent:  defaultBackoffExponent,
+		jitter:    defaultBackoffJitter,
+		resources: make(map[string]*resourceBackoff),
+		now:       time.Now,
+		reporter:  reporter,
+	}
+
+	if config.CloudProviderRateLimit {
+		qps, bucket := config.CloudProviderRateLimitQPS, config.CloudProviderRateLimitBucket
+		if qps <= 0 {
+			qps = defaultRateLimitQPS
+		}
+		if bucket <= 0 {
+			bucket = defaultRateLimitBucket
+		}
+		// writes default to the read limits when they are not set explicitly
+		qpsWrite, bucketWrite := config.CloudProviderRateLimitQPSWrite, config.CloudProviderRateLimitBucketWrite
+		if qpsWrite <= 0 {
+			qpsWrite = qps
+		}
+		if bucketWrite <= 0 {
+			bucketWrite = bucket
+		}
+		klog.Infof("Azure Resource Manager rate limits: reads %v QPS (bucket %d), writes %v QPS (bucket %d)", qps, bucket, qpsWrite, bucketWrite)
+		t.reader = flowcontrol.NewTokenBucketRateLimiter(qps, bucket)
+		t.writer = flowcontrol.NewTokenBucketRateLimiter(qpsWrite, bucketWrite)
+	}
+	if config.CloudProviderBackoffDuration > 0 {
+		t.duration = time.Duration(config.CloudProviderBackoffDuration) * time.Second
+	}
+	if config.CloudProviderBackoffExponent > 0 {
+		t.exponent = config.CloudProviderBackoffExponent
+	}
+	if config.CloudProviderBackoffJitter > 0 {
+		t.jitter = config.CloudProviderBackoffJitter
+	}
+	return t
+}
+
+// wait returns an error if the resource is backing off, otherwise it blocks until the read or
+// write rate limiter lets the operation through.
+func (t *throttle) wait(operation, resource string, write bool) error {
+	if remaining := t.backoffRemaining(resource); remaining > 0 {
+		t.reportRateLimited(operation)
+		return &backoffError{resource: resource, retryAfter: remaining}
+	}
+
+	limiter := t.reader
+	if write {
+		limiter = t.writer
+	}
+	if !limiter.TryAccept() {
+		klog.V(5).Infof("Rate limiting %s of %s", operation, resource)
+		t.reportRateLimited(operation)
+		limiter.Accept()
+	}
+	return nil
+}
+
+// done records the outcome of an operation on the resource. Throttled (429) responses and
+// server errors back the resource off for the Retry-After duration when the response carries
+// one, or else exponentially with jitter. Any other outcome resets the backoff.
+func (t *throttle) done(operation, resource string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statusCode, resp := responseOf(err)
+	if statusCode == http.StatusTooManyRequests {
+		if t.reporter != nil {
+			t.reporter.ReportCloudProviderThrottled(operation)
+		}
+	} else if statusCode < http.StatusInternalServerError {
+		delete(t.resources, resource)
+		return
+	}
+
+	b, ok := t.resources[resource]
+	if !ok {
+		b = &resourceBackoff{}
+		t.resources[resource] = b
+	}
+	b.failures++
+
+	delay := retryAfter(resp, t.now())
+	if delay <= 0 {
+		delay = t.backoffDuration(b.failures)
+	}
+	b.until = t.now().Add(delay)
+	klog.Warningf("%s of %s failed with status code %d, backing off for %s", operation, resource, statusCode, delay)
+}
+
+func (t *throttle) backoffRemaining(resource string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.resources[resource]
+	if !ok {
+		return 0
+	}
+	return b.until.Sub(t.now())
+}
+
+// backoffDuration returns the jittered exponential backoff after the given number of
+// consecutive failures.
+func (t *throttle) backoffDuration(failures int) time.Duration {
+	delay := time.Duration(float64(t.duration) * math.Pow(t.exponent, float64(failures-1)))
+	if delay > maxBackoffDuration || delay <= 0 {
+		delay = maxBackoffDuration
+	}
+	return wait.Jitter(delay, t.jitter)
+}
+
+func (t *throttle) reportRateLimited(operation string) {
+	if t.reporter != nil {
+		t.reporter.ReportCloudProviderRateLimited(operation)
+	}
+}
+
+// responseOf returns the HTTP status code and response carried by an error returned from the
+// compute clients. The status code is 0 for errors without a response.
+func responseOf(err error) (int, *http.Response) {
+	var resp *http.Response
+	var statusCode interface{}
+	switch e := err.(type) {
+	case autorest.DetailedError:
+		resp, statusCode = e.Response, e.StatusCode
+	case *autorest.DetailedError:
+		resp, statusCode = e.Response, e.StatusCode
+	case *azure.RequestError:
+		resp, statusCode = e.Response, e.StatusCode
+	default:
+		return 0, nil
+	}
+	if resp != nil {
+		return resp.StatusCode, resp
+	}
+	if code, ok := statusCode.(int); ok {
+		return code, nil
+	}
+	return 0, nil
+}
+
+// retryAfter parses the Retry-After header of the response, which is either a number of
+// seconds or an HTTP date. It returns 0 if the header is absent or malformed.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	value := resp.Header.Get(autorest.HeaderRetryAfter)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+func vmResourceKey(rg, name string) string {
+	return "vm/" + rg + "/" + name
+}
+
+func vmssResourceKey(rg, name string) string {
+	return "vmss/" + rg + "/" + name
+}
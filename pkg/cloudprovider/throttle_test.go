@@ -0,0 +1,157 @@
+package cloudprovider
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/aad-pod-identity/pkg/config"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+func newTestResponse(statusCode int, retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: statusCode, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set(autorest.HeaderRetryAfter, retryAfter)
+	}
+	return resp
+}
+
+func newTestDetailedError(statusCode int, retryAfter string) error {
+	resp := newTestResponse(statusCode, retryAfter)
+	return autorest.NewErrorWithError(fmt.Errorf("status %d", statusCode), "compute.VirtualMachinesClient", "Get", resp, "Failure responding to request")
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc   string
+		resp   *http.Response
+		expect time.Duration
+	}{
+		{"no response", nil, 0},
+		{"no header", newTestResponse(http.StatusTooManyRequests, ""), 0},
+		{"seconds", newTestResponse(http.StatusTooManyRequests, "17"), 17 * time.Second},
+		{"negative seconds", newTestResponse(http.StatusTooManyRequests, "-1"), 0},
+		{"http date", newTestResponse(http.StatusTooManyRequests, now.Add(time.Minute).Format(http.TimeFormat)), time.Minute},
+		{"malformed", newTestResponse(http.StatusTooManyRequests, "soon"), 0},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := retryAfter(tc.resp, now); got != tc.expect {
+				t.Fatalf("expected %s, got %s", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestResponseOf(t *testing.T) {
+	detailed := newTestDetailedError(http.StatusTooManyRequests, "")
+	requestErr := &azure.RequestError{DetailedError: detailed.(autorest.DetailedError)}
+	noResponse := autorest.DetailedError{StatusCode: http.StatusServiceUnavailable}
+
+	for _, tc := range []struct {
+		desc   string
+		err    error
+		expect int
+	}{
+		{"nil", nil, 0},
+		{"plain error", fmt.Errorf("boom"), 0},
+		{"detailed error", detailed, http.StatusTooManyRequests},
+		{"request error", requestErr, http.StatusTooManyRequests},
+		{"status code only", noResponse, http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got, _ := responseOf(tc.err); got != tc.expect {
+				t.Fatalf("expected status code %d, got %d", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestThrottleBackoff(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	th := newThrottle(config.AzureConfig{
+		CloudProviderBackoffDuration: 2,
+		CloudProviderBackoffExponent: 2,
+		CloudProviderBackoffJitter:   0.5,
+	}, nil)
+	th.now = func() time.Time { return now }
+
+	resource := vmResourceKey("rg", "node0")
+	other := vmResourceKey("rg", "node1")
+
+	// throttled with Retry-After: the resource backs off for exactly that long.
+	th.done("vm_get", resource, newTestDetailedError(http.StatusTooManyRequests, "30"))
+	err := th.wait("vm_get", resource, false)
+	if _, ok := err.(*backoffError); !ok {
+		t.Fatalf("expected backoff error, got %v", err)
+	}
+	if remaining := th.backoffRemaining(resource); remaining != 30*time.Second {
+		t.Fatalf("expected 30s backoff, got %s", remaining)
+	}
+	if err := th.wait("vm_get", other, false); err != nil {
+		t.Fatalf("expected other resources not to back off, got %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := th.wait("vm_create_or_update", resource, true); err != nil {
+		t.Fatalf("expected backoff to be over, got %v", err)
+	}
+
+	// further consecutive server errors without Retry-After back off exponentially with jitter.
+	for _, base := range []time.Duration{4 * time.Second, 8 * time.Second} {
+		th.done("vm_create_or_update", resource, newTestDetailedError(http.StatusInternalServerError, ""))
+		remaining := th.backoffRemaining(resource)
+		if remaining < base || remaining > base+base/2 {
+			t.Fatalf("expected backoff between %s and %s, got %s", base, base+base/2, remaining)
+		}
+	}
+
+	// client errors and successes reset the backoff.
+	th.done("vm_get", resource, newTestDetailedError(http.StatusNotFound, ""))
+	if err := th.wait("vm_get", resource, false); err != nil {
+		t.Fatalf("expected backoff to be reset, got %v", err)
+	}
+	th.done("vm_get", other, newTestDetailedError(http.StatusTooManyRequests, ""))
+	th.done("vm_get", other, nil)
+	if err := th.wait("vm_get", other, false); err != nil {
+		t.Fatalf("expected backoff to be reset, got %v", err)
+	}
+}
+
+func TestThrottleBackoffCapped(t *testing.T) {
+	th := newThrottle(config.AzureConfig{CloudProviderBackoffJitter: 0.1}, nil)
+	for failures := 1; failures < 100; failures++ {
+		if d := th.backoffDuration(failures); d > maxBackoffDuration+maxBackoffDuration/10 {
+			t.Fatalf("expected backoff to be capped at %s, got %s after %d failures", maxBackoffDuration, d, failures)
+		}
+	}
+}
+
+func TestThrottleRateLimit(t *testing.T) {
+	th := newThrottle(config.AzureConfig{
+		CloudProviderRateLimit:            true,
+		CloudProviderRateLimitQPS:         0.001,
+		CloudProviderRateLimitBucket:      2,
+		CloudProviderRateLimitBucketWrite: 1,
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		if !th.reader.TryAccept() {
+			t.Fatalf("expected read %d to be accepted", i)
+		}
+	}
+	if th.reader.TryAccept() {
+		t.Fatalf("expected read to be rate limited once the bucket is empty")
+	}
+	// writes are limited by their own bucket.
+	if !th.writer.TryAccept() {
+		t.Fatalf("expected write to be accepted")
+	}
+	if th.writer.TryAccept() {
+		t.Fatalf("expected write to be rate limited once the bucket is empty")
+	}
+}
@@ -19,12 +19,14 @@ import (
 type VMClient struct {
 	client   compute.VirtualMachinesClient
 	reporter *metrics.Reporter
+	throttle *throttle
 }
 
 // VMClientInt is the interface used by "cloudprovider" for interacting with Azure vmas
 type VMClientInt interface {
 	CreateOrUpdate(rg string, nodeName string, vm compute.VirtualMachine) error
 	Get(rgName string, nodeName string) (compute.VirtualMachine, error)
+	UpdateIdentities(rg, nodeName string, vm compute.VirtualMachine) error
 }
 
 // NewVirtualMachinesClient creates a new vm client.
@@ -50,6 +52,7 @@ func NewVirtualMachinesClient(config config.AzureConfig, spt *adal.ServicePrinci
 	return &VMClient{
 		client:   client,
 		reporter: reporter,
+		throttle: newThrottle(config, reporter),
 	}, nil
 }
 
@@ -58,11 +61,18 @@ func NewVirtualMachinesClient(config config.AzureConfig, spt *adal.ServicePrinci
 func (c *VMClient) CreateOrUpdate(rg string, nodeName string, vm compute.VirtualMachine) error {
 	// Set the read-only property of extension to null.
 	vm.Resources = nil
+	resource := vmResourceKey(rg, nodeName)
+	if err := c.throttle.wait(metrics.PutVMOperationName, resource, true); err != nil {
+		klog.Error(err)
+		return err
+	}
+
 	ctx := context.Background()
 	begin := time.Now()
 	var err error
 
 	defer func() {
+		c.throttle.done(metrics.PutVMOperationName, resource, err)
 		if err != nil {
 			c.reporter.ReportCloudProviderOperationError(metrics.PutVMOperationName)
 			return
@@ -86,13 +96,70 @@ func (c *VMClient) CreateOrUpdate(rg string, nodeName string, vm compute.Virtual
 	return nil
 }
 
+// UpdateIdentities updates only the identity of the vm, leaving the rest of its model untouched.
+// If the vm was read with an ETag, the update is conditional on it and fails with
+// 412 Precondition Failed when the vm was changed since it was read.
+func (c *VMClient) UpdateIdentities(rg string, nodeName string, vm compute.VirtualMachine) error {
+	resource := vmResourceKey(rg, nodeName)
+	if err := c.throttle.wait(metrics.UpdateVMOperationName, resource, true); err != nil {
+		klog.Error(err)
+		return err
+	}
+
+	ctx := context.Background()
+	begin := time.Now()
+	var err error
+
+	defer func() {
+		c.throttle.done(metrics.UpdateVMOperationName, resource, err)
+		if err != nil {
+			c.reporter.ReportCloudProviderOperationError(metrics.UpdateVMOperationName)
+			return
+		}
+		c.reporter.ReportCloudProviderOperationDuration(metrics.UpdateVMOperationName, time.Since(begin))
+	}()
+
+	req, err := c.client.UpdatePreparer(ctx, rg, nodeName, compute.VirtualMachineUpdate{Identity: vm.Identity})
+	if err == nil {
+		req, err = prepareIfMatch(req, vm.Response)
+	}
+	if err != nil {
+		err = autorest.NewErrorWithError(err, "compute.VirtualMachinesClient", "Update", nil, "Failure preparing request")
+		klog.Error(err)
+		return err
+	}
+
+	future, err := c.client.UpdateSender(req)
+	if err != nil {
+		err = autorest.NewErrorWithError(err, "compute.VirtualMachinesClient", "Update", future.Response(), "Failure sending request")
+		klog.Error(err)
+		return err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.client.Client)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	stats.UpdateCount(stats.TotalPutCalls, 1)
+	stats.Update(stats.CloudPut, time.Since(begin))
+	return nil
+}
+
 // Get gets the passed in vm.
 func (c *VMClient) Get(rgName string, nodeName string) (compute.VirtualMachine, error) {
+	resource := vmResourceKey(rgName, nodeName)
+	if err := c.throttle.wait(metrics.GetVMOperationName, resource, false); err != nil {
+		klog.Error(err)
+		return compute.VirtualMachine{}, err
+	}
+
 	ctx := context.Background()
 	begin := time.Now()
 	var err error
 
 	defer func() {
+		c.throttle.done(metrics.GetVMOperationName, resource, err)
 		if err != nil {
 			c.reporter.ReportCloudProviderOperationError(metrics.GetVMOperationName)
 			return
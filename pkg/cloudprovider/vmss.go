@@ -19,12 +19,14 @@ import (
 type VMSSClient struct {
 	client   compute.VirtualMachineScaleSetsClient
 	reporter *metrics.Reporter
+	throttle *throttle
 }
 
 // VMSSClientInt is the interface used by "cloudprovider" for interacting with Azure vmss
 type VMSSClientInt interface {
 	CreateOrUpdate(rg, name string, vm compute.VirtualMachineScaleSet) error
 	Get(rgName, name string) (compute.VirtualMachineScaleSet, error)
+	UpdateIdentities(rg, vmssName string, vmss compute.VirtualMachineScaleSet) error
 }
 
 // NewVMSSClient creates a new vmss client.
@@ -50,6 +52,7 @@ func NewVMSSClient(config config.AzureConfig, spt *adal.ServicePrincipalToken) (
 	return &VMSSClient{
 		client:   client,
 		reporter: reporter,
+		throttle: newThrottle(config, reporter),
 	}, nil
 }
 
@@ -58,11 +61,18 @@ func NewVMSSClient(config config.AzureConfig, spt *adal.ServicePrincipalToken) (
 func (c *VMSSClient) CreateOrUpdate(rg string, vmssName string, vm compute.VirtualMachineScaleSet) error {
 	// Set the read-only property of extension to null.
 	//vm.Resources = nil
+	resource := vmssResourceKey(rg, vmssName)
+	if err := c.throttle.wait(metrics.PutVmssOperationName, resource, true); err != nil {
+		klog.Error(err)
+		return err
+	}
+
 	ctx := context.Background()
 	begin := time.Now()
 	var err error
 
 	defer func() {
+		c.throttle.done(metrics.PutVmssOperationName, resource, err)
 		if err != nil {
 			c.reporter.ReportCloudProviderOperationError(metrics.PutVmssOperationName)
 			return
@@ -86,12 +96,69 @@ func (c *VMSSClient) CreateOrUpdate(rg string, vmssName string, vm compute.Virtu
 	return nil
 }
 
+// UpdateIdentities updates only the identity of the vmss, leaving the rest of its model untouched.
+// If the vmss was read with an ETag, the update is conditional on it and fails with
+// 412 Precondition Failed when the vmss was changed since it was read.
+func (c *VMSSClient) UpdateIdentities(rg string, vmssName string, vmss compute.VirtualMachineScaleSet) error {
+	resource := vmssResourceKey(rg, vmssName)
+	if err := c.throttle.wait(metrics.UpdateVmssOperationName, resource, true); err != nil {
+		klog.Error(err)
+		return err
+	}
+
+	ctx := context.Background()
+	begin := time.Now()
+	var err error
+
+	defer func() {
+		c.throttle.done(metrics.UpdateVmssOperationName, resource, err)
+		if err != nil {
+			c.reporter.ReportCloudProviderOperationError(metrics.UpdateVmssOperationName)
+			return
+		}
+		c.reporter.ReportCloudProviderOperationDuration(metrics.UpdateVmssOperationName, time.Since(begin))
+	}()
+
+	req, err := c.client.UpdatePreparer(ctx, rg, vmssName, compute.VirtualMachineScaleSetUpdate{Identity: vmss.Identity})
+	if err == nil {
+		req, err = prepareIfMatch(req, vmss.Response)
+	}
+	if err != nil {
+		err = autorest.NewErrorWithError(err, "compute.VirtualMachineScaleSetsClient", "Update", nil, "Failure preparing request")
+		klog.Error(err)
+		return err
+	}
+
+	future, err := c.client.UpdateSender(req)
+	if err != nil {
+		err = autorest.NewErrorWithError(err, "compute.VirtualMachineScaleSetsClient", "Update", future.Response(), "Failure sending request")
+		klog.Error(err)
+		return err
+	}
+
+	err = future.WaitForCompletionRef(ctx, c.client.Client)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	stats.UpdateCount(stats.TotalPutCalls, 1)
+	stats.Update(stats.CloudPut, time.Since(begin))
+	return nil
+}
+
 // Get gets the passed in vmss.
 func (c *VMSSClient) Get(rgName string, vmssName string) (ret compute.VirtualMachineScaleSet, err error) {
+	resource := vmssResourceKey(rgName, vmssName)
+	if err := c.throttle.wait(metrics.GetVmssOperationName, resource, false); err != nil {
+		klog.Error(err)
+		return ret, err
+	}
+
 	ctx := context.Background()
 	begin := time.Now()
 
 	defer func() {
+		c.throttle.done(metrics.GetVmssOperationName, resource, err)
 		if err != nil {
 			c.reporter.ReportCloudProviderOperationError(metrics.GetVmssOperationName)
 			return
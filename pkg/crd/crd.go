@@ -3,33 +3,41 @@ package crd
 import (
 	"encoding/json"
 	"fmt"
-	"reflect"
+	"sort"
 	"time"
 
 	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
 	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned"
+	informers "github.com/Azure/aad-pod-identity/pkg/client/informers/externalversions"
+	informersv1 "github.com/Azure/aad-pod-identity/pkg/client/informers/externalversions/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/client/informers/externalversions/internalinterfaces"
+	listers "github.com/Azure/aad-pod-identity/pkg/client/listers/aadpodidentity/v1"
 	"github.com/Azure/aad-pod-identity/pkg/metrics"
 	"github.com/Azure/aad-pod-identity/pkg/stats"
+	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/informers/internalinterfaces"
-	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 )
 
+const resyncPeriod = time.Minute * 10
+
 // Client represents all the watchers
 type Client struct {
-	rest                         *rest.RESTClient
-	BindingInformer              cache.SharedInformer
-	IDInformer                   cache.SharedInformer
-	AssignedIDInformer           cache.SharedInformer
-	PodIdentityExceptionInformer cache.SharedInformer
+	clientSet                    versioned.Interface
+	BindingInformer              cache.SharedIndexInformer
+	IDInformer                   cache.SharedIndexInformer
+	AssignedIDInformer           cache.SharedIndexInformer
+	PodIdentityExceptionInformer cache.SharedIndexInformer
+	bindingLister                listers.AzureIdentityBindingLister
+	idLister                     listers.AzureIdentityLister
+	assignedIDLister             listers.AzureAssignedIdentityLister
+	podIdentityExceptionLister   listers.AzurePodIdentityExceptionLister
 	reporter                     *metrics.Reporter
+	podIDWatchers                *podIDWatchers
 }
 
 // ClientInt ...
@@ -40,41 +48,32 @@ type ClientInt interface {
 	RemoveAssignedIdentity(assignedIdentity *aadpodid.AzureAssignedIdentity) error
 	CreateAssignedIdentity(assignedIdentity *aadpodid.AzureAssignedIdentity) error
 	UpdateAzureAssignedIdentityStatus(assignedIdentity *aadpodid.AzureAssignedIdentity, status string) error
+	UpdateAzureIdentityStatus(azureID *aadpodid.AzureIdentity, status aadpodid.AzureIdentityStatus) error
+	UpdateAzureIdentityBindingStatus(binding *aadpodid.AzureIdentityBinding, status aadpodid.AzureIdentityBindingStatus) error
 	ListBindings() (res *[]aadpodid.AzureIdentityBinding, err error)
 	ListAssignedIDs() (res *[]aadpodid.AzureAssignedIdentity, err error)
 	ListAssignedIDsInMap() (res map[string]aadpodid.AzureAssignedIdentity, err error)
 	ListIds() (res *[]aadpodid.AzureIdentity, err error)
-	ListPodIds(podns, podname string) (map[string][]aadpodid.AzureIdentity, error)
+	ListPodIds(podns, podname string, podUID types.UID) (map[string][]aadpodid.AzureIdentity, error)
 	ListPodIdentityExceptions(ns string) (res *[]aadpodid.AzurePodIdentityException, err error)
 }
 
-// NewCRDClientLite ...
-func NewCRDClientLite(config *rest.Config, nodeName string, scale bool) (crdClient *Client, err error) {
-	restClient, err := newRestClient(config)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
-	}
-
-	var assignedIDListWatch *cache.ListWatch
-
+// NewCRDClientLite returns a crd client caching only the assigned identities and
+// pod identity exceptions. With scale set, only the assigned identities of the
+// node are cached.
+func NewCRDClientLite(clientSet versioned.Interface, nodeName string, scale bool) (crdClient *Client, err error) {
+	informerFactory := informers.NewSharedInformerFactory(clientSet, resyncPeriod)
+	assignedIDInformerFactory := informerFactory
 	if scale {
-		assignedIDListWatch = newAssignedIDNodeListWatch(restClient, nodeName)
-	} else {
-		assignedIDListWatch = newAssignedIDListWatch(restClient)
+		assignedIDInformerFactory = informers.NewFilteredSharedInformerFactory(clientSet, resyncPeriod, v1.NamespaceAll, NodeNameFilter(nodeName))
 	}
 
-	assignedIDListInformer, err := newAssignedIDInformer(assignedIDListWatch)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
-	}
-	podIdentityExceptionListWatch := newPodIdentityExceptionListWatch(restClient)
-	podIdentityExceptionInformer, err := newPodIdentityExceptionInformer(podIdentityExceptionListWatch)
+	assignedIDInformer, err := newAssignedIDInformer(assignedIDInformerFactory)
 	if err != nil {
 		klog.Error(err)
 		return nil, err
 	}
+	podIdentityExceptionInformer := informerFactory.Aadpodidentity().V1().AzurePodIdentityExceptions()
 
 	reporter, err := metrics.NewReporter()
 	if err != nil {
@@ -83,37 +82,27 @@ func NewCRDClientLite(config *rest.Config, nodeName string, scale bool) (crdClie
 	}
 
 	return &Client{
-		AssignedIDInformer:           assignedIDListInformer,
-		PodIdentityExceptionInformer: podIdentityExceptionInformer,
-		rest:                         restClient,
+		clientSet:                    clientSet,
+		AssignedIDInformer:           assignedIDInformer.Informer(),
+		PodIdentityExceptionInformer: podIdentityExceptionInformer.Informer(),
+		assignedIDLister:             assignedIDInformer.Lister(),
+		podIdentityExceptionLister:   podIdentityExceptionInformer.Lister(),
 		reporter:                     reporter,
+		podIDWatchers:                newPodIDWatchers(assignedIDInformer.Informer()),
 	}, nil
 }
 
 // NewCRDClient returns a new crd client and error if any
-func NewCRDClient(config *rest.Config, eventCh chan aadpodid.EventType) (crdClient *Client, err error) {
-	restClient, err := newRestClient(config)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
-	}
+func NewCRDClient(clientSet versioned.Interface, eventCh chan aadpodid.EventType) (crdClient *Client, err error) {
+	informerFactory := informers.NewSharedInformerFactory(clientSet, resyncPeriod)
 
-	bindingListWatch := newBindingListWatch(restClient)
-	bindingInformer, err := newBindingInformer(restClient, eventCh, bindingListWatch)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
-	}
+	bindingInformer := informerFactory.Aadpodidentity().V1().AzureIdentityBindings()
+	addBindingEventHandler(bindingInformer.Informer(), eventCh)
 
-	idListWatch := newIDListWatch(restClient)
-	idInformer, err := newIDInformer(restClient, eventCh, idListWatch)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
-	}
+	idInformer := informerFactory.Aadpodidentity().V1().AzureIdentities()
+	addIDEventHandler(idInformer.Informer(), eventCh)
 
-	assignedIDListWatch := newAssignedIDListWatch(restClient)
-	assignedIDListInformer, err := newAssignedIDInformer(assignedIDListWatch)
+	assignedIDInformer, err := newAssignedIDInformer(informerFactory)
 	if err != nil {
 		klog.Error(err)
 		return nil, err
@@ -126,54 +115,20 @@ func NewCRDClient(config *rest.Config, eventCh chan aadpodid.EventType) (crdClie
 	}
 
 	return &Client{
-		rest:               restClient,
-		BindingInformer:    bindingInformer,
-		IDInformer:         idInformer,
-		AssignedIDInformer: assignedIDListInformer,
+		clientSet:          clientSet,
+		BindingInformer:    bindingInformer.Informer(),
+		IDInformer:         idInformer.Informer(),
+		AssignedIDInformer: assignedIDInformer.Informer(),
+		bindingLister:      bindingInformer.Lister(),
+		idLister:           idInformer.Lister(),
+		assignedIDLister:   assignedIDInformer.Lister(),
 		reporter:           reporter,
+		podIDWatchers:      newPodIDWatchers(assignedIDInformer.Informer()),
 	}, nil
 }
 
-func newRestClient(config *rest.Config) (r *rest.RESTClient, err error) {
-	crdconfig := *config
-	crdconfig.GroupVersion = &schema.GroupVersion{Group: aadpodv1.CRDGroup, Version: aadpodv1.CRDVersion}
-	crdconfig.APIPath = "/apis"
-	crdconfig.ContentType = runtime.ContentTypeJSON
-	s := runtime.NewScheme()
-	s.AddKnownTypes(*crdconfig.GroupVersion,
-		&aadpodv1.AzureIdentity{},
-		&aadpodv1.AzureIdentityList{},
-		&aadpodv1.AzureIdentityBinding{},
-		&aadpodv1.AzureIdentityBindingList{},
-		&aadpodv1.AzureAssignedIdentity{},
-		&aadpodv1.AzureAssignedIdentityList{},
-		&aadpodv1.AzurePodIdentityException{},
-		&aadpodv1.AzurePodIdentityExceptionList{},
-	)
-	crdconfig.NegotiatedSerializer = serializer.DirectCodecFactory{
-		CodecFactory: serializer.NewCodecFactory(s)}
-
-	//Client interacting with our CRDs
-	restClient, err := rest.RESTClientFor(&crdconfig)
-	if err != nil {
-		return nil, err
-	}
-	return restClient, nil
-}
-
-func newBindingListWatch(r *rest.RESTClient) *cache.ListWatch {
-	return cache.NewListWatchFromClient(r, aadpodv1.AzureIDBindingResource, v1.NamespaceAll, fields.Everything())
-}
-
-func newBindingInformer(r *rest.RESTClient, eventCh chan aadpodid.EventType, lw *cache.ListWatch) (cache.SharedInformer, error) {
-	azBindingInformer := cache.NewSharedInformer(
-		lw,
-		&aadpodv1.AzureIdentityBinding{},
-		time.Minute*10)
-	if azBindingInformer == nil {
-		return nil, fmt.Errorf("Could not create watcher for %s", aadpodv1.AzureIDBindingResource)
-	}
-	azBindingInformer.AddEventHandler(
+func addBindingEventHandler(informer cache.SharedIndexInformer, eventCh chan aadpodid.EventType) {
+	informer.AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				klog.V(6).Infof("Binding created")
@@ -189,22 +144,10 @@ func newBindingInformer(r *rest.RESTClient, eventCh chan aadpodid.EventType, lw
 			},
 		},
 	)
-	return azBindingInformer, nil
 }
 
-func newIDListWatch(r *rest.RESTClient) *cache.ListWatch {
-	return cache.NewListWatchFromClient(r, aadpodv1.AzureIDResource, v1.NamespaceAll, fields.Everything())
-}
-
-func newIDInformer(r *rest.RESTClient, eventCh chan aadpodid.EventType, lw *cache.ListWatch) (cache.SharedInformer, error) {
-	azIDInformer := cache.NewSharedInformer(
-		lw,
-		&aadpodv1.AzureIdentity{},
-		time.Minute*10)
-	if azIDInformer == nil {
-		return nil, fmt.Errorf("Could not create Identity watcher for %s", aadpodv1.AzureIDResource)
-	}
-	azIDInformer.AddEventHandler(
+func addIDEventHandler(informer cache.SharedIndexInformer, eventCh chan aadpodid.EventType) {
+	informer.AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				klog.V(6).Infof("Identity created")
@@ -220,7 +163,6 @@ func newIDInformer(r *rest.RESTClient, eventCh chan aadpodid.EventType, lw *cach
 			},
 		},
 	)
-	return azIDInformer, nil
 }
 
 // NodeNameFilter - CRDs do not yet support field selectors. Instead of that we
@@ -236,39 +178,15 @@ func NodeNameFilter(nodeName string) internalinterfaces.TweakListOptionsFunc {
 	}
 }
 
-func newAssignedIDNodeListWatch(r *rest.RESTClient, nodeName string) *cache.ListWatch {
-	return cache.NewFilteredListWatchFromClient(r, aadpodv1.AzureAssignedIDResource, v1.NamespaceAll, NodeNameFilter(nodeName))
-}
-
-func newAssignedIDListWatch(r *rest.RESTClient) *cache.ListWatch {
-	return cache.NewListWatchFromClient(r, aadpodv1.AzureAssignedIDResource, v1.NamespaceAll, fields.Everything())
-}
-
-func newAssignedIDInformer(lw *cache.ListWatch) (cache.SharedInformer, error) {
-	azAssignedIDInformer := cache.NewSharedInformer(lw, &aadpodv1.AzureAssignedIdentity{}, time.Minute*10)
-	if azAssignedIDInformer == nil {
-		return nil, fmt.Errorf("could not create %s informer", aadpodv1.AzureAssignedIDResource)
-	}
-
-	return azAssignedIDInformer, nil
-}
-
-func newPodIdentityExceptionListWatch(r *rest.RESTClient) *cache.ListWatch {
-	optionsModifier := func(options *v1.ListOptions) {}
-	return cache.NewFilteredListWatchFromClient(
-		r,
-		aadpodv1.AzureIdentityExceptionResource,
-		v1.NamespaceAll,
-		optionsModifier,
-	)
-}
-
-func newPodIdentityExceptionInformer(lw *cache.ListWatch) (cache.SharedInformer, error) {
-	azPodIDExceptionInformer := cache.NewSharedInformer(lw, &aadpodv1.AzurePodIdentityException{}, time.Minute*10)
-	if azPodIDExceptionInformer == nil {
-		return nil, fmt.Errorf("could not create %s informer", aadpodv1.AzureIdentityExceptionResource)
+// newAssignedIDInformer returns the assigned identity informer of the factory,
+// indexed by the pod the identity is assigned to.
+func newAssignedIDInformer(informerFactory informers.SharedInformerFactory) (informersv1.AzureAssignedIdentityInformer, error) {
+	assignedIDInformer := informerFactory.Aadpodidentity().V1().AzureAssignedIdentities()
+	err := assignedIDInformer.Informer().AddIndexers(cache.Indexers{assignedIDPodIndex: assignedIDPodIndexFunc})
+	if err != nil {
+		return nil, fmt.Errorf("could not create %s informer: %v", aadpodv1.AzureAssignedIDResource, err)
 	}
-	return azPodIDExceptionInformer, nil
+	return assignedIDInformer, nil
 }
 
 // StartLite to be used only case of lite client
@@ -310,6 +228,32 @@ func (c *Client) syncCache(exit <-chan struct{}, initial bool, cacheSyncs ...cac
 	}
 }
 
+// AddAssignedIDDeleteHandler registers a handler that is invoked for every
+// AzureAssignedIdentity removed from the assigned identity informer cache.
+func (c *Client) AddAssignedIDDeleteHandler(handler func(assignedID *aadpodid.AzureAssignedIdentity)) {
+	c.AssignedIDInformer.AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				o, ok := obj.(*aadpodv1.AzureAssignedIdentity)
+				if !ok {
+					klog.Errorf("could not cast %T to %s", obj, aadpodv1.AzureAssignedIDResource)
+					return
+				}
+				if o.Spec.AzureIdentityRef == nil || o.Spec.AzureBindingRef == nil {
+					klog.Warningf("assigned id %s/%s deleted without identity or binding reference", o.Namespace, o.Name)
+					return
+				}
+				klog.V(6).Infof("Assigned ID %s/%s deleted", o.Namespace, o.Name)
+				out := aadpodv1.ConvertV1AssignedIdentityToInternalAssignedIdentity(*o)
+				handler(&out)
+			},
+		},
+	)
+}
+
 // RemoveAssignedIdentity removes the assigned identity
 func (c *Client) RemoveAssignedIdentity(assignedIdentity *aadpodid.AzureAssignedIdentity) (err error) {
 	klog.V(6).Infof("Deletion of assigned id named: %s", assignedIdentity.Name)
@@ -326,12 +270,46 @@ func (c *Client) RemoveAssignedIdentity(assignedIdentity *aadpodid.AzureAssigned
 
 	}()
 
-	err = c.rest.Delete().Namespace(assignedIdentity.Namespace).Resource("azureassignedidentities").Name(assignedIdentity.Name).Do().Error()
+	// the finalizer is removed first, as the assigned identity may already be deleted by the
+	// garbage collector after its pod was deleted, and is only waiting for mic to remove it
+	if err = c.removeAssignedIdentityFinalizer(assignedIdentity); err != nil {
+		return err
+	}
+	err = c.clientSet.AadpodidentityV1().AzureAssignedIdentities(assignedIdentity.Namespace).Delete(assignedIdentity.Name, &v1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		err = nil
+	}
 	klog.V(5).Infof("Deletion %s took: %v", assignedIdentity.Name, time.Since(begin))
 	stats.Update(stats.AssignedIDDel, time.Since(begin))
 	return err
 }
 
+// removeAssignedIdentityFinalizer removes the mic finalizer from the assigned identity
+func (c *Client) removeAssignedIdentityFinalizer(assignedIdentity *aadpodid.AzureAssignedIdentity) error {
+	finalizers := make([]string, 0, len(assignedIdentity.Finalizers))
+	for _, finalizer := range assignedIdentity.Finalizers {
+		if finalizer != aadpodid.AssignedIDFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	if len(finalizers) == len(assignedIdentity.Finalizers) {
+		return nil
+	}
+
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{"finalizers": finalizers},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.clientSet.AadpodidentityV1().AzureAssignedIdentities(assignedIdentity.Namespace).Patch(assignedIdentity.Name, types.MergePatchType, patchBytes)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
 // CreateAssignedIdentity creates new assigned identity
 func (c *Client) CreateAssignedIdentity(assignedIdentity *aadpodid.AzureAssignedIdentity) (err error) {
 	klog.Infof("Got assigned id %s to assign", assignedIdentity.Name)
@@ -349,14 +327,19 @@ func (c *Client) CreateAssignedIdentity(assignedIdentity *aadpodid.AzureAssigned
 	}()
 
 	// Create a new AzureAssignedIdentity which maps the relationship between id and pod
-	var res aadpodv1.AzureAssignedIdentity
 	v1AssignedID := aadpodv1.ConvertInternalAssignedIdentityToV1AssignedIdentity(*assignedIdentity)
-	// TODO: Ensure that the status reflects the corresponding
-	err = c.rest.Post().Namespace(assignedIdentity.Namespace).Resource("azureassignedidentities").Body(&v1AssignedID).Do().Into(&res)
+	res, err := c.clientSet.AadpodidentityV1().AzureAssignedIdentities(assignedIdentity.Namespace).Create(&v1AssignedID)
 	if err != nil {
 		klog.Error(err)
 		return err
 	}
+	// the status is dropped on create since it is a subresource, so it is written separately
+	if assignedIdentity.Status.Status != "" && res.Status.Status != assignedIdentity.Status.Status {
+		if err = c.patchAssignedIdentityStatus(res.Namespace, res.Name, assignedIdentity.Status.Status); err != nil {
+			klog.Error(err)
+			return err
+		}
+	}
 
 	klog.V(5).Infof("Time take to create %s: %v", assignedIdentity.Name, time.Since(begin))
 	stats.Update(stats.AssignedIDAdd, time.Since(begin))
@@ -367,26 +350,20 @@ func (c *Client) CreateAssignedIdentity(assignedIdentity *aadpodid.AzureAssigned
 func (c *Client) ListBindings() (res *[]aadpodid.AzureIdentityBinding, err error) {
 	begin := time.Now()
 
-	var resList []aadpodid.AzureIdentityBinding
-
-	list := c.BindingInformer.GetStore().List()
-	for _, binding := range list {
-		o, ok := binding.(*aadpodv1.AzureIdentityBinding)
-		if !ok {
-			err := fmt.Errorf("could not cast %T to %s", binding, aadpodv1.AzureIDBindingResource)
-			klog.Error(err)
-			return nil, err
-		}
-		// Note: List items returned from cache have empty Kind and API version..
-		// Work around this issue since we need that for event recording to work.
-		o.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   aadpodv1.CRDGroup,
-			Version: aadpodv1.CRDVersion,
-			Kind:    reflect.TypeOf(*o).String()})
+	list, err := c.bindingLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
 
-		internalBinding := aadpodv1.ConvertV1BindingToInternalBinding(*o)
+	resList := make([]aadpodid.AzureIdentityBinding, 0, len(list))
+	for _, o := range list {
+		out := aadpodv1.ConvertV1BindingToInternalBinding(*o)
+		// objects returned from the cache have an empty kind and api version,
+		// which are needed for event recording to work
+		out.SetGroupVersionKind(aadpodv1.SchemeGroupVersion.WithKind("AzureIdentityBinding"))
 
-		resList = append(resList, internalBinding)
+		resList = append(resList, out)
 		klog.V(6).Infof("Appending binding: %s/%s to list.", o.Namespace, o.Name)
 	}
 
@@ -398,23 +375,15 @@ func (c *Client) ListBindings() (res *[]aadpodid.AzureIdentityBinding, err error
 func (c *Client) ListAssignedIDs() (res *[]aadpodid.AzureAssignedIdentity, err error) {
 	begin := time.Now()
 
-	var resList []aadpodid.AzureAssignedIdentity
+	list, err := c.assignedIDLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
 
-	list := c.AssignedIDInformer.GetStore().List()
-	for _, assignedID := range list {
-		o, ok := assignedID.(*aadpodv1.AzureAssignedIdentity)
-		if !ok {
-			err := fmt.Errorf("could not cast %T to %s", assignedID, aadpodv1.AzureAssignedIDResource)
-			klog.Error(err)
-			return nil, err
-		}
-		// Note: List items returned from cache have empty Kind and API version..
-		// Work around this issue since we need that for event recording to work.
-		o.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   aadpodv1.CRDGroup,
-			Version: aadpodv1.CRDVersion,
-			Kind:    reflect.TypeOf(*o).String()})
-		out := aadpodv1.ConvertV1AssignedIdentityToInternalAssignedIdentity(*o)
+	resList := make([]aadpodid.AzureAssignedIdentity, 0, len(list))
+	for _, o := range list {
+		out := convertAssignedID(o)
 		resList = append(resList, out)
 		klog.V(6).Infof("Appending Assigned ID: %s/%s to list.", o.Namespace, o.Name)
 	}
@@ -428,28 +397,16 @@ func (c *Client) ListAssignedIDs() (res *[]aadpodid.AzureAssignedIdentity, err e
 func (c *Client) ListAssignedIDsInMap() (map[string]aadpodid.AzureAssignedIdentity, error) {
 	begin := time.Now()
 
-	result := make(map[string]aadpodid.AzureAssignedIdentity)
-	list := c.AssignedIDInformer.GetStore().List()
-
-	for _, assignedID := range list {
-
-		o, ok := assignedID.(*aadpodv1.AzureAssignedIdentity)
-		if !ok {
-			err := fmt.Errorf("could not cast %T to %s", assignedID, aadpodv1.AzureAssignedIDResource)
-			klog.Error(err)
-			return nil, err
-		}
-		// Note: List items returned from cache have empty Kind and API version..
-		// Work around this issue since we need that for event recording to work.
-		o.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   aadpodv1.CRDGroup,
-			Version: aadpodv1.CRDVersion,
-			Kind:    reflect.TypeOf(*o).String()})
-
-		out := aadpodv1.ConvertV1AssignedIdentityToInternalAssignedIdentity(*o)
+	list, err := c.assignedIDLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
 
-		// assigned identities names are unique across namespaces as we use pod name-<id ns>-<id name>
-		result[o.Name] = out
+	result := make(map[string]aadpodid.AzureAssignedIdentity)
+	for _, o := range list {
+		// assigned identities names are unique across namespaces as they include a hash of the pod, identity and binding
+		result[o.Name] = convertAssignedID(o)
 	}
 
 	stats.Update(stats.AssignedIDList, time.Since(begin))
@@ -460,24 +417,16 @@ func (c *Client) ListAssignedIDsInMap() (map[string]aadpodid.AzureAssignedIdenti
 func (c *Client) ListIds() (res *[]aadpodid.AzureIdentity, err error) {
 	begin := time.Now()
 
-	var resList []aadpodid.AzureIdentity
-
-	list := c.IDInformer.GetStore().List()
-	for _, id := range list {
-		o, ok := id.(*aadpodv1.AzureIdentity)
-		if !ok {
-			err := fmt.Errorf("could not cast %T to %s", id, aadpodv1.AzureIDResource)
-			klog.Error(err)
-			return nil, err
-		}
-		// Note: List items returned from cache have empty Kind and API version..
-		// Work around this issue since we need that for event recording to work.
-		o.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   aadpodv1.CRDGroup,
-			Version: aadpodv1.CRDVersion,
-			Kind:    reflect.TypeOf(*o).String()})
+	list, err := c.idLister.List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
 
+	resList := make([]aadpodid.AzureIdentity, 0, len(list))
+	for _, o := range list {
 		out := aadpodv1.ConvertV1IdentityToInternalIdentity(*o)
+		out.SetGroupVersionKind(aadpodv1.SchemeGroupVersion.WithKind("AzureIdentity"))
 
 		resList = append(resList, out)
 		klog.V(6).Infof("Appending Identity: %s/%s to list.", o.Namespace, o.Name)
@@ -491,51 +440,106 @@ func (c *Client) ListIds() (res *[]aadpodid.AzureIdentity, err error) {
 func (c *Client) ListPodIdentityExceptions(ns string) (res *[]aadpodid.AzurePodIdentityException, err error) {
 	begin := time.Now()
 
-	var resList []aadpodid.AzurePodIdentityException
+	list, err := c.podIdentityExceptionLister.AzurePodIdentityExceptions(ns).List(labels.Everything())
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
 
-	list := c.PodIdentityExceptionInformer.GetStore().List()
-	for _, binding := range list {
-		o, ok := binding.(*aadpodv1.AzurePodIdentityException)
-		if !ok {
-			err := fmt.Errorf("could not cast %T to %s", binding, aadpodid.AzureIdentityExceptionResource)
-			klog.Error(err)
-			return nil, err
-		}
-		if o.Namespace == ns {
-			// Note: List items returned from cache have empty Kind and API version..
-			// Work around this issue since we need that for event recording to work.
-			o.SetGroupVersionKind(schema.GroupVersionKind{
-				Group:   aadpodv1.CRDGroup,
-				Version: aadpodv1.CRDVersion,
-				Kind:    reflect.TypeOf(*o).String()})
-			out := aadpodv1.ConvertV1PodIdentityExceptionToInternalPodIdentityException(*o)
-
-			resList = append(resList, out)
-			klog.V(6).Infof("Appending exception: %s/%s to list.", o.Namespace, o.Name)
-		}
+	resList := make([]aadpodid.AzurePodIdentityException, 0, len(list))
+	for _, o := range list {
+		out := aadpodv1.ConvertV1PodIdentityExceptionToInternalPodIdentityException(*o)
+		out.SetGroupVersionKind(aadpodv1.SchemeGroupVersion.WithKind("AzurePodIdentityException"))
+
+		resList = append(resList, out)
+		klog.V(6).Infof("Appending exception: %s/%s to list.", o.Namespace, o.Name)
 	}
 
 	stats.Update(stats.ExceptionList, time.Since(begin))
 	return &resList, nil
 }
 
-// ListPodIds - given a pod with pod name space
-// returns a map with list of azure identities in each state
-func (c *Client) ListPodIds(podns, podname string) (map[string][]aadpodid.AzureIdentity, error) {
-	list, err := c.ListAssignedIDs()
+// convertAssignedID converts the cached assigned identity to the internal type
+// with its kind and api version set.
+func convertAssignedID(assignedID *aadpodv1.AzureAssignedIdentity) aadpodid.AzureAssignedIdentity {
+	out := aadpodv1.ConvertV1AssignedIdentityToInternalAssignedIdentity(*assignedID)
+	out.SetGroupVersionKind(aadpodv1.SchemeGroupVersion.WithKind("AzureAssignedIdentity"))
+	return out
+}
+
+// ListPodIds - given a pod with pod name space and uid
+// returns a map with list of azure identities in each state,
+// ordered by the weight of the matching binding
+func (c *Client) ListPodIds(podns, podname string, podUID types.UID) (map[string][]aadpodid.AzureIdentity, error) {
+	list, err := c.AssignedIDInformer.GetIndexer().ByIndex(assignedIDPodIndex, podKey(podns, podname))
 	if err != nil {
 		return nil, err
 	}
 
+	assignedIDs := make([]aadpodid.AzureAssignedIdentity, 0, len(list))
+	for _, assignedID := range list {
+		o, ok := assignedID.(*aadpodv1.AzureAssignedIdentity)
+		if !ok {
+			err := fmt.Errorf("could not cast %T to %s", assignedID, aadpodv1.AzureAssignedIDResource)
+			klog.Error(err)
+			return nil, err
+		}
+		// assigned identities of a deleted pod are not used by a new pod with the same name
+		if o.Spec.PodUID != "" && o.Spec.PodUID != podUID {
+			klog.V(2).Infof("Ignoring assigned id %s/%s of pod %s/%s with uid %s", o.Namespace, o.Name, podns, podname, o.Spec.PodUID)
+			continue
+		}
+		assignedIDs = append(assignedIDs, convertAssignedID(o))
+	}
+	sortAssignedIDsByWeight(assignedIDs)
+
 	idStateMap := make(map[string][]aadpodid.AzureIdentity)
-	for _, v := range *list {
-		if v.Spec.Pod == podname && v.Spec.PodNamespace == podns {
-			idStateMap[v.Status.Status] = append(idStateMap[v.Status.Status], *v.Spec.AzureIdentityRef)
+	for _, v := range assignedIDs {
+		if v.Spec.AzureIdentityRef == nil {
+			klog.Warningf("Ignoring assigned id %s/%s without identity reference", v.Namespace, v.Name)
+			continue
 		}
+		idStateMap[v.Status.Status] = append(idStateMap[v.Status.Status], *v.Spec.AzureIdentityRef)
 	}
 	return idStateMap, nil
 }
 
+// sortAssignedIDsByWeight orders the assigned identities by the weight of the
+// binding they were created for, highest first, and then by identity name so
+// the identity selected for a pod matched by multiple bindings is deterministic.
+// A missing binding reference counts as weight 0 and a missing identity reference
+// as an empty name.
+func sortAssignedIDsByWeight(assignedIDs []aadpodid.AzureAssignedIdentity) {
+	sort.SliceStable(assignedIDs, func(i, j int) bool {
+		wi, wj := bindingWeight(&assignedIDs[i]), bindingWeight(&assignedIDs[j])
+		if wi != wj {
+			return wi > wj
+		}
+		nsi, namei := identityKey(&assignedIDs[i])
+		nsj, namej := identityKey(&assignedIDs[j])
+		if namei != namej {
+			return namei < namej
+		}
+		return nsi < nsj
+	})
+}
+
+// bindingWeight returns the weight of the binding the assigned identity was created for
+func bindingWeight(assignedID *aadpodid.AzureAssignedIdentity) int {
+	if assignedID.Spec.AzureBindingRef == nil {
+		return 0
+	}
+	return assignedID.Spec.AzureBindingRef.Spec.Weight
+}
+
+// identityKey returns the namespace and name of the identity of the assigned identity
+func identityKey(assignedID *aadpodid.AzureAssignedIdentity) (namespace, name string) {
+	if assignedID.Spec.AzureIdentityRef == nil {
+		return "", ""
+	}
+	return assignedID.Spec.AzureIdentityRef.Namespace, assignedID.Spec.AzureIdentityRef.Name
+}
+
 type patchStatusOps struct {
 	Op    string      `json:"op"`
 	Path  string      `json:"path"`
@@ -552,25 +556,77 @@ func (c *Client) UpdateAzureAssignedIdentityStatus(assignedIdentity *aadpodid.Az
 		}
 	}()
 
-	ops := make([]patchStatusOps, 1)
-	ops[0].Op = "replace"
-	ops[0].Path = "/Status/status"
-	ops[0].Value = status
+	return c.patchAssignedIdentityStatus(assignedIdentity.Namespace, assignedIdentity.Name, status)
+}
 
-	patchBytes, err := json.Marshal(ops)
+// patchAssignedIdentityStatus sets the state in the status of the assigned identity. A merge
+// patch is used as assigned identities created before the status subresource have no status.
+func (c *Client) patchAssignedIdentityStatus(namespace, name, status string) error {
+	patch := map[string]interface{}{
+		"status": map[string]string{"status": status},
+	}
+	patchBytes, err := json.Marshal(patch)
 	if err != nil {
 		return err
 	}
 
 	begin := time.Now()
-	err = c.rest.
-		Patch(types.JSONPatchType).
-		Namespace(assignedIdentity.Namespace).
-		Resource("azureassignedidentities").
-		Name(assignedIdentity.Name).
-		Body(patchBytes).
-		Do().
-		Error()
-	klog.V(5).Infof("Patch of %s took: %v", assignedIdentity.Name, time.Since(begin))
+	_, err = c.clientSet.AadpodidentityV1().AzureAssignedIdentities(namespace).Patch(name, types.MergePatchType, patchBytes, "status")
+	klog.V(5).Infof("Patch of %s took: %v", name, time.Since(begin))
 	return err
 }
+
+// UpdateAzureIdentityStatus replaces the status of the AzureIdentity
+func (c *Client) UpdateAzureIdentityStatus(azureID *aadpodid.AzureIdentity, status aadpodid.AzureIdentityStatus) (err error) {
+	klog.V(5).Infof("Updating identity %s/%s status", azureID.Namespace, azureID.Name)
+
+	defer func() {
+		if err != nil {
+			c.reporter.ReportKubernetesAPIOperationError(metrics.UpdateAzureIdentityStatusOperationName)
+		}
+	}()
+
+	v1Status := aadpodv1.ConvertInternalIdentityToV1Identity(aadpodid.AzureIdentity{Status: status}).Status
+	patchBytes, err := statusPatch(v1Status)
+	if err != nil {
+		return err
+	}
+
+	begin := time.Now()
+	_, err = c.clientSet.AadpodidentityV1().AzureIdentities(azureID.Namespace).Patch(azureID.Name, types.JSONPatchType, patchBytes, "status")
+	klog.V(5).Infof("Patch of identity %s/%s status took: %v", azureID.Namespace, azureID.Name, time.Since(begin))
+	return err
+}
+
+// UpdateAzureIdentityBindingStatus replaces the status of the AzureIdentityBinding
+func (c *Client) UpdateAzureIdentityBindingStatus(binding *aadpodid.AzureIdentityBinding, status aadpodid.AzureIdentityBindingStatus) (err error) {
+	klog.V(5).Infof("Updating binding %s/%s status", binding.Namespace, binding.Name)
+
+	defer func() {
+		if err != nil {
+			c.reporter.ReportKubernetesAPIOperationError(metrics.UpdateAzureIdentityBindingStatusOperationName)
+		}
+	}()
+
+	v1Status := aadpodv1.ConvertInternalBindingToV1Binding(aadpodid.AzureIdentityBinding{Status: status}).Status
+	patchBytes, err := statusPatch(v1Status)
+	if err != nil {
+		return err
+	}
+
+	begin := time.Now()
+	_, err = c.clientSet.AadpodidentityV1().AzureIdentityBindings(binding.Namespace).Patch(binding.Name, types.JSONPatchType, patchBytes, "status")
+	klog.V(5).Infof("Patch of binding %s/%s status took: %v", binding.Namespace, binding.Name, time.Since(begin))
+	return err
+}
+
+// statusPatch returns the JSON patch replacing the whole status of a resource
+// through its status subresource. The resource may not have a status yet.
+func statusPatch(status interface{}) ([]byte, error) {
+	ops := make([]patchStatusOps, 1)
+	ops[0].Op = "add"
+	ops[0].Path = "/status"
+	ops[0].Value = status
+
+	return json.Marshal(ops)
+}
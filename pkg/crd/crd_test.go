@@ -1,11 +1,22 @@
 package crd
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 
 	internalaadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
 	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned/fake"
+	"github.com/Azure/aad-pod-identity/pkg/metrics"
 	api "k8s.io/api/core/v1"
 )
 
@@ -102,3 +113,282 @@ func (c *TestCrdClient) ListAssignedIDs() (res *[]aadpodid.AzureAssignedIdentity
 	}
 	return &assignedIDList, nil
 }
+
+func TestCreateAssignedIdentityStatus(t *testing.T) {
+	type request struct {
+		method, path, contentType, body string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(body)})
+		// the status is dropped on create like the API server does for resources with a status subresource
+		var assignedID aadpodid.AzureAssignedIdentity
+		if err := json.Unmarshal(body, &assignedID); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+		assignedID.Status = aadpodid.AzureAssignedIdentityStatus{}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&assignedID)
+	}))
+	defer server.Close()
+
+	clientSet, err := versioned.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	reporter, err := metrics.NewReporter()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c := &Client{clientSet: clientSet, reporter: reporter}
+
+	assignedID := &internalaadpodid.AzureAssignedIdentity{
+		ObjectMeta: v1.ObjectMeta{Name: "assignedid", Namespace: "default"},
+		Spec: internalaadpodid.AzureAssignedIdentitySpec{
+			AzureIdentityRef: &internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id"}},
+			AzureBindingRef:  &internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding"}},
+		},
+		Status: internalaadpodid.AzureAssignedIdentityStatus{Status: internalaadpodid.AssignedIDCreated},
+	}
+	if err := c.CreateAssignedIdentity(assignedID); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := c.UpdateAzureAssignedIdentityStatus(assignedID, internalaadpodid.AssignedIDAssigned); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	statusPath := "/apis/aadpodidentity.k8s.io/v1/namespaces/default/azureassignedidentities/assignedid/status"
+	expected := []request{
+		{"POST", "/apis/aadpodidentity.k8s.io/v1/namespaces/default/azureassignedidentities", "application/json", ""},
+		{"PATCH", statusPath, string(types.MergePatchType), `{"status":{"status":"Created"}}`},
+		{"PATCH", statusPath, string(types.MergePatchType), `{"status":{"status":"Assigned"}}`},
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("expected %d requests, got %+v", len(expected), requests)
+	}
+	for i, r := range expected {
+		if r.body == "" {
+			r.body = requests[i].body
+		}
+		if requests[i] != r {
+			t.Errorf("expected request %+v, got %+v", r, requests[i])
+		}
+	}
+}
+
+func TestLegacyAssignedIdentityStatus(t *testing.T) {
+	// an assigned identity created before the status subresource, with its state in Status
+	stored := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(`{
+		"apiVersion": "aadpodidentity.k8s.io/v1",
+		"kind": "AzureAssignedIdentity",
+		"metadata": {"name": "assignedid", "namespace": "default"},
+		"spec": {
+			"azureidentityref": {"metadata": {"name": "id", "namespace": "default"}},
+			"azurebindingref": {"metadata": {"name": "binding", "namespace": "default"}},
+			"pod": "pod", "podnamespace": "default", "nodename": "node"
+		},
+		"Status": {"status": "Assigned", "availableReplicas": 1}
+	}`), &stored); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	path := "/apis/aadpodidentity.k8s.io/v1/namespaces/default/azureassignedidentities/assignedid"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == path:
+		case r.Method == http.MethodPatch && r.URL.Path == path+"/status":
+			// like the API server, the status subresource only applies the status of the patch
+			var patch map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			status, _ := stored["status"].(map[string]interface{})
+			if status == nil {
+				status = map[string]interface{}{}
+			}
+			for k, v := range patch["status"].(map[string]interface{}) {
+				status[k] = v
+			}
+			stored["status"] = status
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stored)
+	}))
+	defer server.Close()
+
+	clientSet, err := versioned.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	reporter, err := metrics.NewReporter()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c := &Client{clientSet: clientSet, reporter: reporter}
+
+	getStatus := func() internalaadpodid.AzureAssignedIdentityStatus {
+		v1AssignedID, err := clientSet.AadpodidentityV1().AzureAssignedIdentities("default").Get("assignedid", v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return aadpodid.ConvertV1AssignedIdentityToInternalAssignedIdentity(*v1AssignedID).Status
+	}
+
+	status := getStatus()
+	if status.Status != internalaadpodid.AssignedIDAssigned || status.AvailableReplicas != 1 {
+		t.Fatalf("expected the legacy status to be read, got %+v", status)
+	}
+
+	assignedID := &internalaadpodid.AzureAssignedIdentity{
+		ObjectMeta: v1.ObjectMeta{Name: "assignedid", Namespace: "default"},
+		Status:     status,
+	}
+	if err := c.UpdateAzureAssignedIdentityStatus(assignedID, internalaadpodid.AssignedIDUnAssigned); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := stored["Status"]; !ok {
+		t.Errorf("expected the legacy status to be kept by the status subresource")
+	}
+	if status := getStatus(); status.Status != internalaadpodid.AssignedIDUnAssigned {
+		t.Errorf("expected status %s over the legacy status, got %+v", internalaadpodid.AssignedIDUnAssigned, status)
+	}
+}
+
+func TestCrdClientWithFakeClientSet(t *testing.T) {
+	assignedID := newTestAssignedID("assignedid", "default", "pod1", aadpodid.AssignedIDAssigned)
+	clientSet := fake.NewSimpleClientset(
+		&aadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id", Namespace: "default"}},
+		&aadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "default"}},
+		assignedID,
+	)
+
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	c, err := NewCRDClient(clientSet, eventCh)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	exit := make(chan struct{})
+	defer close(exit)
+	c.Start(exit)
+
+	bindings, err := c.ListBindings()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(*bindings) != 1 || (*bindings)[0].Name != "binding" || (*bindings)[0].Kind != "AzureIdentityBinding" {
+		t.Fatalf("expected binding with kind AzureIdentityBinding, got %+v", *bindings)
+	}
+	ids, err := c.ListIds()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(*ids) != 1 || (*ids)[0].Name != "id" || (*ids)[0].APIVersion != "aadpodidentity.k8s.io/v1" {
+		t.Fatalf("expected identity with api version aadpodidentity.k8s.io/v1, got %+v", *ids)
+	}
+	assignedIDs, err := c.ListAssignedIDsInMap()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, ok := assignedIDs["assignedid"]; !ok || len(assignedIDs) != 1 {
+		t.Fatalf("expected assignedid in %+v", assignedIDs)
+	}
+	// the kind is set on the returned objects and not on the objects in the cache
+	cached, err := c.assignedIDLister.AzureAssignedIdentities("default").Get("assignedid")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if cached.Kind != "" {
+		t.Fatalf("expected cached assigned identity to be unchanged, got kind %s", cached.Kind)
+	}
+
+	internalAssignedID := assignedIDs["assignedid"]
+	if err := c.RemoveAssignedIdentity(&internalAssignedID); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, err = clientSet.AadpodidentityV1().AzureAssignedIdentities("default").Get("assignedid", v1.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("expected assigned identity to be deleted, got %v", err)
+	}
+}
+
+func TestCrdClientLiteListPodIdentityExceptions(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		&aadpodid.AzurePodIdentityException{ObjectMeta: v1.ObjectMeta{Name: "exception", Namespace: "default"}},
+		&aadpodid.AzurePodIdentityException{ObjectMeta: v1.ObjectMeta{Name: "exception", Namespace: "other"}},
+	)
+
+	c, err := NewCRDClientLite(clientSet, "node1", false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	exit := make(chan struct{})
+	defer close(exit)
+	c.StartLite(exit)
+
+	exceptions, err := c.ListPodIdentityExceptions("default")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(*exceptions) != 1 || (*exceptions)[0].Namespace != "default" {
+		t.Fatalf("expected exception in namespace default, got %+v", *exceptions)
+	}
+}
+
+func TestRemoveAssignedIdentityFinalizer(t *testing.T) {
+	type request struct {
+		method, path, body string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, string(body)})
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodDelete {
+			// the assigned identity was deleted by the garbage collector once the finalizer was removed
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&v1.Status{Status: v1.StatusFailure, Reason: v1.StatusReasonNotFound, Code: http.StatusNotFound})
+			return
+		}
+		json.NewEncoder(w).Encode(&aadpodid.AzureAssignedIdentity{})
+	}))
+	defer server.Close()
+
+	clientSet, err := versioned.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	reporter, err := metrics.NewReporter()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	c := &Client{clientSet: clientSet, reporter: reporter}
+
+	assignedID := &internalaadpodid.AzureAssignedIdentity{
+		ObjectMeta: v1.ObjectMeta{Name: "assignedid", Namespace: "default", Finalizers: []string{internalaadpodid.AssignedIDFinalizer}},
+	}
+	if err := c.RemoveAssignedIdentity(assignedID); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	path := "/apis/aadpodidentity.k8s.io/v1/namespaces/default/azureassignedidentities/assignedid"
+	expected := []request{
+		{"PATCH", path, `{"metadata":{"finalizers":[]}}`},
+		{"DELETE", path, ""},
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("expected %d requests, got %+v", len(expected), requests)
+	}
+	for i, r := range expected {
+		if r.body == "" {
+			r.body = requests[i].body
+		}
+		if requests[i] != r {
+			t.Errorf("expected request %+v, got %+v", r, requests[i])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package crd
+
+import (
+	"fmt"
+	"sync"
+
+	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	// assignedIDPodIndex is the name of the assigned identity informer index
+	// on the namespace/name of the pod the identity is assigned to
+	assignedIDPodIndex = "pod"
+)
+
+func podKey(podns, podname string) string {
+	return podns + "/" + podname
+}
+
+// assignedIDPodIndexFunc indexes the assigned identities by pod namespace/name
+func assignedIDPodIndexFunc(obj interface{}) ([]string, error) {
+	assignedID, ok := obj.(*aadpodv1.AzureAssignedIdentity)
+	if !ok {
+		return nil, fmt.Errorf("could not cast %T to %s", obj, aadpodv1.AzureAssignedIDResource)
+	}
+	return []string{podKey(assignedID.Spec.PodNamespace, assignedID.Spec.Pod)}, nil
+}
+
+// podIDWatchers notifies the watchers of a pod every time one of the
+// identities assigned to the pod is added, updated or deleted
+type podIDWatchers struct {
+	mu       sync.Mutex
+	watchers map[string][]chan struct{}
+}
+
+// newPodIDWatchers returns the watchers notified by the assigned identity informer
+func newPodIDWatchers(assignedIDInformer cache.SharedInformer) *podIDWatchers {
+	w := &podIDWatchers{}
+	assignedIDInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: w.notify,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			w.notify(newObj)
+		},
+		DeleteFunc: w.notify,
+	})
+	return w
+}
+
+func (w *podIDWatchers) add(key string) chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.watchers == nil {
+		w.watchers = make(map[string][]chan struct{})
+	}
+	ch := make(chan struct{}, 1)
+	w.watchers[key] = append(w.watchers[key], ch)
+	return ch
+}
+
+func (w *podIDWatchers) remove(key string, ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	watchers := w.watchers[key]
+	for i := range watchers {
+		if watchers[i] == ch {
+			watchers = append(watchers[:i], watchers[i+1:]...)
+			break
+		}
+	}
+	if len(watchers) == 0 {
+		delete(w.watchers, key)
+		return
+	}
+	w.watchers[key] = watchers
+}
+
+func (w *podIDWatchers) notify(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	assignedID, ok := obj.(*aadpodv1.AzureAssignedIdentity)
+	if !ok {
+		klog.Errorf("could not cast %T to %s", obj, aadpodv1.AzureAssignedIDResource)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, ch := range w.watchers[podKey(assignedID.Spec.PodNamespace, assignedID.Spec.Pod)] {
+		// the watcher only needs to know the identities changed since it last listed them
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// WatchPodIds returns a channel that receives a notification every time an
+// identity assigned to the pod is added, updated or deleted. The returned
+// function stops the notifications and has to be called once done.
+func (c *Client) WatchPodIds(podns, podname string) (<-chan struct{}, func()) {
+	key := podKey(podns, podname)
+	ch := c.podIDWatchers.add(key)
+	return ch, func() {
+		c.podIDWatchers.remove(key, ch)
+	}
+}
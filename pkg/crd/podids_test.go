@@ -0,0 +1,170 @@
+package crd
+
+import (
+	"reflect"
+	"testing"
+
+	internalaadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestAssignedID(name, podns, podname, state string) *aadpodid.AzureAssignedIdentity {
+	return &aadpodid.AzureAssignedIdentity{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: aadpodid.AzureAssignedIdentitySpec{
+			AzureIdentityRef: &aadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: name}},
+			AzureBindingRef:  &aadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: name}},
+			Pod:              podname,
+			PodNamespace:     podns,
+		},
+		Status: aadpodid.AzureAssignedIdentityStatus{Status: state},
+	}
+}
+
+func TestListPodIds(t *testing.T) {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &aadpodid.AzureAssignedIdentity{}, 0,
+		cache.Indexers{assignedIDPodIndex: assignedIDPodIndexFunc})
+	c := &Client{AssignedIDInformer: informer, podIDWatchers: &podIDWatchers{}}
+
+	store := informer.GetStore()
+	store.Add(newTestAssignedID("id1", "default", "pod1", aadpodid.AssignedIDAssigned))
+	store.Add(newTestAssignedID("id2", "default", "pod1", aadpodid.AssignedIDCreated))
+	store.Add(newTestAssignedID("id3", "default", "pod2", aadpodid.AssignedIDAssigned))
+	store.Add(newTestAssignedID("id4", "other", "pod1", aadpodid.AssignedIDAssigned))
+
+	idStateMap, err := c.ListPodIds("default", "pod1", "")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if len(idStateMap[aadpodid.AssignedIDAssigned]) != 1 || idStateMap[aadpodid.AssignedIDAssigned][0].Name != "id1" {
+		t.Fatalf("expected id1 in Assigned state, got: %+v", idStateMap[aadpodid.AssignedIDAssigned])
+	}
+	if len(idStateMap[aadpodid.AssignedIDCreated]) != 1 || idStateMap[aadpodid.AssignedIDCreated][0].Name != "id2" {
+		t.Fatalf("expected id2 in Created state, got: %+v", idStateMap[aadpodid.AssignedIDCreated])
+	}
+}
+
+func TestListPodIdsPodUID(t *testing.T) {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &aadpodid.AzureAssignedIdentity{}, 0,
+		cache.Indexers{assignedIDPodIndex: assignedIDPodIndexFunc})
+	c := &Client{AssignedIDInformer: informer, podIDWatchers: &podIDWatchers{}}
+
+	for name, podUID := range map[string]types.UID{"current": "uid", "legacy": "", "deleted-pod": "old-uid"} {
+		assignedID := newTestAssignedID(name, "default", "pod1", aadpodid.AssignedIDAssigned)
+		assignedID.Spec.PodUID = podUID
+		informer.GetStore().Add(assignedID)
+	}
+
+	idStateMap, err := c.ListPodIds("default", "pod1", "uid")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	var names []string
+	for _, id := range idStateMap[aadpodid.AssignedIDAssigned] {
+		names = append(names, id.Name)
+	}
+	expected := []string{"current", "legacy"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected identities %v, got: %v", expected, names)
+	}
+}
+
+func TestWatchPodIds(t *testing.T) {
+	c := &Client{podIDWatchers: &podIDWatchers{}}
+
+	ch, stop := c.WatchPodIds("default", "pod1")
+	other, stopOther := c.WatchPodIds("default", "pod2")
+	defer stopOther()
+
+	// multiple notifications before the watcher lists the identities are coalesced
+	c.podIDWatchers.notify(newTestAssignedID("id1", "default", "pod1", aadpodid.AssignedIDCreated))
+	c.podIDWatchers.notify(cache.DeletedFinalStateUnknown{Obj: newTestAssignedID("id1", "default", "pod1", aadpodid.AssignedIDAssigned)})
+
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("expected notification for default/pod1")
+	}
+	select {
+	case <-ch:
+		t.Fatalf("expected notifications to be coalesced")
+	default:
+	}
+	select {
+	case <-other:
+		t.Fatalf("expected no notification for default/pod2")
+	default:
+	}
+
+	stop()
+	c.podIDWatchers.notify(newTestAssignedID("id1", "default", "pod1", aadpodid.AssignedIDAssigned))
+	select {
+	case <-ch:
+		t.Fatalf("expected no notification after the watch is stopped")
+	default:
+	}
+	if _, ok := c.podIDWatchers.watchers["default/pod1"]; ok {
+		t.Fatalf("expected watcher for default/pod1 to be removed")
+	}
+}
+
+func TestListPodIdsOrderedByWeight(t *testing.T) {
+	informer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &aadpodid.AzureAssignedIdentity{}, 0,
+		cache.Indexers{assignedIDPodIndex: assignedIDPodIndexFunc})
+	c := &Client{AssignedIDInformer: informer, podIDWatchers: &podIDWatchers{}}
+
+	for name, weight := range map[string]int{"id-a": 1, "id-b": 5, "id-c": 1, "id-d": 0} {
+		assignedID := newTestAssignedID(name, "default", "pod1", aadpodid.AssignedIDAssigned)
+		assignedID.Spec.AzureBindingRef.Spec.Weight = weight
+		informer.GetStore().Add(assignedID)
+	}
+
+	idStateMap, err := c.ListPodIds("default", "pod1", "")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	var names []string
+	for _, id := range idStateMap[aadpodid.AssignedIDAssigned] {
+		names = append(names, id.Name)
+	}
+	expected := []string{"id-b", "id-a", "id-c", "id-d"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected identities in order %v, got: %v", expected, names)
+	}
+}
+
+func TestSortAssignedIDsByWeightWithNilRefs(t *testing.T) {
+	newAssignedID := func(name string, weight int) internalaadpodid.AzureAssignedIdentity {
+		return internalaadpodid.AzureAssignedIdentity{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: internalaadpodid.AzureAssignedIdentitySpec{
+				AzureIdentityRef: &internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: name}},
+				AzureBindingRef: &internalaadpodid.AzureIdentityBinding{
+					Spec: internalaadpodid.AzureIdentityBindingSpec{Weight: weight},
+				},
+			},
+		}
+	}
+	noBinding := newAssignedID("no-binding", 0)
+	noBinding.Spec.AzureBindingRef = nil
+	noIdentity := newAssignedID("no-identity", 0)
+	noIdentity.Spec.AzureIdentityRef = nil
+
+	assignedIDs := []internalaadpodid.AzureAssignedIdentity{
+		newAssignedID("id-a", 0), noBinding, newAssignedID("id-b", 1), noIdentity,
+	}
+	sortAssignedIDsByWeight(assignedIDs)
+
+	var names []string
+	for _, assignedID := range assignedIDs {
+		names = append(names, assignedID.Name)
+	}
+	// missing binding references count as weight 0, missing identity references as an empty name
+	expected := []string{"id-b", "no-identity", "id-a", "no-binding"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected assigned ids in order %v, got: %v", expected, names)
+	}
+}
@@ -7,25 +7,33 @@ import (
 	"strings"
 	"time"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 
 	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned"
 	crd "github.com/Azure/aad-pod-identity/pkg/crd"
 	"github.com/Azure/aad-pod-identity/pkg/metrics"
 	"github.com/Azure/aad-pod-identity/version"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	informersv1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/informers/internalinterfaces"
 )
 
 const (
-	getPodListRetries               = 4
-	getPodListSleepTimeMilliseconds = 300
+	// getPodListTimeout is the duration to wait for the pod informer to observe
+	// the pod with the ip of the token request
+	getPodListTimeout = 1200 * time.Millisecond
+	// serviceAccountTokenExpirationSeconds is the requested validity of the
+	// service account tokens, which is the minimum allowed by the api server
+	serviceAccountTokenExpirationSeconds = 600
 )
 
 // Client api client
@@ -36,20 +44,38 @@ type Client interface {
 	GetPodInfo(podip string) (podns, podname, rsName string, selectors *metav1.LabelSelector, err error)
 	// ListPodIds pod matching azure identity or nil
 	ListPodIds(podns, podname string) (map[string][]aadpodid.AzureIdentity, error)
+	// WatchPodIds returns a channel notified when the identities assigned to the pod change
+	// and a function to stop the notifications
+	WatchPodIds(podns, podname string) (<-chan struct{}, func())
 	// GetSecret returns secret the secretRef represents
 	GetSecret(secretRef *v1.SecretReference) (*v1.Secret, error)
+	// GetServiceAccountName returns the name of the service account of the pod
+	GetServiceAccountName(podns, podname string) (string, error)
+	// GetServiceAccountToken returns a token for the service account of the pod, bound to the pod
+	GetServiceAccountToken(podns, podname string, audiences []string) (string, error)
 	// ListPodIdentityExceptions returns list of azurepodidentityexceptions
 	ListPodIdentityExceptions(namespace string) (*[]aadpodid.AzurePodIdentityException, error)
+	// AddAssignedIDDeleteHandler registers a handler invoked when an azureassignedidentity is deleted
+	AddAssignedIDDeleteHandler(handler func(assignedID *aadpodid.AzureAssignedIdentity))
+	// RecordIdentityEvent records an event for the azureidentity
+	RecordIdentityEvent(id *aadpodid.AzureIdentity, eventType, reason, message string)
+	// RecordPodEvent records an event for the pod
+	RecordPodEvent(podns, podname, eventType, reason, message string)
 }
 
 // KubeClient k8s client
 type KubeClient struct {
 	// Main Kubernetes client
 	ClientSet kubernetes.Interface
+	// Generated clientset used to access our CRD resources.
+	CrdClientSet versioned.Interface
 	// Crd client used to access our CRD resources.
 	CrdClient   *crd.Client
 	PodInformer cache.SharedIndexInformer
 	reporter    *metrics.Reporter
+	recorder    record.EventRecorder
+	// podIPWaiters are notified when the pod informer observes a pod ip
+	podIPWaiters podIPWaiters
 }
 
 // NewKubeClient new kubernetes api client
@@ -63,7 +89,11 @@ func NewKubeClient(nodeName string, scale bool) (Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	crdclient, err := crd.NewCRDClientLite(config, nodeName, scale)
+	crdclientset, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	crdclient, err := crd.NewCRDClientLite(crdclientset, nodeName, scale)
 	if err != nil {
 		return nil, err
 	}
@@ -72,16 +102,29 @@ func NewKubeClient(nodeName string, scale bool) (Client, error) {
 		return nil, err
 	}
 
-	podInformer := informersv1.NewFilteredPodInformer(clientset, v1.NamespaceAll, 10*time.Minute,
-		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
-		NodeNameFilter(nodeName))
+	// the pods are decoded from the raw pods to index them by all their ips
+	podInformer := cache.NewSharedIndexInformer(newPodListWatch(clientset.CoreV1().RESTClient(), NodeNameFilter(nodeName)),
+		&v1.Pod{}, 10*time.Minute,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc, podIPIndex: podIPIndexFunc})
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "nmi", Host: nodeName})
 
 	kubeClient := &KubeClient{
-		CrdClient:   crdclient,
-		ClientSet:   clientset,
-		PodInformer: podInformer,
-		reporter:    reporter,
+		CrdClientSet: crdclientset,
+		CrdClient:    crdclient,
+		ClientSet:    clientset,
+		PodInformer:  podInformer,
+		reporter:     reporter,
+		recorder:     recorder,
 	}
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: kubeClient.podIPWaiters.notify,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			kubeClient.podIPWaiters.notify(newObj)
+		},
+	})
 
 	return kubeClient, nil
 }
@@ -127,7 +170,7 @@ func (c *KubeClient) GetPodInfo(podip string) (podns, poddname, rsName string, l
 		return "", "", "", nil, fmt.Errorf("podip is empty")
 	}
 
-	podList, err := c.getPodListRetry(podip, getPodListRetries, getPodListSleepTimeMilliseconds)
+	podList, err := c.getPodListWithTimeout(podip, getPodListTimeout)
 
 	if err != nil {
 		return "", "", "", nil, err
@@ -145,9 +188,14 @@ func isPhaseValid(p v1.PodPhase) bool {
 	return p == v1.PodPending || p == v1.PodRunning
 }
 
+// getPodList returns the pods with the ip using the pod ip index
 func (c *KubeClient) getPodList(podip string) ([]*v1.Pod, error) {
 	var podList []*v1.Pod
-	list := c.PodInformer.GetStore().List()
+	list, err := c.PodInformer.GetIndexer().ByIndex(podIPIndex, podip)
+	if err != nil {
+		klog.Error(err)
+		return nil, err
+	}
 	for _, o := range list {
 		pod, ok := o.(*v1.Pod)
 		if !ok {
@@ -155,41 +203,46 @@ func (c *KubeClient) getPodList(podip string) ([]*v1.Pod, error) {
 			klog.Error(err)
 			return nil, err
 		}
-		if pod.Status.PodIP == podip && isPhaseValid(pod.Status.Phase) {
+		if isPhaseValid(pod.Status.Phase) {
 			podList = append(podList, pod)
 		}
 	}
 	if len(podList) == 0 {
-		err := fmt.Errorf("pod list empty")
-		klog.Error(err)
-		return nil, err
+		return nil, fmt.Errorf("pod list empty")
 	}
 	return podList, nil
 }
 
-func (c *KubeClient) getPodListRetry(podip string, retries int, sleeptime time.Duration) ([]*v1.Pod, error) {
-	var podList []*v1.Pod
-	var err error
-	i := 0
+// getPodListWithTimeout returns the pods with the ip. If the pod informer has
+// not observed the pod yet, it waits until it does or the timeout expires.
+func (c *KubeClient) getPodListWithTimeout(podip string, timeout time.Duration) ([]*v1.Pod, error) {
+	podList, err := c.getPodList(podip)
+	if err == nil {
+		return podList, nil
+	}
+
+	ch := c.podIPWaiters.add(podip)
+	defer c.podIPWaiters.remove(podip, ch)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
 	for {
-		// Atleast run the getpodlist once.
+		// check again as the pod could have been observed before the
+		// waiter was registered
 		podList, err = c.getPodList(podip)
 		if err == nil {
 			return podList, nil
 		}
-		c.reporter.ReportKubernetesAPIOperationError(metrics.GetPodListOperationName)
-
-		if i >= retries {
-			break
+		select {
+		case <-ch:
+			klog.V(5).Infof("pod informer observed pod with ip %s", podip)
+		case <-timer.C:
+			klog.Errorf("failed to get pod with ip %s after %v, err: %+v", podip, timeout, err)
+			c.reporter.ReportKubernetesAPIOperationError(metrics.GetPodListOperationName)
+			return nil, err
 		}
-		i++
-		klog.Warningf("List pod error: %+v. Retrying, attempt number: %d", err, i)
-		time.Sleep(sleeptime * time.Millisecond)
 	}
-	// We reach here only if there is an error and we have exhausted all retries.
-	// Return the last error
-	return nil, err
 }
 
 // GetLocalIP returns the non loopback local IP of the host
@@ -209,9 +262,26 @@ func GetLocalIP() (string, error) {
 	return "", fmt.Errorf("non loopback ip address not found")
 }
 
-// ListPodIds lists matching ids for pod or error
+// ListPodIds lists matching ids for pod or error. Only the assigned identities
+// created for the pod with the current uid of the pod are used.
 func (c *KubeClient) ListPodIds(podns, podname string) (map[string][]aadpodid.AzureIdentity, error) {
-	return c.CrdClient.ListPodIds(podns, podname)
+	obj, exists, err := c.PodInformer.GetIndexer().GetByKey(podns + "/" + podname)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("pod %s/%s not found", podns, podname)
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("could not cast %T to %s", obj, "v1.Pod")
+	}
+	return c.CrdClient.ListPodIds(podns, podname, pod.UID)
+}
+
+// WatchPodIds returns a channel notified when the identities assigned to the pod change
+func (c *KubeClient) WatchPodIds(podns, podname string) (<-chan struct{}, func()) {
+	return c.CrdClient.WatchPodIds(podns, podname)
 }
 
 // ListPodIdentityExceptions lists azurepodidentityexceptions
@@ -219,6 +289,11 @@ func (c *KubeClient) ListPodIdentityExceptions(ns string) (*[]aadpodid.AzurePodI
 	return c.CrdClient.ListPodIdentityExceptions(ns)
 }
 
+// AddAssignedIDDeleteHandler registers a handler invoked when an azureassignedidentity is deleted
+func (c *KubeClient) AddAssignedIDDeleteHandler(handler func(assignedID *aadpodid.AzureAssignedIdentity)) {
+	c.CrdClient.AddAssignedIDDeleteHandler(handler)
+}
+
 // GetSecret returns secret the secretRef represents
 func (c *KubeClient) GetSecret(secretRef *v1.SecretReference) (*v1.Secret, error) {
 	secret, err := c.ClientSet.CoreV1().Secrets(secretRef.Namespace).Get(secretRef.Name, metav1.GetOptions{})
@@ -229,6 +304,105 @@ func (c *KubeClient) GetSecret(secretRef *v1.SecretReference) (*v1.Secret, error
 	return secret, nil
 }
 
+// RecordIdentityEvent records an event for the azureidentity
+func (c *KubeClient) RecordIdentityEvent(id *aadpodid.AzureIdentity, eventType, reason, message string) {
+	if c.recorder == nil || id == nil {
+		return
+	}
+	// the identity is referenced directly as the internal type is not
+	// registered in the scheme used by the recorder
+	ref := &v1.ObjectReference{
+		Kind:       "AzureIdentity",
+		APIVersion: aadpodid.CRDGroup + "/" + aadpodid.CRDVersion,
+		Name:       id.Name,
+		Namespace:  id.Namespace,
+		UID:        id.UID,
+	}
+	c.recorder.Event(ref, eventType, reason, message)
+}
+
+// RecordPodEvent records an event for the pod
+func (c *KubeClient) RecordPodEvent(podns, podname, eventType, reason, message string) {
+	if c.recorder == nil {
+		return
+	}
+	obj, exists, err := c.PodInformer.GetStore().GetByKey(podns + "/" + podname)
+	if err != nil || !exists {
+		klog.Errorf("failed to get pod %s/%s to record event, exists: %v, err: %+v", podns, podname, exists, err)
+		return
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		klog.Errorf("could not cast %T to %s", obj, "v1.Pod")
+		return
+	}
+	c.recorder.Event(pod, eventType, reason, message)
+}
+
+// GetServiceAccountToken returns a token for the service account of the pod
+// using the TokenRequest API. The token is bound to the pod so it's no longer
+// valid once the pod is deleted. NMI is only allowed to create tokens in the
+// namespaces binding the federated role to its service account.
+func (c *KubeClient) GetServiceAccountToken(podns, podname string, audiences []string) (string, error) {
+	pod, err := c.getCachedPod(podns, podname)
+	if err != nil {
+		return "", err
+	}
+
+	serviceAccountName := getServiceAccountName(pod)
+	expirationSeconds := int64(serviceAccountTokenExpirationSeconds)
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &expirationSeconds,
+			BoundObjectRef: &authenticationv1.BoundObjectReference{
+				Kind:       "Pod",
+				APIVersion: "v1",
+				Name:       pod.Name,
+				UID:        pod.UID,
+			},
+		},
+	}
+	tokenRequest, err = c.ClientSet.CoreV1().ServiceAccounts(podns).CreateToken(serviceAccountName, tokenRequest)
+	if err != nil {
+		recordError(c.reporter, metrics.GetServiceAccountTokenOperationName)
+		return "", err
+	}
+	return tokenRequest.Status.Token, nil
+}
+
+// GetServiceAccountName returns the name of the service account of the pod
+func (c *KubeClient) GetServiceAccountName(podns, podname string) (string, error) {
+	pod, err := c.getCachedPod(podns, podname)
+	if err != nil {
+		return "", err
+	}
+	return getServiceAccountName(pod), nil
+}
+
+// getCachedPod returns the pod from the pod informer
+func (c *KubeClient) getCachedPod(podns, podname string) (*v1.Pod, error) {
+	obj, exists, err := c.PodInformer.GetStore().GetByKey(podns + "/" + podname)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("pod %s/%s not found", podns, podname)
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("could not cast %T to %s", obj, "v1.Pod")
+	}
+	return pod, nil
+}
+
+func getServiceAccountName(pod *v1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return pod.Spec.ServiceAccountName
+}
+
 func getkubeclient(config *rest.Config) (*kubernetes.Clientset, error) {
 	// creates the clientset
 	kubeClient, err := kubernetes.NewForConfig(config)
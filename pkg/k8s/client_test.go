@@ -11,14 +11,22 @@ import (
 	"sync"
 	"testing"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	fakerest "k8s.io/client-go/rest/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	fakecrd "github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned/fake"
+	"github.com/Azure/aad-pod-identity/pkg/crd"
 )
 
 func TestGetSecret(t *testing.T) {
@@ -44,6 +52,85 @@ func TestGetSecret(t *testing.T) {
 	}
 }
 
+func TestGetServiceAccountToken(t *testing.T) {
+	fakeClient := fake.NewSimpleClientset()
+	var tokenRequest *authenticationv1.TokenRequest
+	var serviceAccountName string
+	fakeClient.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		createAction := action.(k8stesting.CreateAction)
+		tokenRequest = createAction.GetObject().(*authenticationv1.TokenRequest)
+		serviceAccountName = createAction.(k8stesting.CreateActionImpl).Name
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: "token"}}, nil
+	})
+
+	podInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Pod{}, 0, cache.Indexers{})
+	podInformer.GetStore().Add(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "uid"},
+		Spec:       v1.PodSpec{ServiceAccountName: "sa"},
+	})
+	kubeClient := &KubeClient{ClientSet: fakeClient, PodInformer: podInformer}
+
+	token, err := kubeClient.GetServiceAccountToken("default", "pod", []string{"audience"})
+	if err != nil {
+		t.Fatalf("Error getting service account token: %v", err)
+	}
+	if token != "token" {
+		t.Fatalf("Incorrect token: %s", token)
+	}
+	if serviceAccountName != "sa" {
+		t.Fatalf("Incorrect service account name: %s", serviceAccountName)
+	}
+	if ref := tokenRequest.Spec.BoundObjectRef; ref == nil || ref.Kind != "Pod" || ref.Name != "pod" || ref.UID != "uid" {
+		t.Fatalf("Incorrect bound object reference: %+v", ref)
+	}
+	if len(tokenRequest.Spec.Audiences) != 1 || tokenRequest.Spec.Audiences[0] != "audience" {
+		t.Fatalf("Incorrect audiences: %v", tokenRequest.Spec.Audiences)
+	}
+
+	if _, err = kubeClient.GetServiceAccountToken("default", "notfound", []string{"audience"}); err == nil {
+		t.Fatal("Expected error getting service account token for missing pod")
+	}
+}
+
+func TestListPodIds(t *testing.T) {
+	newAssignedID := func(name string, podUID types.UID) *aadpodv1.AzureAssignedIdentity {
+		return &aadpodv1.AzureAssignedIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: aadpodv1.AzureAssignedIdentitySpec{
+				AzureIdentityRef: &aadpodv1.AzureIdentity{ObjectMeta: metav1.ObjectMeta{Name: name}},
+				AzureBindingRef:  &aadpodv1.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Name: name}},
+				Pod:              "pod",
+				PodNamespace:     "default",
+				PodUID:           podUID,
+			},
+			Status: aadpodv1.AzureAssignedIdentityStatus{Status: aadpodv1.AssignedIDAssigned},
+		}
+	}
+	crdClient, err := crd.NewCRDClientLite(fakecrd.NewSimpleClientset(newAssignedID("current", "uid"), newAssignedID("deleted-pod", "old-uid")), "node", false)
+	if err != nil {
+		t.Fatalf("Error creating crd client: %v", err)
+	}
+	exit := make(chan struct{})
+	defer close(exit)
+	crdClient.StartLite(exit)
+
+	podInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Pod{}, 0, cache.Indexers{})
+	podInformer.GetStore().Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", UID: "uid"}})
+	kubeClient := &KubeClient{CrdClient: crdClient, PodInformer: podInformer}
+
+	idStateMap, err := kubeClient.ListPodIds("default", "pod")
+	if err != nil {
+		t.Fatalf("Error listing pod ids: %v", err)
+	}
+	if ids := idStateMap[aadpodv1.AssignedIDAssigned]; len(ids) != 1 || ids[0].Name != "current" {
+		t.Fatalf("Expected only the identity assigned to the pod uid, got: %+v", ids)
+	}
+
+	if _, err = kubeClient.ListPodIds("default", "notfound"); err == nil {
+		t.Fatal("Expected error listing pod ids for missing pod")
+	}
+}
+
 type TestClientSet struct {
 	mu      *sync.Mutex
 	podList []v1.Pod
@@ -28,16 +28,46 @@ func (c *FakeClient) ListPodIds(podns, podname string) (map[string][]aadpodid.Az
 	return nil, nil
 }
 
+// WatchPodIds ...
+func (c *FakeClient) WatchPodIds(podns, podname string) (<-chan struct{}, func()) {
+	return nil, func() {}
+}
+
 // ListPodIdentityExceptions ...
 func (c *FakeClient) ListPodIdentityExceptions(ns string) (*[]aadpodid.AzurePodIdentityException, error) {
 	return nil, nil
 }
 
+// AddAssignedIDDeleteHandler ...
+func (c *FakeClient) AddAssignedIDDeleteHandler(handler func(assignedID *aadpodid.AzureAssignedIdentity)) {
+
+}
+
+// RecordIdentityEvent ...
+func (c *FakeClient) RecordIdentityEvent(id *aadpodid.AzureIdentity, eventType, reason, message string) {
+
+}
+
+// RecordPodEvent ...
+func (c *FakeClient) RecordPodEvent(podns, podname, eventType, reason, message string) {
+
+}
+
 // GetSecret returns secret the secretRef represents
 func (c *FakeClient) GetSecret(secretRef *v1.SecretReference) (*v1.Secret, error) {
 	return nil, nil
 }
 
+// GetServiceAccountName returns the default service account name
+func (c *FakeClient) GetServiceAccountName(podns, podname string) (string, error) {
+	return "default", nil
+}
+
+// GetServiceAccountToken returns a fake service account token
+func (c *FakeClient) GetServiceAccountToken(podns, podname string, audiences []string) (string, error) {
+	return "token", nil
+}
+
 // Start - for starting informer clients in the fake Client
 func (c *FakeClient) Start(exit <-chan struct{}) {
 
@@ -0,0 +1,235 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers/internalinterfaces"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// podIPIndex is the name of the pod informer index on pod ip
+	podIPIndex = "podIP"
+	// podIPsAnnotationKey holds the comma-separated ips of status.podIPs in the pods
+	// cached by nmi, since the pod type predates status.podIPs. It is only set on the
+	// cached pods, when they are decoded.
+	podIPsAnnotationKey = "aadpodidentity.k8s.io/pod-ips"
+)
+
+// podIPsStatus is the part of the pod holding the ips of a dual-stack pod, which is
+// read from the raw pod since the pod type does not have them
+type podIPsStatus struct {
+	Status struct {
+		PodIPs []struct {
+			IP string `json:"ip"`
+		} `json:"podIPs"`
+	} `json:"status"`
+}
+
+// podIPIndexFunc indexes the pods by every pod ip
+func podIPIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return nil, fmt.Errorf("could not cast %T to %s", obj, "v1.Pod")
+	}
+	return getPodIPs(pod), nil
+}
+
+// getPodIPs returns the ips of the pod: status.podIP and the ips of status.podIPs
+// recorded by decodePod
+func getPodIPs(pod *v1.Pod) []string {
+	var ips []string
+	if pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	for _, ip := range strings.Split(pod.Annotations[podIPsAnnotationKey], ",") {
+		if ip != "" && ip != pod.Status.PodIP {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// decodePod decodes the raw pod and records the ips of status.podIPs in the pod
+func decodePod(raw []byte) (*v1.Pod, error) {
+	pod := &v1.Pod{}
+	if err := json.Unmarshal(raw, pod); err != nil {
+		return nil, err
+	}
+	status := podIPsStatus{}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return nil, err
+	}
+
+	// the annotation is always replaced, so a pod cannot claim the ips of another pod
+	delete(pod.Annotations, podIPsAnnotationKey)
+	var ips []string
+	for _, podIP := range status.Status.PodIPs {
+		if podIP.IP != "" {
+			ips = append(ips, podIP.IP)
+		}
+	}
+	if len(ips) > 0 {
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[podIPsAnnotationKey] = strings.Join(ips, ",")
+	}
+	return pod, nil
+}
+
+// newPodListWatch returns the list watch of the pod informer, which decodes the
+// raw pods with decodePod so the pods can be indexed by all their ips
+func newPodListWatch(client rest.Interface, tweakListOptions internalinterfaces.TweakListOptionsFunc) *cache.ListWatch {
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			tweakListOptions(&options)
+			raw, err := client.Get().
+				Resource("pods").
+				VersionedParams(&options, scheme.ParameterCodec).
+				SetHeader("Accept", runtime.ContentTypeJSON).
+				DoRaw()
+			if err != nil {
+				return nil, err
+			}
+			rawList := struct {
+				Metadata metav1.ListMeta   `json:"metadata"`
+				Items    []json.RawMessage `json:"items"`
+			}{}
+			if err := json.Unmarshal(raw, &rawList); err != nil {
+				return nil, err
+			}
+			list := &v1.PodList{ListMeta: rawList.Metadata, Items: make([]v1.Pod, 0, len(rawList.Items))}
+			for _, item := range rawList.Items {
+				pod, err := decodePod(item)
+				if err != nil {
+					return nil, err
+				}
+				list.Items = append(list.Items, *pod)
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			tweakListOptions(&options)
+			options.Watch = true
+			stream, err := client.Get().
+				Resource("pods").
+				VersionedParams(&options, scheme.ParameterCodec).
+				SetHeader("Accept", runtime.ContentTypeJSON).
+				Stream()
+			if err != nil {
+				return nil, err
+			}
+			return watch.NewStreamWatcher(&podWatchDecoder{stream: stream, decoder: json.NewDecoder(stream)}), nil
+		},
+	}
+}
+
+// podWatchDecoder decodes the pod watch events with decodePod
+type podWatchDecoder struct {
+	stream  io.ReadCloser
+	decoder *json.Decoder
+}
+
+// Decode returns the next pod watch event
+func (d *podWatchDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	var event struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := d.decoder.Decode(&event); err != nil {
+		return "", nil, err
+	}
+	switch event.Type {
+	case watch.Added, watch.Modified, watch.Deleted:
+		pod, err := decodePod(event.Object)
+		if err != nil {
+			return "", nil, err
+		}
+		return event.Type, pod, nil
+	case watch.Error:
+		status := &metav1.Status{}
+		if err := json.Unmarshal(event.Object, status); err != nil {
+			return "", nil, err
+		}
+		return event.Type, status, nil
+	default:
+		return "", nil, fmt.Errorf("unexpected watch event type %q", event.Type)
+	}
+}
+
+// Close closes the watch stream
+func (d *podWatchDecoder) Close() {
+	d.stream.Close()
+}
+
+// podIPWaiters notifies the token requests waiting for a pod ip to be
+// observed by the pod informer
+type podIPWaiters struct {
+	mu      sync.Mutex
+	waiters map[string][]chan struct{}
+}
+
+// add registers a waiter for the pod ip. The returned channel receives
+// a notification every time a pod with the ip is added or updated.
+func (w *podIPWaiters) add(podip string) chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.waiters == nil {
+		w.waiters = make(map[string][]chan struct{})
+	}
+	ch := make(chan struct{}, 1)
+	w.waiters[podip] = append(w.waiters[podip], ch)
+	return ch
+}
+
+// remove unregisters the waiter for the pod ip
+func (w *podIPWaiters) remove(podip string, ch chan struct{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	waiters := w.waiters[podip]
+	for i := range waiters {
+		if waiters[i] == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(w.waiters, podip)
+		return
+	}
+	w.waiters[podip] = waiters
+}
+
+// notify wakes up the waiters for the ips of the pod
+func (w *podIPWaiters) notify(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, podip := range getPodIPs(pod) {
+		for _, ch := range w.waiters[podip] {
+			// the waiter only needs to know the pod changed since it last checked
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
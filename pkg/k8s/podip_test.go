@@ -0,0 +1,196 @@
+package k8s
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/aad-pod-identity/pkg/metrics"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestPod(name, ip string, phase v1.PodPhase) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     v1.PodStatus{PodIP: ip, Phase: phase},
+	}
+}
+
+func newTestKubeClient(t *testing.T) *KubeClient {
+	reporter, err := metrics.NewReporter()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	podInformer := cache.NewSharedIndexInformer(&cache.ListWatch{}, &v1.Pod{}, 0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc, podIPIndex: podIPIndexFunc})
+	return &KubeClient{PodInformer: podInformer, reporter: reporter}
+}
+
+func TestGetPodListByIP(t *testing.T) {
+	c := newTestKubeClient(t)
+	store := c.PodInformer.GetStore()
+	store.Add(newTestPod("pod1", "10.0.0.1", v1.PodRunning))
+	store.Add(newTestPod("pod2", "10.0.0.2", v1.PodPending))
+	store.Add(newTestPod("pod3", "10.0.0.3", v1.PodSucceeded))
+	store.Add(newTestPod("pod4", "", v1.PodPending))
+
+	podList, err := c.getPodList("10.0.0.2")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if len(podList) != 1 || podList[0].Name != "pod2" {
+		t.Fatalf("expected pod2, got: %+v", podList)
+	}
+
+	// completed pods are ignored
+	if _, err = c.getPodList("10.0.0.3"); err == nil {
+		t.Fatal("expected error for completed pod")
+	}
+
+	// pod ip is reused after the pod is updated
+	store.Update(newTestPod("pod1", "10.0.0.3", v1.PodRunning))
+	if _, err = c.getPodList("10.0.0.1"); err == nil {
+		t.Fatal("expected error for stale pod ip")
+	}
+	if podList, err = c.getPodList("10.0.0.3"); err != nil || len(podList) != 1 || podList[0].Name != "pod1" {
+		t.Fatalf("expected pod1, got: %+v, err: %+v", podList, err)
+	}
+}
+
+func TestGetPodListWithTimeout(t *testing.T) {
+	c := newTestKubeClient(t)
+	pod := newTestPod("pod1", "10.0.0.1", v1.PodRunning)
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		c.PodInformer.GetStore().Add(pod)
+		c.podIPWaiters.notify(pod)
+	})
+
+	start := time.Now()
+	podList, err := c.getPodListWithTimeout("10.0.0.1", 10*time.Second)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if len(podList) != 1 || podList[0].Name != "pod1" {
+		t.Fatalf("expected pod1, got: %+v", podList)
+	}
+	// the pod should be returned as soon as it is observed
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected pod to be returned once observed, elapsed: %v", elapsed)
+	}
+	if len(c.podIPWaiters.waiters) != 0 {
+		t.Fatalf("expected waiters to be removed, got: %d", len(c.podIPWaiters.waiters))
+	}
+
+	if _, err = c.getPodListWithTimeout("10.0.0.2", 100*time.Millisecond); err == nil {
+		t.Fatal("expected error for missing pod")
+	}
+}
+
+const testDualStackPod = `{"metadata":{"name":"pod1","namespace":"default","annotations":{"aadpodidentity.k8s.io/pod-ips":"10.0.0.9"}},
+	"status":{"phase":"Running","podIP":"10.0.0.1","podIPs":[{"ip":"10.0.0.1"},{"ip":"fd00::1"}]}}`
+
+func TestDecodePodIPs(t *testing.T) {
+	pod, err := decodePod([]byte(testDualStackPod))
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	// the annotation of the pod is replaced by the ips of its status
+	if ips := getPodIPs(pod); !reflect.DeepEqual(ips, []string{"10.0.0.1", "fd00::1"}) {
+		t.Fatalf("expected ips 10.0.0.1 and fd00::1, got: %v", ips)
+	}
+
+	pod, err = decodePod([]byte(`{"metadata":{"name":"pod2","annotations":{"aadpodidentity.k8s.io/pod-ips":"10.0.0.9"}},"status":{"podIP":"10.0.0.2"}}`))
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if ips := getPodIPs(pod); !reflect.DeepEqual(ips, []string{"10.0.0.2"}) {
+		t.Fatalf("expected ip 10.0.0.2, got: %v", ips)
+	}
+}
+
+func TestGetPodListByDualStackIP(t *testing.T) {
+	c := newTestKubeClient(t)
+	pod, err := decodePod([]byte(testDualStackPod))
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	c.PodInformer.GetStore().Add(pod)
+
+	for _, ip := range []string{"10.0.0.1", "fd00::1"} {
+		podList, err := c.getPodList(ip)
+		if err != nil || len(podList) != 1 || podList[0].Name != "pod1" {
+			t.Fatalf("expected pod1 for ip %s, got: %+v, err: %+v", ip, podList, err)
+		}
+	}
+	if _, err = c.getPodList("10.0.0.9"); err == nil {
+		t.Fatal("expected error for the ip of the pod annotation")
+	}
+}
+
+func TestPodListWatch(t *testing.T) {
+	var fieldSelectors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fieldSelectors = append(fieldSelectors, r.URL.Query().Get("fieldSelector"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			fmt.Fprintf(w, `{"type":"MODIFIED","object":%s}`, testDualStackPod)
+			return
+		}
+		fmt.Fprintf(w, `{"metadata":{"resourceVersion":"1"},"items":[%s]}`, testDualStackPod)
+	}))
+	defer server.Close()
+
+	client, err := rest.RESTClientFor(&rest.Config{
+		Host:    server.URL,
+		APIPath: "/api",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion:         &v1.SchemeGroupVersion,
+			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: scheme.Codecs},
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	lw := newPodListWatch(client, NodeNameFilter("node1"))
+
+	obj, err := lw.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	list, ok := obj.(*v1.PodList)
+	if !ok || list.ResourceVersion != "1" || len(list.Items) != 1 {
+		t.Fatalf("expected a list of 1 pod at resource version 1, got: %+v", obj)
+	}
+	if ips := getPodIPs(&list.Items[0]); !reflect.DeepEqual(ips, []string{"10.0.0.1", "fd00::1"}) {
+		t.Fatalf("expected listed pod ips 10.0.0.1 and fd00::1, got: %v", ips)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: "1"})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	defer w.Stop()
+	select {
+	case event := <-w.ResultChan():
+		pod, ok := event.Object.(*v1.Pod)
+		if event.Type != watch.Modified || !ok || !reflect.DeepEqual(getPodIPs(pod), []string{"10.0.0.1", "fd00::1"}) {
+			t.Fatalf("expected modified pod with ips 10.0.0.1 and fd00::1, got: %+v", event)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the watch event")
+	}
+
+	if !reflect.DeepEqual(fieldSelectors, []string{"spec.nodeName=node1", "spec.nodeName=node1"}) {
+		t.Fatalf("expected the pods of node1 to be listed and watched, got field selectors: %v", fieldSelectors)
+	}
+}
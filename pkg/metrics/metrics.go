@@ -24,9 +24,13 @@ const (
 	micNewLeaderElectionCountName          = "mic_new_leader_election_count"
 	cloudProviderOperationsErrorsCountName = "cloud_provider_operations_errors_count"
 	cloudProviderOperationsDurationName    = "cloud_provider_operations_duration_seconds"
+	cloudProviderThrottledCountName        = "cloud_provider_throttled_count"
+	cloudProviderRateLimitedCountName      = "cloud_provider_rate_limited_count"
 	kubernetesAPIOperationsErrorsCountName = "kubernetes_api_operations_errors_count"
 	imdsOperationsErrorsCountName          = "imds_operations_errors_count"
 	imdsOperationsDurationName             = "imds_operations_duration_seconds"
+	nmiTokenCacheHitCountName              = "nmi_token_cache_hit_count"
+	nmiTokenCacheMissCountName             = "nmi_token_cache_miss_count"
 
 	// AdalTokenFromMSIOperationName ...
 	AdalTokenFromMSIOperationName = "adal_token_msi"
@@ -34,6 +38,8 @@ const (
 	AdalTokenFromMSIWithUserAssignedIDOperationName = "adal_token_msi_userassignedid"
 	// AdalTokenOperationName ...
 	AdalTokenOperationName = "adal_token"
+	// AdalTokenWithClientAssertionOperationName ...
+	AdalTokenWithClientAssertionOperationName = "adal_token_client_assertion"
 	// GetVmssOperationName ...
 	GetVmssOperationName = "vmss_get"
 	// PutVmssOperationName ...
@@ -42,16 +48,26 @@ const (
 	GetVMOperationName = "vm_get"
 	// PutVMOperationName ...
 	PutVMOperationName = "vm_create_or_update"
+	// UpdateVmssOperationName ...
+	UpdateVmssOperationName = "vmss_update"
+	// UpdateVMOperationName ...
+	UpdateVMOperationName = "vm_update"
 	// AssignedIdentityDeletionOperationName ...
 	AssignedIdentityDeletionOperationName = "assigned_identity_deletion"
 	// AssignedIdentityAdditionOperationName ...
 	AssignedIdentityAdditionOperationName = "assigned_identity_addition"
 	// UpdateAzureAssignedIdentityStatusOperationName ...
 	UpdateAzureAssignedIdentityStatusOperationName = "update_azure_assigned_identity_status"
+	// UpdateAzureIdentityStatusOperationName ...
+	UpdateAzureIdentityStatusOperationName = "update_azure_identity_status"
+	// UpdateAzureIdentityBindingStatusOperationName ...
+	UpdateAzureIdentityBindingStatusOperationName = "update_azure_identity_binding_status"
 	// GetPodListOperationName
 	GetPodListOperationName = "get_pod_list"
 	// GetSecretOperationName
 	GetSecretOperationName = "get_secret"
+	// GetServiceAccountTokenOperationName ...
+	GetServiceAccountTokenOperationName = "get_service_account_token"
 )
 
 // The following variables are measures
@@ -115,6 +131,18 @@ var (
 		"Duration in seconds of cloudprovider operations",
 		stats.UnitMilliseconds)
 
+	// CloudProviderThrottledCountM is a measure that tracks the cumulative number of cloud provider operations throttled by Azure Resource Manager.
+	CloudProviderThrottledCountM = stats.Int64(
+		cloudProviderThrottledCountName,
+		"Total number of cloud provider operations throttled by Azure Resource Manager",
+		stats.UnitDimensionless)
+
+	// CloudProviderRateLimitedCountM is a measure that tracks the cumulative number of cloud provider operations delayed or skipped by the client-side rate limiter and backoff.
+	CloudProviderRateLimitedCountM = stats.Int64(
+		cloudProviderRateLimitedCountName,
+		"Total number of cloud provider operations delayed or skipped by client-side rate limiting and backoff",
+		stats.UnitDimensionless)
+
 	// KubernetesAPIOperationsErrorsCountM is a measure that tracks the cumulative number of errors in cloud provider operations.
 	KubernetesAPIOperationsErrorsCountM = stats.Int64(
 		kubernetesAPIOperationsErrorsCountName,
@@ -132,6 +160,18 @@ var (
 		imdsOperationsDurationName,
 		"Duration in seconds of imds token operations",
 		stats.UnitMilliseconds)
+
+	// NMITokenCacheHitCountM is a measure that tracks the cumulative number of tokens served from the nmi token cache.
+	NMITokenCacheHitCountM = stats.Int64(
+		nmiTokenCacheHitCountName,
+		"Total number of tokens served from the nmi token cache",
+		stats.UnitDimensionless)
+
+	// NMITokenCacheMissCountM is a measure that tracks the cumulative number of token requests not served from the nmi token cache.
+	NMITokenCacheMissCountM = stats.Int64(
+		nmiTokenCacheMissCountName,
+		"Total number of token requests that missed the nmi token cache",
+		stats.UnitDimensionless)
 )
 
 var (
@@ -204,6 +244,18 @@ func registerViews() error {
 			Aggregation: view.Distribution(0.5, 1, 5, 10, 30, 60, 120, 300, 600, 900, 1200),
 			TagKeys:     []tag.Key{operationTypeKey},
 		},
+		&view.View{
+			Description: CloudProviderThrottledCountM.Description(),
+			Measure:     CloudProviderThrottledCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{operationTypeKey},
+		},
+		&view.View{
+			Description: CloudProviderRateLimitedCountM.Description(),
+			Measure:     CloudProviderRateLimitedCountM,
+			Aggregation: view.Count(),
+			TagKeys:     []tag.Key{operationTypeKey},
+		},
 		&view.View{
 			Description: KubernetesAPIOperationsErrorsCountM.Description(),
 			Measure:     KubernetesAPIOperationsErrorsCountM,
@@ -222,6 +274,16 @@ func registerViews() error {
 			Aggregation: view.Distribution(0.01, 0.02, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1, 2, 3, 4, 5, 10),
 			TagKeys:     []tag.Key{operationTypeKey},
 		},
+		&view.View{
+			Description: NMITokenCacheHitCountM.Description(),
+			Measure:     NMITokenCacheHitCountM,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Description: NMITokenCacheMissCountM.Description(),
+			Measure:     NMITokenCacheMissCountM,
+			Aggregation: view.Count(),
+		},
 	}
 	err := view.Register(views...)
 	return err
@@ -332,6 +394,16 @@ func (r *Reporter) ReportCloudProviderOperationDuration(operation string, durati
 	return r.ReportOperation(operation, CloudProviderOperationsDurationM.M(duration.Seconds()))
 }
 
+// ReportCloudProviderThrottled reports cloud provider operation throttled count
+func (r *Reporter) ReportCloudProviderThrottled(operation string) error {
+	return r.ReportOperation(operation, CloudProviderThrottledCountM.M(1))
+}
+
+// ReportCloudProviderRateLimited reports cloud provider operation rate limited count
+func (r *Reporter) ReportCloudProviderRateLimited(operation string) error {
+	return r.ReportOperation(operation, CloudProviderRateLimitedCountM.M(1))
+}
+
 // ReportKubernetesAPIOperationError reports kubernetes operation error count
 func (r *Reporter) ReportKubernetesAPIOperationError(operation string) error {
 	return r.ReportOperation(operation, KubernetesAPIOperationsErrorsCountM.M(1))
@@ -20,6 +20,8 @@ func TestBasicAndDurationReport(t *testing.T) {
 	testCounterMetric(t, reporter, MICNewLeaderElectionCountM)
 	testCounterMetric(t, reporter, CloudProviderOperationsErrorsCountM)
 	testCounterMetric(t, reporter, KubernetesAPIOperationsErrorsCountM)
+	testCounterMetric(t, reporter, NMITokenCacheHitCountM)
+	testCounterMetric(t, reporter, NMITokenCacheMissCountM)
 	testOperationDurationMetric(t, reporter, CloudProviderOperationsDurationM)
 	testOperationDurationMetric(t, reporter, NMIOperationsDurationM)
 }
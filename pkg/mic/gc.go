@@ -0,0 +1,188 @@
+package mic
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// gcKeyPrefix prefixes the work queue keys of the garbage collection. The garbage
+// collection of a node or vmss is keyed by the prefix followed by its node key, and
+// gcKey lists the nodes and vmss to garbage collect.
+const (
+	gcKeyPrefix = "gc/"
+	gcKey       = gcKeyPrefix + fullSyncKey
+)
+
+// GarbageCollectionConfig - used to configure the garbage collection of the user
+// assigned identities left on the nodes and vmss.
+type GarbageCollectionConfig struct {
+	// Interval is the interval between garbage collections, 0 disables them
+	Interval time.Duration
+	// QPS is the maximum number of nodes or vmss garbage collected per second
+	QPS float64
+	// ReportOnly logs the identities that would be removed instead of removing them
+	ReportOnly bool
+}
+
+// enqueueAfter queues the key for reconciliation after the delay. Keys queued while
+// not syncing are ignored.
+func (c *Client) enqueueAfter(key string, delay time.Duration) {
+	c.queueLock.RLock()
+	defer c.queueLock.RUnlock()
+	if c.queue == nil {
+		return
+	}
+	c.queue.AddAfter(key, delay)
+}
+
+// collectGarbage queues the garbage collection of every node and vmss of the cluster,
+// spaced out to stay within the garbage collection QPS.
+func (c *Client) collectGarbage() error {
+	nodes, err := c.NodeClient.List()
+	if err != nil {
+		return err
+	}
+	nodeKeys := make(map[string]bool)
+	for _, node := range nodes {
+		nodeKeys[c.getNodeKey(node.Name)] = true
+	}
+
+	keys := make([]string, 0, len(nodeKeys))
+	for key := range nodeKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// forget the identities seen on the nodes or vmss that were removed
+	for key := range c.gcCandidates {
+		if !nodeKeys[key] {
+			delete(c.gcCandidates, key)
+		}
+	}
+
+	var interval time.Duration
+	if c.gc.QPS > 0 {
+		interval = time.Duration(float64(time.Second) / c.gc.QPS)
+	}
+	klog.V(2).Infof("Garbage collecting user assigned identities of %d nodes or vmss", len(keys))
+	for i, key := range keys {
+		c.enqueueAfter(gcKeyPrefix+key, time.Duration(i)*interval)
+	}
+	return nil
+}
+
+// collectNodeGarbage removes the user assigned identities of pod identity left on the
+// node or vmss with the node key. An identity is only removed when it is still left
+// on the node or vmss at the next garbage collection, so identities assigned by a sync
+// whose assigned identity is not yet in the cache are not removed.
+func (c *Client) collectNodeGarbage(key string) error {
+	name, isvmss := key, false
+	if strings.HasPrefix(key, vmssKeyPrefix) {
+		name, isvmss = getVMSSName(strings.TrimPrefix(key, vmssKeyPrefix)), true
+	}
+
+	listIDs, err := c.CRDClient.ListIds()
+	if err != nil {
+		return err
+	}
+	assignedIDs, err := c.CRDClient.ListAssignedIDsInMap()
+	if err != nil {
+		return err
+	}
+
+	// only the identities of pod identity are removed. They are matched by their
+	// lower case resource id to their client id.
+	clientIDs := make(map[string]string)
+	if listIDs != nil {
+		for i := range *listIDs {
+			id := &(*listIDs)[i]
+			if c.checkIfUserAssignedMSI(id) {
+				clientIDs[strings.ToLower(id.Spec.ResourceID)] = id.Spec.ClientID
+			}
+		}
+	}
+	inUse := make(map[string]bool)
+	for _, assignedID := range assignedIDs {
+		id := assignedID.Spec.AzureIdentityRef
+		if id == nil || !c.checkIfUserAssignedMSI(id) {
+			continue
+		}
+		resourceID := strings.ToLower(id.Spec.ResourceID)
+		clientIDs[resourceID] = id.Spec.ClientID
+		// assigned identities in any state keep their identity, they are removed by the sync
+		if c.getNodeKey(assignedID.Spec.NodeName) == key {
+			inUse[resourceID] = true
+		}
+	}
+
+	idList, err := c.getUserMSIListForNode(name, isvmss)
+	if err != nil {
+		return err
+	}
+
+	candidates := make(map[string]bool)
+	var removeList []string
+	for _, resourceID := range idList {
+		lowerResourceID := strings.ToLower(resourceID)
+		clientID, ok := clientIDs[lowerResourceID]
+		if !ok || inUse[lowerResourceID] || c.checkIfIdentityImmutable(strings.ToLower(clientID)) || c.checkIfKubeletIdentity(clientID) {
+			continue
+		}
+		candidates[lowerResourceID] = true
+		if !c.gcCandidates[key][lowerResourceID] {
+			klog.Infof("Identity %s on %s is not assigned to any pod, it will be removed if still unassigned at the next garbage collection", resourceID, name)
+			continue
+		}
+		removeList = append(removeList, resourceID)
+	}
+	if len(candidates) > 0 {
+		c.gcCandidates[key] = candidates
+	} else {
+		delete(c.gcCandidates, key)
+	}
+
+	if len(removeList) == 0 {
+		return nil
+	}
+	if c.gc.ReportOnly || c.dryRun {
+		for _, resourceID := range removeList {
+			klog.Infof("[report only] would remove identity %s not assigned to any pod from %s", resourceID, name)
+		}
+		return nil
+	}
+	klog.Infof("Removing %d identities not assigned to any pod from %s: %v", len(removeList), name, removeList)
+	if err := c.CloudClient.UpdateUserMSI(nil, removeList, name, isvmss); err != nil {
+		return err
+	}
+	delete(c.gcCandidates, key)
+	return nil
+}
+
+// checkIfKubeletIdentity checks if the client id is the client id of the kubelet identity,
+// which is never removed from the nodes or vmss
+func (c *Client) checkIfKubeletIdentity(clientID string) bool {
+	return c.kubeletIdentityClientID != "" && strings.EqualFold(clientID, c.kubeletIdentityClientID)
+}
+
+// syncGarbageKey runs the garbage collection with the work queue key. Failures are
+// only logged, since the next garbage collection retries them.
+func (c *Client) syncGarbageKey(key string) {
+	nodeKey := strings.TrimPrefix(key, gcKeyPrefix)
+	if nodeKey == fullSyncKey {
+		if err := c.collectGarbage(); err != nil {
+			klog.Errorf("failed to list the nodes to garbage collect. Error: %v", err)
+		}
+		return
+	}
+	if err := c.collectNodeGarbage(nodeKey); err != nil {
+		klog.Errorf("failed to garbage collect the identities of %s. Error: %v", nodeKey, err)
+	}
+}
+
+// isGarbageKey returns true if the work queue key is a garbage collection key
+func isGarbageKey(key string) bool {
+	return strings.HasPrefix(key, gcKeyPrefix)
+}
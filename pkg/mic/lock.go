@@ -0,0 +1,277 @@
+package mic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog"
+)
+
+const (
+	// LeasesResourceLock is the lock type of the leader election using a coordination.k8s.io Lease
+	LeasesResourceLock = "leases"
+
+	contentTypeJSON = "application/json"
+)
+
+var errLockReleased = errors.New("leader election lock released")
+
+// errLostLeaderLease is returned by Run once the leader lease is lost
+var errLostLeaderLease = errors.New("lost leader lease")
+
+var leaseGroupVersion = schema.GroupVersion{Group: "coordination.k8s.io", Version: "v1"}
+
+// newResourceLock returns the leader election lock of the lock type, which is
+// either an endpoints, a configmaps or a leases lock.
+func newResourceLock(lockType, namespace, name string, clientSet kubernetes.Interface, config *rest.Config, lockConfig resourcelock.ResourceLockConfig) (resourcelock.Interface, error) {
+	if lockType != LeasesResourceLock {
+		return resourcelock.New(lockType, namespace, name, clientSet.CoreV1(), lockConfig)
+	}
+
+	leaseConfig := rest.CopyConfig(config)
+	leaseConfig.GroupVersion = &leaseGroupVersion
+	leaseConfig.APIPath = "/apis"
+	leaseConfig.ContentType = contentTypeJSON
+	leaseConfig.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
+	client, err := rest.RESTClientFor(leaseConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &leaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Client:     client,
+		LockConfig: lockConfig,
+	}, nil
+}
+
+// lease is the subset of a coordination.k8s.io/v1 Lease used by the leader election
+type lease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              leaseSpec `json:"spec,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       *string           `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32            `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *metav1.MicroTime `json:"acquireTime,omitempty"`
+	RenewTime            *metav1.MicroTime `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32            `json:"leaseTransitions,omitempty"`
+}
+
+// leaseLock is a leader election lock stored in a coordination.k8s.io/v1 Lease,
+// which unlike endpoints and configmaps is not watched by other components.
+type leaseLock struct {
+	// LeaseMeta should contain a Name and a Namespace of the Lease
+	// that the LeaderElector will attempt to lead.
+	LeaseMeta  metav1.ObjectMeta
+	Client     rest.Interface
+	LockConfig resourcelock.ResourceLockConfig
+	lease      *lease
+}
+
+// Get returns the election record from the Lease spec
+func (ll *leaseLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	body, err := ll.Client.Get().Namespace(ll.LeaseMeta.Namespace).Resource("leases").Name(ll.LeaseMeta.Name).Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	l := &lease{}
+	if err := json.Unmarshal(body, l); err != nil {
+		return nil, err
+	}
+	ll.lease = l
+	return leaseSpecToRecord(&l.Spec), nil
+}
+
+// Create attempts to create a Lease with the election record
+func (ll *leaseLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	return ll.write(ll.Client.Post().Namespace(ll.LeaseMeta.Namespace).Resource("leases"), &lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ll.LeaseMeta.Name,
+			Namespace: ll.LeaseMeta.Namespace,
+		},
+		Spec: recordToLeaseSpec(&ler),
+	})
+}
+
+// Update will update the existing Lease with the election record
+func (ll *leaseLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if ll.lease == nil {
+		return errors.New("lease not initialized, call get or create first")
+	}
+	l := *ll.lease
+	l.Spec = recordToLeaseSpec(&ler)
+	return ll.write(ll.Client.Put().Namespace(ll.LeaseMeta.Namespace).Resource("leases").Name(ll.LeaseMeta.Name), &l)
+}
+
+// write sends the lease with the request and keeps the written lease, whose
+// resource version is used by the next update
+func (ll *leaseLock) write(req *rest.Request, l *lease) error {
+	l.APIVersion = leaseGroupVersion.String()
+	l.Kind = "Lease"
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	body, err := req.SetHeader("Content-Type", contentTypeJSON).Body(data).Do().Raw()
+	if err != nil {
+		return err
+	}
+	written := &lease{}
+	if err := json.Unmarshal(body, written); err != nil {
+		return err
+	}
+	ll.lease = written
+	return nil
+}
+
+// RecordEvent in leader election while adding meta-data
+func (ll *leaseLock) RecordEvent(s string) {
+	if ll.LockConfig.EventRecorder == nil {
+		return
+	}
+	ref := &corev1.ObjectReference{
+		Kind:       "Lease",
+		APIVersion: leaseGroupVersion.String(),
+		Namespace:  ll.LeaseMeta.Namespace,
+		Name:       ll.LeaseMeta.Name,
+	}
+	if ll.lease != nil {
+		ref.UID = ll.lease.UID
+	}
+	events := fmt.Sprintf("%v %v", ll.LockConfig.Identity, s)
+	ll.LockConfig.EventRecorder.Eventf(ref, corev1.EventTypeNormal, "LeaderElection", events)
+}
+
+// Describe is used to convert details on current resource lock
+// into a string
+func (ll *leaseLock) Describe() string {
+	return fmt.Sprintf("%v/%v", ll.LeaseMeta.Namespace, ll.LeaseMeta.Name)
+}
+
+// Identity returns the Identity of the lock
+func (ll *leaseLock) Identity() string {
+	return ll.LockConfig.Identity
+}
+
+func leaseSpecToRecord(spec *leaseSpec) *resourcelock.LeaderElectionRecord {
+	var r resourcelock.LeaderElectionRecord
+	if spec.HolderIdentity != nil {
+		r.HolderIdentity = *spec.HolderIdentity
+	}
+	if spec.LeaseDurationSeconds != nil {
+		r.LeaseDurationSeconds = int(*spec.LeaseDurationSeconds)
+	}
+	if spec.LeaseTransitions != nil {
+		r.LeaderTransitions = int(*spec.LeaseTransitions)
+	}
+	if spec.AcquireTime != nil {
+		r.AcquireTime = metav1.Time{Time: spec.AcquireTime.Time}
+	}
+	if spec.RenewTime != nil {
+		r.RenewTime = metav1.Time{Time: spec.RenewTime.Time}
+	}
+	return &r
+}
+
+func recordToLeaseSpec(ler *resourcelock.LeaderElectionRecord) leaseSpec {
+	holderIdentity := ler.HolderIdentity
+	leaseDurationSeconds := int32(ler.LeaseDurationSeconds)
+	leaseTransitions := int32(ler.LeaderTransitions)
+	return leaseSpec{
+		HolderIdentity:       &holderIdentity,
+		LeaseDurationSeconds: &leaseDurationSeconds,
+		AcquireTime:          &metav1.MicroTime{Time: ler.AcquireTime.Time},
+		RenewTime:            &metav1.MicroTime{Time: ler.RenewTime.Time},
+		LeaseTransitions:     &leaseTransitions,
+	}
+}
+
+// releasableLock wraps a leader election lock so the leader can release it when
+// shutting down, by clearing the holder identity of the record. The leader elector
+// waits for the lease duration before taking a lock held by another instance, so a
+// released lock is reported as held by this instance to take it over immediately.
+// The update taking it over fails if another instance took it first.
+type releasableLock struct {
+	resourcelock.Interface
+
+	mu       sync.Mutex
+	released bool
+}
+
+// Get returns the election record, reporting a released lock as held by this instance
+func (rl *releasableLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	record, err := rl.Interface.Get()
+	if err != nil || record.HolderIdentity != "" {
+		return record, err
+	}
+	rl.mu.Lock()
+	released := rl.released
+	rl.mu.Unlock()
+	if released {
+		return record, nil
+	}
+	klog.V(2).Infof("leader election lock %s was released, taking it over", rl.Describe())
+	record.HolderIdentity = rl.Identity()
+	record.AcquireTime = metav1.Now()
+	record.LeaderTransitions++
+	return record, nil
+}
+
+// Update updates the election record, unless this instance released the lock
+func (rl *releasableLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.released {
+		return errLockReleased
+	}
+	return rl.Interface.Update(ler)
+}
+
+// release clears the holder identity of the lock if held by this instance, and
+// returns true if it was. The lock is no longer renewed once released.
+func (rl *releasableLock) release() (bool, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.released {
+		return false, nil
+	}
+	record, err := rl.Interface.Get()
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if record.HolderIdentity != rl.Identity() {
+		return false, nil
+	}
+	record.HolderIdentity = ""
+	record.LeaseDurationSeconds = 1
+	record.RenewTime = metav1.NewTime(time.Now())
+	if err := rl.Interface.Update(*record); err != nil {
+		return false, err
+	}
+	rl.released = true
+	return true, nil
+}
+
+// isReleased returns true if the lock was released by this instance
+func (rl *releasableLock) isReleased() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.released
+}
@@ -2,14 +2,16 @@ package mic
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned"
 	"github.com/Azure/aad-pod-identity/pkg/cloudprovider"
 	"github.com/Azure/aad-pod-identity/pkg/crd"
 	"github.com/Azure/aad-pod-identity/pkg/metrics"
@@ -19,6 +21,8 @@ import (
 	"golang.org/x/sync/semaphore"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -27,17 +31,35 @@ import (
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 )
 
 const (
 	stopped = int32(0)
 	running = int32(1)
+
+	// assignedIDNameMaxLength is the maximum length of the assigned identity names, kept
+	// short enough to be used as a label value
+	assignedIDNameMaxLength = validation.LabelValueMaxLength
+	// assignedIDNameHashLength is the length of the hash ending the assigned identity names
+	assignedIDNameHashLength = 16
+
+	// stopSyncTimeout is how long MIC waits for the key being reconciled when the sync loop is stopped.
+	// It leaves time to release the leader election lock within the default pod termination grace period.
+	stopSyncTimeout = 20 * time.Second
 )
 
 // NodeGetter ...
 type NodeGetter interface {
 	Get(name string) (*corev1.Node, error)
+	List() ([]*corev1.Node, error)
+	Start(<-chan struct{})
+}
+
+// NamespaceGetter ...
+type NamespaceGetter interface {
+	Get(name string) (*corev1.Namespace, error)
 	Start(<-chan struct{})
 }
 
@@ -47,6 +69,8 @@ type LeaderElectionConfig struct {
 	Name      string
 	Duration  time.Duration
 	Instance  string
+	// LockType is the type of the lock, which is endpoints, configmaps or leases
+	LockType string
 }
 
 // Client has the required pointers to talk to the api server
@@ -58,17 +82,48 @@ type Client struct {
 	EventRecorder        record.EventRecorder
 	EventChannel         chan aadpodid.EventType
 	NodeClient           NodeGetter
+	NamespaceClient      NamespaceGetter
 	IsNamespaced         bool
 	SyncLoopStarted      bool
 	syncRetryInterval    time.Duration
 	enableScaleFeatures  bool
 	createDeleteBatch    int64
 	ImmutableUserMSIsMap map[string]bool
+	// dryRun logs the changes each sync would make instead of applying them
+	dryRun bool
+	// status tracks the outcome of the syncs for the identity and binding statuses
+	status *statusTracker
+	// gc configures the garbage collection of the identities left on the nodes and vmss
+	gc GarbageCollectionConfig
+	// gcCandidates holds the identities not assigned to any pod seen by the last garbage
+	// collection of each node or vmss, by node key and lower case resource id
+	gcCandidates map[string]map[string]bool
+	// kubeletIdentityClientID is the client id of the kubelet identity, which is never removed
+	kubeletIdentityClientID string
+	// podReadinessCondition sets the identity assigned condition of the pods
+	podReadinessCondition bool
 
 	syncing int32 // protect against conucrrent sync's
+	// labelSelectorsInUse is set when a binding uses a label selector, so all the pods
+	// are reconciled instead of only the pods with binding selectors
+	labelSelectorsInUse int32
+
+	// queue holds the keys of the nodes or vmss to reconcile while syncing
+	queue               workqueue.RateLimitingInterface
+	queueLock           sync.RWMutex
+	totalSyncCycles     int
+	totalWorkDoneCycles int
 
 	leaderElector *leaderelection.LeaderElector
+	// resourceLock is the leader election lock, released when shutting down
+	resourceLock *releasableLock
 	*LeaderElectionConfig
+
+	// cancelSync cancels the context of the sync loop, and syncStopped is closed once the loop stopped
+	syncLock    sync.Mutex
+	cancelSync  context.CancelFunc
+	syncStopped chan struct{}
+
 	Reporter *metrics.Reporter
 }
 
@@ -84,11 +139,14 @@ type trackUserAssignedMSIIds struct {
 	assignedIDsToCreate      []aadpodid.AzureAssignedIdentity
 	assignedIDsToDelete      []aadpodid.AzureAssignedIdentity
 	isvmss                   bool
+	// nodeNotFound is only set in dry run mode, for the nodes whose assigned ids
+	// would all be deleted since the node no longer exists
+	nodeNotFound bool
 }
 
 // NewMICClient returnes new mic client
 func NewMICClient(cloudconfig string, config *rest.Config, isNamespaced bool, syncRetryInterval time.Duration,
-	leaderElectionConfig *LeaderElectionConfig, enableScaleFeatures bool, createDeleteBatch int64, immutableUserMSIsList []string) (*Client, error) {
+	leaderElectionConfig *LeaderElectionConfig, enableScaleFeatures bool, createDeleteBatch int64, immutableUserMSIsList []string, dryRun bool, gcConfig *GarbageCollectionConfig, podReadinessCondition bool) (*Client, error) {
 	klog.Infof("Starting to create the pod identity client. Version: %v. Build date: %v", version.MICVersion, version.BuildDate)
 
 	clientSet := kubernetes.NewForConfigOrDie(config)
@@ -107,15 +165,16 @@ func NewMICClient(cloudconfig string, config *rest.Config, isNamespaced bool, sy
 	klog.V(1).Infof("Cloud provider initialized")
 
 	eventCh := make(chan aadpodid.EventType, 100)
-	crdClient, err := crd.NewCRDClient(config, eventCh)
+	crdClientSet, err := versioned.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	crdClient, err := crd.NewCRDClient(crdClientSet, eventCh)
 	if err != nil {
 		return nil, err
 	}
 	klog.V(1).Infof("CRD client initialized")
 
-	podClient := pod.NewPodClient(informer, eventCh)
-	klog.V(1).Infof("Pod Client initialized")
-
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")})
 	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: aadpodid.CRDGroup})
@@ -133,7 +192,6 @@ func NewMICClient(cloudconfig string, config *rest.Config, isNamespaced bool, sy
 	c := &Client{
 		CRDClient:            crdClient,
 		CloudClient:          cloudClient,
-		PodClient:            podClient,
 		EventRecorder:        recorder,
 		EventChannel:         eventCh,
 		NodeClient:           &NodeClient{informer.Core().V1().Nodes()},
@@ -142,8 +200,20 @@ func NewMICClient(cloudconfig string, config *rest.Config, isNamespaced bool, sy
 		enableScaleFeatures:  enableScaleFeatures,
 		createDeleteBatch:    createDeleteBatch,
 		ImmutableUserMSIsMap: immutableUserMSIsMap,
+		dryRun:               dryRun,
+		status:               newStatusTracker(),
+		gc:                   *gcConfig,
+		gcCandidates:         make(map[string]map[string]bool),
+		// the identity used by the cloud provider is the kubelet identity
+		kubeletIdentityClientID: cloudClient.Config.UserAssignedIdentityID,
+		syncStopped:             make(chan struct{}),
+		podReadinessCondition:   podReadinessCondition,
 	}
-	leaderElector, err := c.NewLeaderElector(clientSet, recorder, leaderElectionConfig)
+	c.PodClient = pod.NewPodClient(informer, clientSet, c.enqueuePod)
+	c.NamespaceClient = NewNamespaceClient(informer.Core().V1().Namespaces(), c.enqueueFullSync)
+	klog.V(1).Infof("Pod Client initialized")
+
+	leaderElector, err := c.NewLeaderElector(clientSet, config, recorder, leaderElectionConfig)
 	if err != nil {
 		klog.Errorf("New leader elector failure. Error: %+v", err)
 		return nil, err
@@ -159,21 +229,38 @@ func NewMICClient(cloudconfig string, config *rest.Config, isNamespaced bool, sy
 	return c, nil
 }
 
-// Run - Initiates the leader election run call to find if its leader and run it
-func (c *Client) Run() {
+// Run - Initiates the leader election run call to find if its leader and run it.
+// Once the leader lease is lost, the sync loop is stopped and errLostLeaderLease is
+// returned, unless the lease was released by Shutdown.
+func (c *Client) Run() error {
+	if c.dryRun {
+		// a dry run instance must not take the leadership from the instance applying the changes
+		klog.Info("Running MIC in dry run mode without leader election")
+		c.startSync(wait.NeverStop)
+		return nil
+	}
 	klog.Info("Initiating MIC Leader election")
 	// counter to track number of mic election
 	c.Reporter.Report(metrics.MICNewLeaderElectionCountM.M(1))
 	c.leaderElector.Run()
+	if c.resourceLock != nil && c.resourceLock.isReleased() {
+		return nil
+	}
+	return errLostLeaderLease
 }
 
 // NewLeaderElector - does the required leader election initialization
-func (c *Client) NewLeaderElector(clientSet *kubernetes.Clientset, recorder record.EventRecorder, leaderElectionConfig *LeaderElectionConfig) (leaderElector *leaderelection.LeaderElector, err error) {
+func (c *Client) NewLeaderElector(clientSet *kubernetes.Clientset, restConfig *rest.Config, recorder record.EventRecorder, leaderElectionConfig *LeaderElectionConfig) (leaderElector *leaderelection.LeaderElector, err error) {
 	c.LeaderElectionConfig = leaderElectionConfig
-	resourceLock, err := resourcelock.New(resourcelock.EndpointsResourceLock,
+	lockType := c.LockType
+	if lockType == "" {
+		lockType = resourcelock.EndpointsResourceLock
+	}
+	resourceLock, err := newResourceLock(lockType,
 		c.Namespace,
 		c.Name,
-		clientSet.CoreV1(),
+		clientSet,
+		restConfig,
 		resourcelock.ResourceLockConfig{
 			Identity:      c.Instance,
 			EventRecorder: recorder})
@@ -181,28 +268,88 @@ func (c *Client) NewLeaderElector(clientSet *kubernetes.Clientset, recorder reco
 		klog.Errorf("Resource lock creation for leader election failed with error : %v", err)
 		return nil, err
 	}
-	config := leaderelection.LeaderElectionConfig{
+	c.resourceLock = &releasableLock{Interface: resourceLock}
+
+	leaderElector, err = leaderelection.NewLeaderElector(c.leaderElectionConfig())
+	if err != nil {
+		return nil, err
+	}
+	return leaderElector, nil
+}
+
+// leaderElectionConfig returns the leader election config syncing while MIC holds the resource lock
+func (c *Client) leaderElectionConfig() leaderelection.LeaderElectionConfig {
+	return leaderelection.LeaderElectionConfig{
 		LeaseDuration: c.Duration,
 		RenewDeadline: c.Duration / 2,
 		RetryPeriod:   c.Duration / 4,
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(exit <-chan struct{}) {
-				c.Start(exit)
+				c.startSync(exit)
 			},
 			OnStoppedLeading: func() {
 				klog.Errorf("Lost leader lease")
-				klog.Flush()
-				os.Exit(1)
+				// the next leader reconciles the keys left in the queue. The leader elector
+				// returns once this callback returns, so Run returns to the caller.
+				c.stopSync()
 			},
 		},
-		Lock: resourceLock,
+		Lock: c.resourceLock,
+	}
+}
+
+// startSync starts the clients and the sync loop with a context, which is canceled
+// once stop is closed or stopSync is called.
+func (c *Client) startSync(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.syncLock.Lock()
+	c.cancelSync = cancel
+	c.syncLock.Unlock()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	c.Start(ctx.Done())
+}
+
+// stopSync cancels the context of the sync loop and waits for the key being reconciled,
+// so the identities being updated on the nodes and vmss are updated before MIC exits.
+func (c *Client) stopSync() {
+	c.syncLock.Lock()
+	cancel := c.cancelSync
+	c.syncLock.Unlock()
+	if cancel == nil {
+		return
 	}
+	cancel()
 
-	leaderElector, err = leaderelection.NewLeaderElector(config)
+	select {
+	case <-c.syncStopped:
+		klog.Info("Sync loop stopped")
+	case <-time.After(stopSyncTimeout):
+		klog.Errorf("Sync loop did not stop within %s", stopSyncTimeout)
+	}
+}
+
+// Shutdown stops the sync loop and releases the leader election lock, so another
+// instance takes over without waiting for the lease to expire.
+func (c *Client) Shutdown() {
+	c.stopSync()
+	if c.resourceLock == nil {
+		return
+	}
+	released, err := c.resourceLock.release()
 	if err != nil {
-		return nil, err
+		klog.Errorf("Failed to release leader election lock %s. Error: %v", c.resourceLock.Describe(), err)
+		return
+	}
+	if released {
+		klog.Infof("Released leader election lock %s", c.resourceLock.Describe())
 	}
-	return leaderElector, nil
 }
 
 // Start ...
@@ -232,6 +379,13 @@ func (c *Client) Start(exit <-chan struct{}) {
 		wg.Done()
 	}()
 
+	wg.Add(1)
+	go func() {
+		c.NamespaceClient.Start(exit)
+		klog.V(6).Infof("Namespace client started")
+		wg.Done()
+	}()
+
 	wg.Wait()
 	go c.Sync(exit)
 }
@@ -244,143 +398,239 @@ func (c *Client) setStopped() {
 	atomic.StoreInt32(&c.syncing, stopped)
 }
 
-// Sync ...
+// Sync runs the work queue reconciling the assigned identities of the nodes. Pod
+// changes queue the node of the pod, while binding and identity changes received
+// on the event channel and the periodic resync queue a sync of all the nodes.
 func (c *Client) Sync(exit <-chan struct{}) {
 	if !c.canSync() {
 		panic("concurrent syncs")
 	}
 	defer c.setStopped()
+	if c.syncStopped != nil {
+		defer close(c.syncStopped)
+	}
 
 	ticker := time.NewTicker(c.syncRetryInterval)
 	defer ticker.Stop()
 
+	var gcTicker <-chan time.Time
+	if c.gc.Interval > 0 {
+		t := time.NewTicker(c.gc.Interval)
+		defer t.Stop()
+		gcTicker = t.C
+	}
+
 	klog.Info("Sync thread started.")
 	c.SyncLoopStarted = true
-	var event aadpodid.EventType
-	totalWorkDoneCycles := 0
-	totalSyncCycles := 0
+
+	queue := workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "mic")
+	c.setQueue(queue)
+	// changes received before the queue was created are reconciled by a full sync
+	queue.Add(fullSyncKey)
+
+	// a single worker reconciles the queue, so the same vmss or node is never
+	// updated concurrently from different keys
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for c.processNextWorkItem(queue) {
+			select {
+			case <-exit:
+				// the keys left in the queue are reconciled by the next sync loop
+				return
+			default:
+			}
+		}
+	}()
+	defer func() {
+		c.setQueue(nil)
+		queue.ShutDown()
+		<-done
+	}()
 
 	for {
 		select {
 		case <-exit:
 			return
-		case event = <-c.EventChannel:
+		case event := <-c.EventChannel:
 			klog.V(6).Infof("Received event: %v", event)
+			queue.Add(fullSyncKey)
 		case <-ticker.C:
 			klog.V(6).Infof("Running periodic sync loop")
+			queue.Add(fullSyncKey)
+		case <-gcTicker:
+			klog.V(6).Infof("Running periodic garbage collection")
+			queue.Add(gcKey)
 		}
-		totalSyncCycles++
-		stats.Init()
-		// This is the only place where the AzureAssignedIdentity creation is initiated.
-		begin := time.Now()
-		workDone := false
-
-		// List all pods in all namespaces
-		systemTime := time.Now()
-		listPods, err := c.PodClient.GetPods()
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
-		listBindings, err := c.CRDClient.ListBindings()
-		if err != nil {
-			continue
-		}
-		listIDs, err := c.CRDClient.ListIds()
-		if err != nil {
-			continue
-		}
-		idMap, err := c.convertIDListToMap(*listIDs)
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
+	}
+}
 
-		currentAssignedIDs, err := c.CRDClient.ListAssignedIDsInMap()
-		if err != nil {
-			continue
-		}
-		stats.Put(stats.System, time.Since(systemTime))
+// sync reconciles the assigned identities of the nodes for which filter returns
+// true, or of all the nodes if filter is nil.
+func (c *Client) sync(filter func(nodeName string) bool) error {
+	c.totalSyncCycles++
+	stats.Init()
+	c.status.reset()
+	// This is the only place where the AzureAssignedIdentity creation is initiated.
+	begin := time.Now()
+	workDone := false
 
-		beginNewListTime := time.Now()
-		newAssignedIDs, nodeRefs, err := c.createDesiredAssignedIdentityList(listPods, listBindings, idMap)
-		if err != nil {
-			klog.Error(err)
-			continue
-		}
-		stats.Put(stats.CurrentState, time.Since(beginNewListTime))
+	// List all pods in all namespaces
+	systemTime := time.Now()
+	listPods, err := c.PodClient.GetPods()
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	listBindings, err := c.CRDClient.ListBindings()
+	if err != nil {
+		return err
+	}
+	listPods = c.filterPods(listPods, *listBindings)
+	listIDs, err := c.CRDClient.ListIds()
+	if err != nil {
+		return err
+	}
+	idMap, err := c.convertIDListToMap(*listIDs)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
 
-		// Extract add list and delete list based on existing assigned ids in the system (currentAssignedIDs).
-		// and the ones we have arrived at in the volatile list (newAssignedIDs).
-		addList, err := c.getAzureAssignedIDsToCreate(currentAssignedIDs, newAssignedIDs)
-		if err != nil {
-			klog.Error(err)
-			continue
+	currentAssignedIDs, err := c.CRDClient.ListAssignedIDsInMap()
+	if err != nil {
+		return err
+	}
+	stats.Put(stats.System, time.Since(systemTime))
+
+	allAssignedIDs := currentAssignedIDs
+	if filter != nil {
+		// only the pods and assigned identities on the nodes being reconciled are compared.
+		// Identities in use are only shared by the same node or vmss, which are reconciled together.
+		var nodePods []*corev1.Pod
+		for _, pod := range listPods {
+			if pod.Spec.NodeName != "" && filter(pod.Spec.NodeName) {
+				nodePods = append(nodePods, pod)
+			}
 		}
-		deleteList, err := c.getAzureAssignedIDsToDelete(currentAssignedIDs, newAssignedIDs)
-		if err != nil {
-			klog.Error(err)
-			continue
+		listPods = nodePods
+
+		currentAssignedIDs = make(map[string]aadpodid.AzureAssignedIdentity)
+		for name, assignedID := range allAssignedIDs {
+			if filter(assignedID.Spec.NodeName) {
+				currentAssignedIDs[name] = assignedID
+			}
 		}
-		klog.V(5).Infof("del: %v, add: %v", deleteList, addList)
+	}
 
-		// the node map is used to track assigned ids to create/delete, identities to assign/remove
-		// for each node or vmss
-		nodeMap := make(map[string]trackUserAssignedMSIIds)
+	beginNewListTime := time.Now()
+	newAssignedIDs, nodeRefs, err := c.createDesiredAssignedIdentityList(listPods, listBindings, idMap)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	stats.Put(stats.CurrentState, time.Since(beginNewListTime))
 
-		// seperate the add and delete list per node
-		c.convertAssignedIDListToMap(addList, deleteList, nodeMap)
+	c.migrateAssignedIDNames(currentAssignedIDs, newAssignedIDs)
 
-		// process the delete and add list
-		// determine the list of identities that need to updated, create a node to identity list mapping for add and delete
-		if len(deleteList) > 0 {
-			workDone = true
-			c.getListOfIdsToDelete(deleteList, newAssignedIDs, nodeMap, nodeRefs)
-		}
-		if len(addList) > 0 {
-			workDone = true
-			c.getListOfIdsToAssign(addList, nodeMap)
-		}
+	// Extract add list and delete list based on existing assigned ids in the system (currentAssignedIDs).
+	// and the ones we have arrived at in the volatile list (newAssignedIDs).
+	addList, err := c.getAzureAssignedIDsToCreate(currentAssignedIDs, newAssignedIDs)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	deleteList, err := c.getAzureAssignedIDsToDelete(currentAssignedIDs, newAssignedIDs)
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	klog.V(5).Infof("del: %v, add: %v", deleteList, addList)
 
-		var wg sync.WaitGroup
+	// the node map is used to track assigned ids to create/delete, identities to assign/remove
+	// for each node or vmss
+	nodeMap := make(map[string]trackUserAssignedMSIIds)
 
-		// check if vmss and consolidate vmss nodes into vmss if necessary
-		c.consolidateVMSSNodes(nodeMap, &wg)
+	// seperate the add and delete list per node
+	c.convertAssignedIDListToMap(addList, deleteList, nodeMap)
 
-		// one final createorupdate to each node or vmss in the map
-		c.updateNodeAndDeps(newAssignedIDs, nodeMap, nodeRefs, &wg)
+	// process the delete and add list
+	// determine the list of identities that need to updated, create a node to identity list mapping for add and delete
+	if len(deleteList) > 0 {
+		workDone = true
+		c.getListOfIdsToDelete(deleteList, newAssignedIDs, nodeMap, nodeRefs)
+	}
+	if len(addList) > 0 {
+		workDone = true
+		c.getListOfIdsToAssign(addList, nodeMap)
+	}
 
-		wg.Wait()
+	var wg sync.WaitGroup
 
-		if workDone || ((totalSyncCycles % 1000) == 0) {
-			if workDone {
-				totalWorkDoneCycles++
-			}
-			idsFound := 0
-			bindingsFound := 0
-			if listIDs != nil {
-				idsFound = len(*listIDs)
-			}
-			if listBindings != nil {
-				bindingsFound = len(*listBindings)
-			}
-			klog.Infof("Work done: %v. Found %d pods, %d ids, %d bindings", workDone, len(listPods), idsFound, bindingsFound)
-			klog.Infof("Total work cycles: %d, out of which work was done in: %d.", totalSyncCycles, totalWorkDoneCycles)
-			stats.Put(stats.Total, time.Since(begin))
-
-			c.Reporter.Report(
-				metrics.MICCycleCountM.M(1),
-				metrics.MICCycleDurationM.M(metrics.SinceInSeconds(begin)))
-
-			stats.PrintSync()
-			if workDone {
-				// We need to synchronize the cache inorder to get the latest updates. Sync cache has a bug in the current go client which caused thread leak.
-				// Updating of go client has issues with case sensitivity. Avoid this issue by sleping for 500 milliseconds to reduce the chance
-				// of cache misses for assignedidentities updated in the previous cycle.
-				time.Sleep(time.Millisecond * 200)
+	// check if vmss and consolidate vmss nodes into vmss if necessary
+	c.consolidateVMSSNodes(nodeMap, &wg)
+
+	if c.dryRun {
+		return logSyncPlan(newSyncPlan(nodeMap))
+	}
+
+	// one final createorupdate to each node or vmss in the map
+	c.updateNodeAndDeps(newAssignedIDs, nodeMap, nodeRefs, &wg)
+
+	wg.Wait()
+
+	// the identities are still desired on the nodes which were not reconciled
+	desiredAssignedIDs := make([]aadpodid.AzureAssignedIdentity, 0, len(newAssignedIDs))
+	for _, assignedID := range newAssignedIDs {
+		desiredAssignedIDs = append(desiredAssignedIDs, assignedID)
+	}
+	if filter != nil {
+		for _, assignedID := range allAssignedIDs {
+			if !filter(assignedID.Spec.NodeName) {
+				desiredAssignedIDs = append(desiredAssignedIDs, assignedID)
 			}
 		}
 	}
+	var ids []aadpodid.AzureIdentity
+	var bindings []aadpodid.AzureIdentityBinding
+	if listIDs != nil {
+		ids = *listIDs
+	}
+	if listBindings != nil {
+		bindings = *listBindings
+	}
+	c.updateStatuses(ids, bindings, desiredAssignedIDs, allAssignedIDs)
+	c.updatePodReadiness(listPods, newAssignedIDs, currentAssignedIDs)
+
+	if workDone || ((c.totalSyncCycles % 1000) == 0) {
+		if workDone {
+			c.totalWorkDoneCycles++
+		}
+		idsFound := 0
+		bindingsFound := 0
+		if listIDs != nil {
+			idsFound = len(*listIDs)
+		}
+		if listBindings != nil {
+			bindingsFound = len(*listBindings)
+		}
+		klog.Infof("Work done: %v. Found %d pods, %d ids, %d bindings", workDone, len(listPods), idsFound, bindingsFound)
+		klog.Infof("Total work cycles: %d, out of which work was done in: %d.", c.totalSyncCycles, c.totalWorkDoneCycles)
+		stats.Put(stats.Total, time.Since(begin))
+
+		c.Reporter.Report(
+			metrics.MICCycleCountM.M(1),
+			metrics.MICCycleDurationM.M(metrics.SinceInSeconds(begin)))
+
+		stats.PrintSync()
+		if workDone {
+			// We need to synchronize the cache inorder to get the latest updates. Sync cache has a bug in the current go client which caused thread leak.
+			// Updating of go client has issues with case sensitivity. Avoid this issue by sleping for 500 milliseconds to reduce the chance
+			// of cache misses for assignedidentities updated in the previous cycle.
+			time.Sleep(time.Millisecond * 200)
+		}
+	}
+	return nil
 }
 
 func (c *Client) convertAssignedIDListToMap(addList, deleteList map[string]aadpodid.AzureAssignedIdentity, nodeMap map[string]trackUserAssignedMSIIds) {
@@ -416,21 +666,34 @@ func (c *Client) createDesiredAssignedIdentityList(
 	nodeRefs := make(map[string]bool)
 	newAssignedIDs := make(map[string]aadpodid.AzureAssignedIdentity)
 
+	bindingSelectors := make([]*bindingSelector, 0, len(*listBindings))
+	for i := range *listBindings {
+		binding := &(*listBindings)[i]
+		selector, err := newBindingSelector(*binding)
+		if err != nil {
+			klog.Errorf("binding %s/%s has invalid selectors, it will be ignored. Error: %v", binding.Namespace, binding.Name, err)
+			c.EventRecorder.Event(binding, corev1.EventTypeWarning, "binding selector error", err.Error())
+			continue
+		}
+		bindingSelectors = append(bindingSelectors, selector)
+	}
+
 	for _, pod := range listPods {
 		if pod.Spec.NodeName == "" {
 			//Node is not yet allocated. In that case skip the pod
 			klog.V(2).Infof("Pod %s/%s has no assigned node yet. it will be ignored", pod.Namespace, pod.Name)
 			continue
 		}
-		crdPodLabelVal := pod.Labels[aadpodid.CRDLabelKey]
-		if crdPodLabelVal == "" {
-			//No binding mentioned in the label. Just continue to the next pod
-			klog.V(2).Infof("Pod %s/%s has correct %s label but with no value. it will be ignored", pod.Namespace, pod.Name, aadpodid.CRDLabelKey)
-			continue
-		}
+		podSelectors := getPodSelectors(pod)
 		var matchedBindings []aadpodid.AzureIdentityBinding
-		for _, allBinding := range *listBindings {
-			if allBinding.Spec.Selector == crdPodLabelVal {
+		for _, selector := range bindingSelectors {
+			allBinding := selector.binding
+			matched, err := selector.matches(pod, podSelectors, c.NamespaceClient.Get)
+			if err != nil {
+				klog.Errorf("failed to match pod %s/%s with binding %s/%s. Error: %v", pod.Namespace, pod.Name, allBinding.Namespace, allBinding.Name, err)
+				continue
+			}
+			if matched {
 				klog.V(5).Infof("Found binding match for pod %s/%s with binding %s/%s", pod.Namespace, pod.Name, allBinding.Namespace, allBinding.Name)
 				matchedBindings = append(matchedBindings, allBinding)
 				nodeRefs[pod.Spec.NodeName] = true
@@ -441,16 +704,13 @@ func (c *Client) createDesiredAssignedIdentityList(
 			klog.V(5).Infof("Looking up id map: %s/%s", binding.Namespace, binding.Spec.AzureIdentity)
 			if azureID, idPresent := idMap[getIDKey(binding.Namespace, binding.Spec.AzureIdentity)]; idPresent {
 				// working in Namespaced mode or this specific identity is namespaced
-				if c.IsNamespaced || aadpodid.IsNamespacedIdentity(&azureID) {
-					// They have to match all
-					if !(azureID.Namespace == binding.Namespace && binding.Namespace == pod.Namespace) {
-						klog.V(5).Infof("identity %s/%s was matched via binding %s/%s to %s/%s but namespaced identity is enforced, so it will be ignored",
-							azureID.Namespace, azureID.Name, binding.Namespace, binding.Name, pod.Namespace, pod.Name)
-						continue
-					}
+				if !IsIdentityAllowed(c.IsNamespaced, &azureID, &binding, pod.Namespace) {
+					klog.V(5).Infof("identity %s/%s was matched via binding %s/%s to %s/%s but namespaced identity is enforced, so it will be ignored",
+						azureID.Namespace, azureID.Name, binding.Namespace, binding.Name, pod.Namespace, pod.Name)
+					continue
 				}
 				klog.V(5).Infof("identity %s/%s assigned to %s/%s via %s/%s", azureID.Namespace, azureID.Name, pod.Namespace, pod.Name, binding.Namespace, binding.Name)
-				assignedID, err := c.makeAssignedIDs(azureID, binding, pod.Name, pod.Namespace, pod.Spec.NodeName)
+				assignedID, err := c.makeAssignedIDs(azureID, binding, pod)
 
 				if err != nil {
 					klog.Errorf("failed to create assignment for pod %s/%s with identity %s/%s with error %v", pod.Namespace, pod.Name, azureID.Namespace, azureID.Name, err.Error())
@@ -541,6 +801,8 @@ func (c *Client) matchAssignedID(x aadpodid.AzureAssignedIdentity, y aadpodid.Az
 		idX.ResourceVersion == idY.ResourceVersion &&
 		x.Spec.Pod == y.Spec.Pod &&
 		x.Spec.PodNamespace == y.Spec.PodNamespace &&
+		// assigned identities created before the pod uid was recorded match any pod with the name
+		(x.Spec.PodUID == "" || x.Spec.PodUID == y.Spec.PodUID) &&
 		x.Spec.NodeName == y.Spec.NodeName
 }
 
@@ -608,18 +870,33 @@ func (c *Client) getAzureAssignedIDsToDelete(old, new map[string]aadpodid.AzureA
 	return delete, nil
 }
 
-func (c *Client) makeAssignedIDs(azID aadpodid.AzureIdentity, azBinding aadpodid.AzureIdentityBinding, podName, podNameSpace, nodeName string) (res *aadpodid.AzureAssignedIdentity, err error) {
+func (c *Client) makeAssignedIDs(azID aadpodid.AzureIdentity, azBinding aadpodid.AzureIdentityBinding, pod *corev1.Pod) (res *aadpodid.AzureAssignedIdentity, err error) {
 	binding := azBinding
 	id := azID
+	podName, podNameSpace, nodeName := pod.Name, pod.Namespace, pod.Spec.NodeName
 
 	labels := make(map[string]string)
 	labels["nodename"] = nodeName
 	labels["podnamespace"] = podNameSpace
 	labels["podname"] = podName
+	// the name is hashed, so the identity and binding are labels too. Names longer
+	// than a label value are left out.
+	for key, value := range map[string]string{
+		"idnamespace":      azID.Namespace,
+		"idname":           azID.Name,
+		"bindingnamespace": azBinding.Namespace,
+		"bindingname":      azBinding.Name,
+	} {
+		if len(validation.IsValidLabelValue(value)) == 0 {
+			labels[key] = value
+		}
+	}
 
 	oMeta := v1.ObjectMeta{
-		Name:   c.getAssignedIDName(podName, podNameSpace, azID.Name),
+		Name:   c.getAssignedIDName(podName, podNameSpace, &azID, &azBinding),
 		Labels: labels,
+		// the assigned identity is only deleted once mic removed the identity from the node
+		Finalizers: []string{aadpodid.AssignedIDFinalizer},
 	}
 	assignedID := &aadpodid.AzureAssignedIdentity{
 		ObjectMeta: oMeta,
@@ -629,20 +906,23 @@ func (c *Client) makeAssignedIDs(azID aadpodid.AzureIdentity, azBinding aadpodid
 			Pod:              podName,
 			PodNamespace:     podNameSpace,
 			NodeName:         nodeName,
+			PodUID:           pod.UID,
 		},
 		Status: aadpodid.AzureAssignedIdentityStatus{
 			AvailableReplicas: 1,
 		},
 	}
-	// if we are in namespaced mode (or az identity is namespaced)
-	if c.IsNamespaced || aadpodid.IsNamespacedIdentity(&id) {
-		assignedID.Namespace = azID.Namespace
-	} else {
-		// eventually this should be identity namespace
-		// but to maintain back compat we will use existing
-		// behavior
-		assignedID.Namespace = "default"
-	}
+	// the assigned identity is created in the namespace of the pod, which is also the namespace
+	// of namespaced identities, so it can be owned by the pod. Owner references across namespaces
+	// are not allowed. Assigned identities created in the default namespace by earlier releases
+	// are kept, and deleted by mic with their pod.
+	assignedID.Namespace = podNameSpace
+	assignedID.OwnerReferences = []v1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       podName,
+		UID:        pod.UID,
+	}}
 
 	klog.V(6).Infof("Binding - %+v Identity - %+v", azBinding, azID)
 	klog.V(5).Infof("Making assigned ID: %+v", assignedID)
@@ -687,8 +967,63 @@ func (c *Client) checkIfUserAssignedMSI(id *aadpodid.AzureIdentity) bool {
 	return id.Spec.Type == aadpodid.UserAssignedMSI
 }
 
-func (c *Client) getAssignedIDName(podName, podNameSpace, idName string) string {
-	return podName + "-" + podNameSpace + "-" + idName
+// getAssignedIDName returns the name of the assigned identity of the pod for the identity and
+// binding. It is a readable prefix followed by a hash of the namespaces and names of the pod,
+// identity and binding, so assigned identities of different pods, identities or bindings never
+// share a name, however long the names are.
+func (c *Client) getAssignedIDName(podName, podNameSpace string, id *aadpodid.AzureIdentity, binding *aadpodid.AzureIdentityBinding) string {
+	// names cannot contain a slash, so the joined names are unique
+	sum := sha256.Sum256([]byte(strings.Join([]string{podNameSpace, podName, id.Namespace, id.Name, binding.Namespace, binding.Name}, "/")))
+	hash := hex.EncodeToString(sum[:])[:assignedIDNameHashLength]
+
+	prefix := podName + "-" + podNameSpace + "-" + id.Name
+	if len(prefix) > assignedIDNameMaxLength-assignedIDNameHashLength-1 {
+		prefix = prefix[:assignedIDNameMaxLength-assignedIDNameHashLength-1]
+	}
+	// the name has to start and end with an alphanumeric character
+	prefix = strings.TrimRight(prefix, "-.")
+	return prefix + "-" + hash
+}
+
+// migrateAssignedIDNames renames the current assigned identities whose name is not the name
+// of their pod, identity and binding, such as the ones created by earlier releases. The assigned
+// identity with the new name is created in the same state before the old one is deleted, so the
+// identity stays assigned to the node and is not assigned again.
+func (c *Client) migrateAssignedIDNames(currentAssignedIDs, newAssignedIDs map[string]aadpodid.AzureAssignedIdentity) {
+	for name, assignedID := range currentAssignedIDs {
+		if assignedID.Spec.AzureIdentityRef == nil || assignedID.Spec.AzureBindingRef == nil {
+			continue
+		}
+		newName := c.getAssignedIDName(assignedID.Spec.Pod, assignedID.Spec.PodNamespace, assignedID.Spec.AzureIdentityRef, assignedID.Spec.AzureBindingRef)
+		if name == newName {
+			continue
+		}
+		if _, exists := currentAssignedIDs[newName]; exists {
+			continue
+		}
+		// assigned identities that are no longer desired are deleted by the sync
+		newAssignedID, desired := newAssignedIDs[newName]
+		if !desired || !c.matchAssignedID(assignedID, newAssignedID) {
+			continue
+		}
+		newAssignedID.Status = assignedID.Status
+
+		if c.dryRun {
+			klog.Infof("[dry run] would rename assigned id %s/%s to %s", assignedID.Namespace, name, newName)
+		} else {
+			klog.Infof("Renaming assigned id %s/%s to %s", assignedID.Namespace, name, newName)
+			if err := c.createAssignedIdentity(&newAssignedID); err != nil {
+				klog.Errorf("failed to rename assigned id %s/%s to %s, error: %v", assignedID.Namespace, name, newName, err)
+				continue
+			}
+			// if the old assigned identity is not deleted, it is deleted by the next sync as it is not desired
+			if err := c.removeAssignedIdentity(&assignedID); err != nil {
+				klog.Errorf("failed to delete assigned id %s/%s renamed to %s, error: %v", assignedID.Namespace, name, newName, err)
+			}
+		}
+		delete(currentAssignedIDs, name)
+		currentAssignedIDs[newName] = newAssignedID
+	}
 }
 
 func (c *Client) checkIfMSIExistsOnNode(id *aadpodid.AzureIdentity, nodeName string, nodeMSIList []string) bool {
@@ -846,8 +1181,10 @@ func (c *Client) updateUserMSI(newAssignedIDs map[string]aadpodid.AzureAssignedI
 				message := fmt.Sprintf("Applying binding %s node %s for pod %s resulted in error %v", binding.Name, createID.Spec.NodeName, createID.Name, err.Error())
 				c.EventRecorder.Event(binding, corev1.EventTypeWarning, "binding apply error", message)
 				klog.Error(message)
+				c.status.setError(&createID, err)
 				continue
 			}
+			c.status.setApplied(&createID)
 			// the identity was successfully assigned to the node
 			c.EventRecorder.Event(binding, corev1.EventTypeNormal, "binding applied",
 				fmt.Sprintf("Binding %s applied on node %s for pod %s", binding.Name, createID.Spec.NodeName, createID.Name))
@@ -882,8 +1219,10 @@ func (c *Client) updateUserMSI(newAssignedIDs map[string]aadpodid.AzureAssignedI
 				message := fmt.Sprintf("Binding %s removal from node %s for pod %s resulted in error %v", removedBinding.Name, delID.Spec.NodeName, delID.Spec.Pod, err.Error())
 				c.EventRecorder.Event(removedBinding, corev1.EventTypeWarning, "binding remove error", message)
 				klog.Error(message)
+				c.status.setError(&delID, err)
 				continue
 			}
+			c.status.setRemoved(&delID)
 
 			klog.Infof("Updating msis on node %s failed, but identity %s has successfully been removed from node", delID.Spec.NodeName, removedBinding.Name)
 
@@ -903,6 +1242,13 @@ func (c *Client) updateUserMSI(newAssignedIDs map[string]aadpodid.AzureAssignedI
 		return
 	}
 
+	for i := range nodeTrackList.assignedIDsToCreate {
+		c.status.setApplied(&nodeTrackList.assignedIDsToCreate[i])
+	}
+	for i := range nodeTrackList.assignedIDsToDelete {
+		c.status.setRemoved(&nodeTrackList.assignedIDsToDelete[i])
+	}
+
 	semUpdate := semaphore.NewWeighted(c.createDeleteBatch)
 
 	for _, createID := range nodeTrackList.assignedIDsToCreate {
@@ -1009,6 +1355,11 @@ func (c *Client) consolidateVMSSNodes(nodeMap map[string]trackUserAssignedMSIIds
 		}
 		if err != nil && strings.Contains(err.Error(), "not found") {
 			klog.Warningf("Unable to get node %s while updating user msis. Error %v", nodeName, err)
+			if c.dryRun {
+				nodeTrackList.nodeNotFound = true
+				nodeMap[nodeName] = nodeTrackList
+				continue
+			}
 			wg.Add(1)
 			// node is no longer found in the cluster, all the assigned identities that were created in this sync loop
 			// and those that already exist for this node need to be deleted.
@@ -1067,3 +1418,22 @@ func (c *Client) checkIfIdentityImmutable(id string) bool {
 	}
 	return false
 }
+
+// filterPods returns the pods which can be matched by the bindings. Unless a binding
+// uses a label selector, only the pods with binding selectors are reconciled, so
+// clusters only using the aadpodidbinding label do not reconcile all their pods.
+func (c *Client) filterPods(pods []*corev1.Pod, bindings []aadpodid.AzureIdentityBinding) []*corev1.Pod {
+	if usesLabelSelectors(bindings) {
+		atomic.StoreInt32(&c.labelSelectorsInUse, 1)
+		return pods
+	}
+	atomic.StoreInt32(&c.labelSelectorsInUse, 0)
+
+	filtered := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if canMatchBindings(pod) {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
@@ -1,10 +1,16 @@
 package mic
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,9 +24,19 @@ import (
 	cp "github.com/Azure/aad-pod-identity/pkg/cloudprovider"
 	api "k8s.io/api/core/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 )
 
@@ -78,6 +94,10 @@ func (c *TestVMClient) CreateOrUpdate(rg string, nodeName string, vm compute.Vir
 	return nil
 }
 
+func (c *TestVMClient) UpdateIdentities(rg string, nodeName string, vm compute.VirtualMachine) error {
+	return c.CreateOrUpdate(rg, nodeName, vm)
+}
+
 func (c *TestVMClient) ListMSI() (ret map[string]*[]string) {
 	ret = make(map[string]*[]string)
 
@@ -153,6 +173,10 @@ func (c *TestVMSSClient) CreateOrUpdate(rg string, nodeName string, vm compute.V
 	return nil
 }
 
+func (c *TestVMSSClient) UpdateIdentities(rg string, nodeName string, vm compute.VirtualMachineScaleSet) error {
+	return c.CreateOrUpdate(rg, nodeName, vm)
+}
+
 func (c *TestVMSSClient) ListMSI() (ret map[string]*[]string) {
 	ret = make(map[string]*[]string)
 
@@ -261,6 +285,8 @@ func NewTestCloudClient(cfg config.AzureConfig) *TestCloudClient {
 type TestPodClient struct {
 	mu   sync.Mutex
 	pods []*corev1.Pod
+	// conditionUpdates counts the pod conditions set
+	conditionUpdates int
 }
 
 func NewTestPodClient() *TestPodClient {
@@ -285,6 +311,39 @@ func (c *TestPodClient) GetPods() ([]*corev1.Pod, error) {
 	return pods, nil
 }
 
+func (c *TestPodClient) SetPodCondition(pod *corev1.Pod, condition corev1.PodCondition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conditionUpdates++
+	for i, p := range c.pods {
+		if p.Name != pod.Name || p.Namespace != pod.Namespace {
+			continue
+		}
+		updated := p.DeepCopy()
+		updated.Status.Conditions = []corev1.PodCondition{condition}
+		for _, existing := range p.Status.Conditions {
+			if existing.Type != condition.Type {
+				updated.Status.Conditions = append(updated.Status.Conditions, existing)
+			}
+		}
+		c.pods[i] = updated
+	}
+	return nil
+}
+
+func (c *TestPodClient) getPodCondition(podName, podNs string) *corev1.PodCondition {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range c.pods {
+		if p.Name == podName && p.Namespace == podNs {
+			return getPodCondition(p, internalaadpodid.PodIdentityAssignedCondition)
+		}
+	}
+	return nil
+}
+
 func (c *TestPodClient) AddPod(podName, podNs, nodeName, binding string) {
 	labels := make(map[string]string)
 	labels[aadpodid.CRDLabelKey] = binding
@@ -388,6 +447,32 @@ func (c *TestCrdClient) UpdateAzureAssignedIdentityStatus(assignedIdentity *inte
 	return nil
 }
 
+func (c *TestCrdClient) UpdateAzureIdentityStatus(azureID *internalaadpodid.AzureIdentity, status internalaadpodid.AzureIdentityStatus) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return *c.err
+	}
+	if id, ok := c.idMap[getIDKey(azureID.Namespace, azureID.Name)]; ok {
+		id.Status = aadpodid.ConvertInternalIdentityToV1Identity(internalaadpodid.AzureIdentity{Status: status}).Status
+	}
+	return nil
+}
+
+func (c *TestCrdClient) UpdateAzureIdentityBindingStatus(binding *internalaadpodid.AzureIdentityBinding, status internalaadpodid.AzureIdentityBindingStatus) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return *c.err
+	}
+	if b, ok := c.bindingMap[getIDKey(binding.Namespace, binding.Name)]; ok {
+		b.Status = aadpodid.ConvertInternalBindingToV1Binding(internalaadpodid.AzureIdentityBinding{Status: status}).Status
+	}
+	return nil
+}
+
 func (c *TestCrdClient) CreateBinding(name, ns, idName, selector, resourceVersion string) {
 	binding := &aadpodid.AzureIdentityBinding{
 		ObjectMeta: v1.ObjectMeta{
@@ -468,7 +553,7 @@ func (c *TestCrdClient) ListAssignedIDsInMap() (res map[string]internalaadpodid.
 	return assignedIDMap, nil
 }
 
-func (c *Client) ListPodIds(podns, podname string) (map[string][]internalaadpodid.AzureIdentity, error) {
+func (c *Client) ListPodIds(podns, podname string, podUID types.UID) (map[string][]internalaadpodid.AzureIdentity, error) {
 	return map[string][]internalaadpodid.AzureIdentity{}, nil
 }
 
@@ -507,6 +592,17 @@ func (c *TestNodeClient) Get(name string) (*corev1.Node, error) {
 	return node, nil
 }
 
+func (c *TestNodeClient) List() ([]*corev1.Node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var nodes []*corev1.Node
+	for _, node := range c.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
 func (c *TestNodeClient) Delete(name string) {
 	c.mu.Lock()
 	delete(c.nodes, name)
@@ -597,6 +693,37 @@ func (c *TestEventRecorder) AnnotatedEventf(object runtime.Object, annotations m
 
 }
 
+/************************ NAMESPACE MOCK *************************************/
+
+type TestNamespaceClient struct {
+	mu         sync.Mutex
+	namespaces map[string]*corev1.Namespace
+}
+
+func NewTestNamespaceClient() *TestNamespaceClient {
+	return &TestNamespaceClient{namespaces: make(map[string]*corev1.Namespace)}
+}
+
+func (c *TestNamespaceClient) Get(name string) (*corev1.Namespace, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ns, exists := c.namespaces[name]
+	if !exists {
+		return nil, errors.New("namespace not found")
+	}
+	return ns, nil
+}
+
+func (c *TestNamespaceClient) Start(<-chan struct{}) {}
+
+func (c *TestNamespaceClient) AddNamespace(name string, labels map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.namespaces[name] = &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: name, Labels: labels}}
+}
+
 /************************ MIC MOC *************************************/
 func NewMICTestClient(eventCh chan internalaadpodid.EventType,
 	cpClient *TestCloudClient,
@@ -616,11 +743,14 @@ func NewMICTestClient(eventCh chan internalaadpodid.EventType,
 		PodClient:            podClient,
 		EventChannel:         eventCh,
 		NodeClient:           nodeClient,
+		NamespaceClient:      NewTestNamespaceClient(),
 		syncRetryInterval:    120 * time.Second,
 		IsNamespaced:         isNamespaced,
 		createDeleteBatch:    createDeleteBatch,
 		ImmutableUserMSIsMap: immutableUserMSIs,
 		Reporter:             reporter,
+		status:               newStatusTracker(),
+		gcCandidates:         make(map[string]map[string]bool),
 	}
 
 	return &TestMICClient{
@@ -921,10 +1051,9 @@ func TestAddDelMICClient(t *testing.T) {
 		klog.Errorf("Expected len: %d. Got: %d", expectedLen, gotLen)
 		t.Fatalf("Add and delete id at same time mismatch")
 	} else {
-		gotID := (*listAssignedIDs)[0].Name
-		expectedID := "test-pod3-default-test-id3"
-		if gotID != expectedID {
-			klog.Errorf("Expected %s. Got: %s", expectedID, gotID)
+		gotID := (*listAssignedIDs)[0]
+		if gotID.Spec.Pod != "test-pod3" || gotID.Spec.AzureIdentityRef.Name != "test-id3" {
+			klog.Errorf("Expected test-pod3 with test-id3. Got: %s with %s", gotID.Spec.Pod, gotID.Spec.AzureIdentityRef.Name)
 			t.Fatalf("Add and delete id at same time. Found wrong id")
 		}
 	}
@@ -1538,3 +1667,1164 @@ func TestMicAddDelVMSSwithImmutableIdentities(t *testing.T) {
 		t.Fatalf("missing identity: %+v", cloudClient.ListMSI()["testvmss2"])
 	}
 }
+
+func TestCreateDesiredAssignedIdentityListWithLabelSelector(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	var evtRecorder TestEventRecorder
+	evtRecorder.lastEvent = new(LastEvent)
+	evtRecorder.eventChannel = make(chan bool, 100)
+	micClient := NewMICTestClient(eventCh, NewTestCloudClient(config.AzureConfig{}), NewTestCrdClient(nil), NewTestPodClient(),
+		NewTestNodeClient(), &evtRecorder, false, 4, nil)
+	nsClient := micClient.NamespaceClient.(*TestNamespaceClient)
+	nsClient.AddNamespace("team-a", map[string]string{"team": "a"})
+	nsClient.AddNamespace("team-b", map[string]string{"team": "b"})
+
+	newPod := func(name, ns string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: ns, Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: "node"},
+		}
+	}
+	pods := []*corev1.Pod{
+		newPod("shorthand", "team-a", map[string]string{internalaadpodid.CRDLabelKey: "select_it"}),
+		newPod("frontend", "team-a", map[string]string{"app": "frontend"}),
+		newPod("backend", "team-a", map[string]string{"app": "backend", "tier": "api"}),
+		newPod("backend", "team-b", map[string]string{"app": "backend", "tier": "api"}),
+		newPod("combined", "team-b", map[string]string{internalaadpodid.CRDLabelKey: "select_it", "app": "frontend"}),
+		newPod("other", "team-b", map[string]string{internalaadpodid.CRDLabelKey: "select_other"}),
+	}
+
+	idMap := map[string]internalaadpodid.AzureIdentity{
+		getIDKey("default", "test-id"): {ObjectMeta: v1.ObjectMeta{Name: "test-id", Namespace: "default"}},
+	}
+	bindings := []internalaadpodid.AzureIdentityBinding{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "shorthand", Namespace: "default"},
+			Spec:       internalaadpodid.AzureIdentityBindingSpec{AzureIdentity: "test-id", Selector: "select_it"},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "label-selector", Namespace: "default"},
+			Spec: internalaadpodid.AzureIdentityBindingSpec{
+				AzureIdentity: "test-id",
+				LabelSelector: &v1.LabelSelector{
+					MatchLabels: map[string]string{"app": "backend"},
+					MatchExpressions: []v1.LabelSelectorRequirement{
+						{Key: "tier", Operator: v1.LabelSelectorOpIn, Values: []string{"api", "web"}},
+					},
+				},
+				NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "combined", Namespace: "default"},
+			Spec: internalaadpodid.AzureIdentityBindingSpec{
+				AzureIdentity: "test-id",
+				Selector:      "select_it",
+				LabelSelector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "no-selector", Namespace: "default"},
+			Spec:       internalaadpodid.AzureIdentityBindingSpec{AzureIdentity: "test-id"},
+		},
+		{
+			// an empty label selector matches no pods instead of all of them
+			ObjectMeta: v1.ObjectMeta{Name: "empty-label-selector", Namespace: "default"},
+			Spec:       internalaadpodid.AzureIdentityBindingSpec{AzureIdentity: "test-id", LabelSelector: &v1.LabelSelector{}},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "shorthand-empty-label-selector", Namespace: "default"},
+			Spec: internalaadpodid.AzureIdentityBindingSpec{
+				AzureIdentity: "test-id",
+				Selector:      "select_other",
+				LabelSelector: &v1.LabelSelector{},
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "invalid", Namespace: "default"},
+			Spec: internalaadpodid.AzureIdentityBindingSpec{
+				AzureIdentity: "test-id",
+				LabelSelector: &v1.LabelSelector{
+					MatchExpressions: []v1.LabelSelectorRequirement{{Key: "app", Operator: "Invalid"}},
+				},
+			},
+		},
+	}
+
+	assignedIDs, _, err := micClient.createDesiredAssignedIdentityList(pods, &bindings, idMap)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	matched := make(map[string]bool)
+	for _, assignedID := range assignedIDs {
+		matched[assignedID.Spec.PodNamespace+"/"+assignedID.Spec.Pod] = true
+	}
+	expected := map[string]bool{
+		"team-a/shorthand": true,
+		"team-a/backend":   true,
+		"team-b/combined":  true,
+		"team-b/other":     true,
+	}
+	if !reflect.DeepEqual(matched, expected) {
+		t.Fatalf("expected matched pods %v, got: %v", expected, matched)
+	}
+	if !evtRecorder.WaitForEvents(1) {
+		t.Fatalf("timeout waiting for the binding selector error event")
+	}
+	if evtRecorder.lastEvent.Type != corev1.EventTypeWarning || evtRecorder.lastEvent.Reason != "binding selector error" {
+		t.Fatalf("expected binding selector error event, got: %+v", evtRecorder.lastEvent)
+	}
+}
+
+func TestCreateDesiredAssignedIdentityListWithBindingsAnnotation(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	micClient := NewMICTestClient(eventCh, NewTestCloudClient(config.AzureConfig{}), NewTestCrdClient(nil), NewTestPodClient(),
+		NewTestNodeClient(), &TestEventRecorder{}, false, 4, nil)
+
+	pods := []*corev1.Pod{
+		{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "pod",
+				Namespace:   "default",
+				Labels:      map[string]string{internalaadpodid.CRDLabelKey: "storage"},
+				Annotations: map[string]string{internalaadpodid.BindingsAnnotationKey: "keyvault, ,monitoring"},
+			},
+			Spec: corev1.PodSpec{NodeName: "node"},
+		},
+	}
+
+	idMap := make(map[string]internalaadpodid.AzureIdentity)
+	var bindings []internalaadpodid.AzureIdentityBinding
+	for _, name := range []string{"storage", "keyvault", "monitoring", "other"} {
+		idMap[getIDKey("default", name+"-id")] = internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: name + "-id", Namespace: "default"}}
+		bindings = append(bindings, internalaadpodid.AzureIdentityBinding{
+			ObjectMeta: v1.ObjectMeta{Name: name + "-binding", Namespace: "default"},
+			Spec:       internalaadpodid.AzureIdentityBindingSpec{AzureIdentity: name + "-id", Selector: name},
+		})
+	}
+
+	assignedIDs, _, err := micClient.createDesiredAssignedIdentityList(pods, &bindings, idMap)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	matched := make(map[string]bool)
+	for _, assignedID := range assignedIDs {
+		matched[assignedID.Spec.AzureIdentityRef.Name] = true
+	}
+	expected := map[string]bool{
+		"storage-id":    true,
+		"keyvault-id":   true,
+		"monitoring-id": true,
+	}
+	if !reflect.DeepEqual(matched, expected) {
+		t.Fatalf("expected identities %v, got: %v", expected, matched)
+	}
+}
+
+func TestMakeAssignedIDsOwnerReference(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "pod", Namespace: "team", UID: "pod-uid"},
+		Spec:       corev1.PodSpec{NodeName: "node"},
+	}
+
+	cases := []struct {
+		name         string
+		isNamespaced bool
+		id           internalaadpodid.AzureIdentity
+		binding      internalaadpodid.AzureIdentityBinding
+	}{
+		{
+			name:    "identity of another namespace than the pod in non-namespaced mode",
+			id:      internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id", Namespace: "default"}},
+			binding: internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "default"}},
+		},
+		{
+			name:    "identity in the namespace of the pod in non-namespaced mode",
+			id:      internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id", Namespace: "team"}},
+			binding: internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "team"}},
+		},
+		{
+			name: "namespaced identity",
+			id: internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{
+				Name:        "id",
+				Namespace:   "team",
+				Annotations: map[string]string{internalaadpodid.BehaviorKey: internalaadpodid.BehaviorNamespaced},
+			}},
+			binding: internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "team"}},
+		},
+		{
+			name:         "identity in namespaced mode",
+			isNamespaced: true,
+			id:           internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id", Namespace: "team"}},
+			binding:      internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "team"}},
+		},
+	}
+	for _, tc := range cases {
+		eventCh := make(chan internalaadpodid.EventType, 100)
+		micClient := NewMICTestClient(eventCh, NewTestCloudClient(config.AzureConfig{}), NewTestCrdClient(nil), NewTestPodClient(),
+			NewTestNodeClient(), &TestEventRecorder{}, tc.isNamespaced, 4, nil)
+
+		assignedID, err := micClient.makeAssignedIDs(tc.id, tc.binding, pod)
+		if err != nil {
+			t.Fatalf("%s: expected nil error, got: %v", tc.name, err)
+		}
+		if assignedID.Namespace != pod.Namespace {
+			t.Errorf("%s: expected assigned id in namespace %s, got: %s", tc.name, pod.Namespace, assignedID.Namespace)
+		}
+		if assignedID.Spec.PodUID != pod.UID {
+			t.Errorf("%s: expected pod uid %s, got: %s", tc.name, pod.UID, assignedID.Spec.PodUID)
+		}
+		if !reflect.DeepEqual(assignedID.Finalizers, []string{internalaadpodid.AssignedIDFinalizer}) {
+			t.Errorf("%s: expected finalizer %s, got: %v", tc.name, internalaadpodid.AssignedIDFinalizer, assignedID.Finalizers)
+		}
+		expected := []v1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "pod", UID: pod.UID}}
+		if !reflect.DeepEqual(assignedID.OwnerReferences, expected) {
+			t.Errorf("%s: expected owner references %+v, got: %+v", tc.name, expected, assignedID.OwnerReferences)
+		}
+	}
+}
+
+func TestAssignedIDsForRecreatedPod(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	micClient := NewMICTestClient(eventCh, NewTestCloudClient(config.AzureConfig{}), NewTestCrdClient(nil), NewTestPodClient(),
+		NewTestNodeClient(), &TestEventRecorder{}, false, 4, nil)
+
+	newAssignedID := func(podUID types.UID) internalaadpodid.AzureAssignedIdentity {
+		return internalaadpodid.AzureAssignedIdentity{
+			ObjectMeta: v1.ObjectMeta{Name: "assignedid"},
+			Spec: internalaadpodid.AzureAssignedIdentitySpec{
+				AzureIdentityRef: &internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id"}},
+				AzureBindingRef:  &internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding"}},
+				Pod:              "pod",
+				PodNamespace:     "default",
+				NodeName:         "node",
+				PodUID:           podUID,
+			},
+			Status: internalaadpodid.AzureAssignedIdentityStatus{Status: internalaadpodid.AssignedIDAssigned},
+		}
+	}
+
+	cases := []struct {
+		name          string
+		oldPodUID     types.UID
+		expectReplace bool
+	}{
+		{name: "same pod", oldPodUID: "uid"},
+		{name: "assigned identity created before the pod uid was recorded", oldPodUID: ""},
+		{name: "pod recreated with the same name", oldPodUID: "old-uid", expectReplace: true},
+	}
+	for _, tc := range cases {
+		old := map[string]internalaadpodid.AzureAssignedIdentity{"assignedid": newAssignedID(tc.oldPodUID)}
+		desired := map[string]internalaadpodid.AzureAssignedIdentity{"assignedid": newAssignedID("uid")}
+
+		create, err := micClient.getAzureAssignedIDsToCreate(old, desired)
+		if err != nil {
+			t.Fatalf("%s: expected nil error, got: %v", tc.name, err)
+		}
+		del, err := micClient.getAzureAssignedIDsToDelete(old, desired)
+		if err != nil {
+			t.Fatalf("%s: expected nil error, got: %v", tc.name, err)
+		}
+		if replaced := len(create) == 1 && len(del) == 1; replaced != tc.expectReplace || len(create) != len(del) {
+			t.Errorf("%s: expected replace %v, got create: %v, delete: %v", tc.name, tc.expectReplace, create, del)
+		}
+	}
+}
+
+func TestGetAssignedIDName(t *testing.T) {
+	micClient := NewMICTestClient(make(chan internalaadpodid.EventType, 100), NewTestCloudClient(config.AzureConfig{}), NewTestCrdClient(nil), NewTestPodClient(),
+		NewTestNodeClient(), &TestEventRecorder{}, false, 4, nil)
+
+	id := &internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id", Namespace: "default"}}
+	binding := &internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "default"}}
+	otherBinding := &internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "default"}}
+	long := strings.Repeat("a", 253)
+
+	name := micClient.getAssignedIDName("pod", "ns", id, binding)
+	if !strings.HasPrefix(name, "pod-ns-id-") {
+		t.Errorf("expected name with prefix pod-ns-id-, got: %s", name)
+	}
+	if again := micClient.getAssignedIDName("pod", "ns", id, binding); again != name {
+		t.Errorf("expected the same name %s, got: %s", name, again)
+	}
+
+	names := []string{
+		name,
+		micClient.getAssignedIDName("a-b", "c", id, binding),
+		micClient.getAssignedIDName("a", "b-c", id, binding),
+		micClient.getAssignedIDName("pod", "ns", id, otherBinding),
+		micClient.getAssignedIDName(long, "ns", id, binding),
+		micClient.getAssignedIDName(long+"b", "ns", id, binding),
+		micClient.getAssignedIDName(strings.Repeat("a", 44)+"-b", "ns", id, binding),
+	}
+	seen := make(map[string]bool)
+	for _, n := range names {
+		if seen[n] {
+			t.Errorf("expected unique names, got %s twice in %v", n, names)
+		}
+		seen[n] = true
+		if len(n) > validation.LabelValueMaxLength {
+			t.Errorf("expected name %s to be at most %d characters", n, validation.LabelValueMaxLength)
+		}
+		if errs := validation.IsDNS1123Subdomain(n); len(errs) != 0 {
+			t.Errorf("expected valid name, got %s: %v", n, errs)
+		}
+	}
+}
+
+func TestMigrateAssignedIDNames(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		eventCh := make(chan internalaadpodid.EventType, 100)
+		cloudClient := NewTestCloudClient(config.AzureConfig{})
+		crdClient := NewTestCrdClient(nil)
+		micClient := NewMICTestClient(eventCh, cloudClient, crdClient, NewTestPodClient(),
+			NewTestNodeClient(), &TestEventRecorder{}, false, 4, nil)
+		micClient.dryRun = dryRun
+
+		pod := &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: "pod", Namespace: "default", UID: "uid"},
+			Spec:       corev1.PodSpec{NodeName: "node"},
+		}
+		id := internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "id", Namespace: "default"}}
+		binding := internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "binding", Namespace: "default"}}
+		desired, err := micClient.makeAssignedIDs(id, binding, pod)
+		if err != nil {
+			t.Fatalf("expected nil error, got: %v", err)
+		}
+
+		// assigned identity created by an earlier release
+		legacy := *desired
+		legacy.Name = "pod-default-id"
+		legacy.Spec.PodUID = ""
+		legacy.Status.Status = internalaadpodid.AssignedIDAssigned
+		crdClient.CreateAssignedIdentity(&legacy)
+
+		current := map[string]internalaadpodid.AzureAssignedIdentity{legacy.Name: legacy}
+		newAssignedIDs := map[string]internalaadpodid.AzureAssignedIdentity{desired.Name: *desired}
+		micClient.migrateAssignedIDNames(current, newAssignedIDs)
+
+		if migrated, ok := current[desired.Name]; len(current) != 1 || !ok || migrated.Status.Status != internalaadpodid.AssignedIDAssigned {
+			t.Fatalf("dry run %v: expected assigned id %s in Assigned state, got: %+v", dryRun, desired.Name, current)
+		}
+		create, _ := micClient.getAzureAssignedIDsToCreate(current, newAssignedIDs)
+		del, _ := micClient.getAzureAssignedIDsToDelete(current, newAssignedIDs)
+		if len(create) != 0 || len(del) != 0 {
+			t.Fatalf("dry run %v: expected no assigned id to create or delete, got create: %v, delete: %v", dryRun, create, del)
+		}
+
+		expected := []string{desired.Name}
+		if dryRun {
+			expected = []string{legacy.Name}
+		}
+		var names []string
+		for name, assignedID := range crdClient.assignedIDMap {
+			names = append(names, name)
+			if assignedID.Status.Status != internalaadpodid.AssignedIDAssigned {
+				t.Errorf("dry run %v: expected assigned id %s in Assigned state, got: %s", dryRun, name, assignedID.Status.Status)
+			}
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("dry run %v: expected assigned ids %v, got: %v", dryRun, expected, names)
+		}
+	}
+}
+
+func TestSyncNodeKey(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	cloudClient := NewTestCloudClient(config.AzureConfig{})
+	crdClient := NewTestCrdClient(nil)
+	podClient := NewTestPodClient()
+	nodeClient := NewTestNodeClient()
+	var evtRecorder TestEventRecorder
+	evtRecorder.lastEvent = new(LastEvent)
+	evtRecorder.eventChannel = make(chan bool, 100)
+
+	micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, nil)
+
+	crdClient.CreateID("test-id1", "default", aadpodid.UserAssignedMSI, "test-user-msi-resourceid", "test-user-msi-clientid", nil, "", "", "", "")
+	crdClient.CreateBinding("testbinding1", "default", "test-id1", "test-select1", "")
+
+	nodeClient.AddNode("test-node1")
+	nodeClient.AddNode("test-node2")
+	nodeClient.AddNode("test-node3", func(n *corev1.Node) {
+		n.Spec.ProviderID = "azure:///subscriptions/fakeSub/resourceGroups/fakeGroup/providers/Microsoft.Compute/virtualMachineScaleSets/testvmss1/virtualMachines/0"
+	})
+	nodeClient.AddNode("test-node4", func(n *corev1.Node) {
+		n.Spec.ProviderID = "azure:///subscriptions/fakeSub/resourceGroups/fakeGroup/providers/Microsoft.Compute/virtualMachineScaleSets/testvmss1/virtualMachines/1"
+	})
+	podClient.AddPod("test-pod1", "default", "test-node1", "test-select1")
+	podClient.AddPod("test-pod2", "default", "test-node2", "test-select1")
+	podClient.AddPod("test-pod3", "default", "test-node3", "test-select1")
+	podClient.AddPod("test-pod4", "default", "test-node4", "test-select1")
+
+	vmssKey := vmssKeyPrefix + "fakeSub/fakeGroup/testvmss1"
+	for node, expected := range map[string]string{"test-node1": "test-node1", "test-node3": vmssKey, "test-node4": vmssKey, "deleted-node": "deleted-node"} {
+		if key := micClient.getNodeKey(node); key != expected {
+			t.Errorf("expected key of node %s to be %s, got: %s", node, expected, key)
+		}
+	}
+
+	assignedPods := func() map[string]bool {
+		listAssignedIDs, err := crdClient.ListAssignedIDs()
+		if err != nil {
+			t.Fatalf("expected nil error, got: %v", err)
+		}
+		pods := make(map[string]bool)
+		for _, assignedID := range *listAssignedIDs {
+			pods[assignedID.Spec.Pod] = true
+		}
+		return pods
+	}
+
+	// only the pods on the node of the key are reconciled
+	if err := micClient.syncKey("test-node1"); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if pods := assignedPods(); !reflect.DeepEqual(pods, map[string]bool{"test-pod1": true}) {
+		t.Fatalf("expected only test-pod1 to be assigned, got: %v", pods)
+	}
+
+	// all the nodes of the vmss are reconciled together
+	if err := micClient.syncKey(vmssKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if pods := assignedPods(); !reflect.DeepEqual(pods, map[string]bool{"test-pod1": true, "test-pod3": true, "test-pod4": true}) {
+		t.Fatalf("expected test-pod1, test-pod3 and test-pod4 to be assigned, got: %v", pods)
+	}
+
+	// deleting a pod on another node does not remove the assigned identities of the reconciled node
+	podClient.DeletePod("test-pod1", "default")
+	if err := micClient.syncKey("test-node2"); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if pods := assignedPods(); !reflect.DeepEqual(pods, map[string]bool{"test-pod1": true, "test-pod2": true, "test-pod3": true, "test-pod4": true}) {
+		t.Fatalf("expected all pods to be assigned, got: %v", pods)
+	}
+
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if pods := assignedPods(); !reflect.DeepEqual(pods, map[string]bool{"test-pod2": true, "test-pod3": true, "test-pod4": true}) {
+		t.Fatalf("expected test-pod1 to be removed, got: %v", pods)
+	}
+}
+
+func TestEnqueuePod(t *testing.T) {
+	nodeClient := NewTestNodeClient()
+	nodeClient.AddNode("test-node1")
+	nodeClient.AddNode("test-node2")
+	micClient := &Client{
+		NodeClient: nodeClient,
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	defer micClient.queue.ShutDown()
+
+	selectorLabels := map[string]string{aadpodid.CRDLabelKey: "select"}
+	micClient.enqueuePod(&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "unscheduled", Labels: selectorLabels}})
+	micClient.enqueuePod(&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "pod1", Labels: selectorLabels}, Spec: corev1.PodSpec{NodeName: "test-node1"}})
+	micClient.enqueuePod(&corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "pod2", Labels: selectorLabels}, Spec: corev1.PodSpec{NodeName: "test-node1"}})
+	// pods without binding selectors are ignored unless a binding uses a label selector
+	noSelector := &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "pod3", Labels: map[string]string{"app": "web"}}, Spec: corev1.PodSpec{NodeName: "test-node2"}}
+	micClient.enqueuePod(noSelector)
+
+	if micClient.queue.Len() != 1 {
+		t.Fatalf("expected 1 key in the queue, got: %d", micClient.queue.Len())
+	}
+	key, _ := micClient.queue.Get()
+	if key != "test-node1" {
+		t.Fatalf("expected key test-node1, got: %v", key)
+	}
+	micClient.queue.Done(key)
+
+	micClient.labelSelectorsInUse = 1
+	micClient.enqueuePod(noSelector)
+	if key, _ := micClient.queue.Get(); key != "test-node2" {
+		t.Fatalf("expected key test-node2, got: %v", key)
+	}
+}
+
+func TestFilterPods(t *testing.T) {
+	micClient := &Client{}
+	pods := []*corev1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: "label", Labels: map[string]string{aadpodid.CRDLabelKey: "select"}}},
+		{ObjectMeta: v1.ObjectMeta{Name: "annotation", Annotations: map[string]string{internalaadpodid.BindingsAnnotationKey: "select"}}},
+		{ObjectMeta: v1.ObjectMeta{Name: "condition"}, Status: corev1.PodStatus{Conditions: []corev1.PodCondition{
+			{Type: internalaadpodid.PodIdentityAssignedCondition, Status: corev1.ConditionTrue},
+		}}},
+		{ObjectMeta: v1.ObjectMeta{Name: "other", Labels: map[string]string{"app": "web"}}},
+	}
+	names := func(pods []*corev1.Pod) []string {
+		var names []string
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	bindings := []internalaadpodid.AzureIdentityBinding{
+		{Spec: internalaadpodid.AzureIdentityBindingSpec{Selector: "select"}},
+		// an empty label selector matches no pods
+		{Spec: internalaadpodid.AzureIdentityBindingSpec{LabelSelector: &v1.LabelSelector{}}},
+	}
+	if filtered := names(micClient.filterPods(pods, bindings)); !reflect.DeepEqual(filtered, []string{"label", "annotation", "condition"}) {
+		t.Fatalf("expected the pods with binding selectors or the identity assigned condition, got: %v", filtered)
+	}
+	if micClient.labelSelectorsInUse != 0 {
+		t.Fatal("expected label selectors not to be in use")
+	}
+
+	bindings = append(bindings, internalaadpodid.AzureIdentityBinding{Spec: internalaadpodid.AzureIdentityBindingSpec{
+		LabelSelector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}})
+	if filtered := names(micClient.filterPods(pods, bindings)); len(filtered) != len(pods) {
+		t.Fatalf("expected all the pods when a binding uses a label selector, got: %v", filtered)
+	}
+	if micClient.labelSelectorsInUse != 1 {
+		t.Fatal("expected label selectors to be in use")
+	}
+}
+
+func TestNamespaceLabelsChange(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "team", Labels: map[string]string{"team": "a"}}}
+	clientSet := kubefake.NewSimpleClientset(namespace)
+	informerFactory := informers.NewSharedInformerFactory(clientSet, 0)
+
+	changes := make(chan struct{}, 10)
+	namespaceClient := NewNamespaceClient(informerFactory.Core().V1().Namespaces(), func() { changes <- struct{}{} })
+	exit := make(chan struct{})
+	defer close(exit)
+	namespaceClient.Start(exit)
+
+	update := func(mutate func(ns *corev1.Namespace)) {
+		ns, err := clientSet.CoreV1().Namespaces().Get("team", v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected nil error, got: %v", err)
+		}
+		mutate(ns)
+		if _, err := clientSet.CoreV1().Namespaces().Update(ns); err != nil {
+			t.Fatalf("expected nil error, got: %v", err)
+		}
+	}
+
+	// only label changes can change the pods matched by the namespace selectors
+	update(func(ns *corev1.Namespace) { ns.Annotations = map[string]string{"note": "updated"} })
+	update(func(ns *corev1.Namespace) { ns.Labels["team"] = "b" })
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the namespace labels change")
+	}
+	select {
+	case <-changes:
+		t.Fatal("expected a single namespace labels change")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	cloudClient := NewTestCloudClient(config.AzureConfig{})
+	crdClient := NewTestCrdClient(nil)
+	podClient := NewTestPodClient()
+	nodeClient := NewTestNodeClient()
+	var evtRecorder TestEventRecorder
+	evtRecorder.lastEvent = new(LastEvent)
+	evtRecorder.eventChannel = make(chan bool, 100)
+
+	micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, nil)
+	micClient.dryRun = true
+
+	crdClient.CreateID("test-id1", "default", aadpodid.UserAssignedMSI, "test-user-msi-resourceid", "test-user-msi-clientid", nil, "", "", "", "")
+	crdClient.CreateBinding("testbinding1", "default", "test-id1", "test-select1", "")
+	nodeClient.AddNode("test-node1")
+	podClient.AddPod("test-pod1", "default", "test-node1", "test-select1")
+
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	listAssignedIDs, err := crdClient.ListAssignedIDs()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if len(*listAssignedIDs) != 0 {
+		t.Fatalf("expected no assigned identities in dry run mode, got: %d", len(*listAssignedIDs))
+	}
+	if ids := cloudClient.ListMSI()["test-node1"]; ids != nil && len(*ids) != 0 {
+		t.Fatalf("expected no identities assigned to the node in dry run mode, got: %v", *ids)
+	}
+}
+
+func TestCollectNodeGarbage(t *testing.T) {
+	for _, reportOnly := range []bool{false, true} {
+		eventCh := make(chan internalaadpodid.EventType, 100)
+		cloudClient := NewTestCloudClient(config.AzureConfig{})
+		crdClient := NewTestCrdClient(nil)
+		podClient := NewTestPodClient()
+		nodeClient := NewTestNodeClient()
+		var evtRecorder TestEventRecorder
+		evtRecorder.lastEvent = new(LastEvent)
+		evtRecorder.eventChannel = make(chan bool, 100)
+
+		micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, map[string]bool{"immutable-clientid": true})
+		micClient.gc.ReportOnly = reportOnly
+		micClient.kubeletIdentityClientID = "kubelet-clientid"
+
+		for _, name := range []string{"in-use", "orphaned", "immutable", "kubelet"} {
+			crdClient.CreateID(name+"-id", "default", aadpodid.UserAssignedMSI, name+"-resourceid", name+"-clientid", nil, "", "", "", "")
+		}
+		ids, _ := crdClient.ListIds()
+		for i := range *ids {
+			if (*ids)[i].Name != "in-use-id" {
+				continue
+			}
+			assignedID := internalaadpodid.AzureAssignedIdentity{
+				ObjectMeta: v1.ObjectMeta{Name: "in-use-assigned-id", Namespace: "default"},
+				Spec: internalaadpodid.AzureAssignedIdentitySpec{
+					AzureIdentityRef: &(*ids)[i],
+					NodeName:         "test-node1",
+				},
+			}
+			crdClient.CreateAssignedIdentity(&assignedID)
+		}
+		nodeClient.AddNode("test-node1")
+
+		// the identities of the same resource ids in another case are removed too
+		nodeIDs := []string{"in-use-resourceid", "ORPHANED-RESOURCEID", "immutable-resourceid", "kubelet-resourceid", "unmanaged-resourceid"}
+		if err := cloudClient.UpdateUserMSI(nodeIDs, nil, "test-node1", false); err != nil {
+			t.Fatalf("expected nil error, got: %v", err)
+		}
+
+		// the identity is only removed if still left at the next garbage collection
+		if err := micClient.collectNodeGarbage("test-node1"); err != nil {
+			t.Fatalf("report only %v: expected nil error, got: %v", reportOnly, err)
+		}
+		if !cloudClient.CompareMSI("test-node1", nodeIDs) {
+			t.Fatalf("report only %v: expected identities %v on the node after the first garbage collection, got: %v", reportOnly, nodeIDs, cloudClient.ListMSI()["test-node1"])
+		}
+
+		if err := micClient.collectNodeGarbage("test-node1"); err != nil {
+			t.Fatalf("report only %v: expected nil error, got: %v", reportOnly, err)
+		}
+		expected := []string{"in-use-resourceid", "immutable-resourceid", "kubelet-resourceid", "unmanaged-resourceid"}
+		if reportOnly {
+			expected = nodeIDs
+		}
+		if !cloudClient.CompareMSI("test-node1", expected) {
+			t.Fatalf("report only %v: expected identities %v on the node, got: %v", reportOnly, expected, *cloudClient.ListMSI()["test-node1"])
+		}
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	nodeClient := NewTestNodeClient()
+	nodeClient.AddNode("test-node1")
+	nodeClient.AddNode("test-node2")
+	nodeClient.AddNode("test-node3", func(n *corev1.Node) {
+		n.Spec.ProviderID = "azure:///subscriptions/testSub/resourceGroups/fakeGroup/providers/Microsoft.Compute/virtualMachineScaleSets/testvmss/virtualMachines/0"
+	})
+	nodeClient.AddNode("test-node4", func(n *corev1.Node) {
+		n.Spec.ProviderID = "azure:///subscriptions/testSub/resourceGroups/fakeGroup/providers/Microsoft.Compute/virtualMachineScaleSets/testvmss/virtualMachines/1"
+	})
+	micClient := &Client{
+		NodeClient:   nodeClient,
+		queue:        workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		gcCandidates: map[string]map[string]bool{"removed-node": {"orphaned-resourceid": true}},
+	}
+	defer micClient.queue.ShutDown()
+
+	if err := micClient.syncKey(gcKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	expected := []string{gcKeyPrefix + "test-node1", gcKeyPrefix + "test-node2", gcKeyPrefix + vmssKeyPrefix + "testSub/fakeGroup/testvmss"}
+	if micClient.queue.Len() != len(expected) {
+		t.Fatalf("expected %d keys in the queue, got: %d", len(expected), micClient.queue.Len())
+	}
+	keys := make(map[string]bool)
+	for micClient.queue.Len() > 0 {
+		key, _ := micClient.queue.Get()
+		keys[key.(string)] = true
+		micClient.queue.Done(key)
+	}
+	for _, key := range expected {
+		if !keys[key] {
+			t.Errorf("expected key %s in the queue, got: %v", key, keys)
+		}
+	}
+	if len(micClient.gcCandidates) != 0 {
+		t.Errorf("expected the identities seen on removed nodes to be forgotten, got: %v", micClient.gcCandidates)
+	}
+}
+
+func TestNewSyncPlan(t *testing.T) {
+	newAssignedID := func(name, node, status string) internalaadpodid.AzureAssignedIdentity {
+		return internalaadpodid.AzureAssignedIdentity{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: internalaadpodid.AzureAssignedIdentitySpec{
+				AzureIdentityRef: &internalaadpodid.AzureIdentity{ObjectMeta: v1.ObjectMeta{Name: "test-id", Namespace: "default"}},
+				AzureBindingRef:  &internalaadpodid.AzureIdentityBinding{ObjectMeta: v1.ObjectMeta{Name: "test-binding", Namespace: "default"}},
+				Pod:              name,
+				PodNamespace:     "default",
+				NodeName:         node,
+			},
+			Status: internalaadpodid.AzureAssignedIdentityStatus{Status: status},
+		}
+	}
+
+	nodeMap := map[string]trackUserAssignedMSIIds{
+		"testvmss": {
+			addUserAssignedMSIIDs:    []string{"id2", "id1", "id2"},
+			removeUserAssignedMSIIDs: []string{"id3"},
+			assignedIDsToCreate:      []internalaadpodid.AzureAssignedIdentity{newAssignedID("pod2", "vmss-node", ""), newAssignedID("pod1", "vmss-node", internalaadpodid.AssignedIDCreated)},
+			assignedIDsToDelete:      []internalaadpodid.AzureAssignedIdentity{newAssignedID("pod3", "vmss-node", internalaadpodid.AssignedIDAssigned)},
+			isvmss:                   true,
+		},
+		"deleted-node": {
+			removeUserAssignedMSIIDs: []string{"id1"},
+			assignedIDsToDelete:      []internalaadpodid.AzureAssignedIdentity{newAssignedID("pod4", "deleted-node", internalaadpodid.AssignedIDAssigned)},
+			nodeNotFound:             true,
+		},
+	}
+
+	plan := newSyncPlan(nodeMap)
+	expected := syncPlan{
+		Nodes: []nodePlan{
+			{
+				Name:         "deleted-node",
+				NodeNotFound: true,
+				AssignedIDsToDelete: []assignedIDPlan{
+					{Name: "pod4", Namespace: "default", Pod: "pod4", PodNamespace: "default", NodeName: "deleted-node", Identity: "default/test-id", Binding: "default/test-binding", Status: internalaadpodid.AssignedIDAssigned},
+				},
+			},
+			{
+				Name:                     "testvmss",
+				VMSS:                     true,
+				AddUserAssignedMSIIDs:    []string{"id1", "id2"},
+				RemoveUserAssignedMSIIDs: []string{"id3"},
+				AssignedIDsToCreate: []assignedIDPlan{
+					{Name: "pod1", Namespace: "default", Pod: "pod1", PodNamespace: "default", NodeName: "vmss-node", Identity: "default/test-id", Binding: "default/test-binding", Status: internalaadpodid.AssignedIDCreated},
+					{Name: "pod2", Namespace: "default", Pod: "pod2", PodNamespace: "default", NodeName: "vmss-node", Identity: "default/test-id", Binding: "default/test-binding"},
+				},
+				AssignedIDsToDelete: []assignedIDPlan{
+					{Name: "pod3", Namespace: "default", Pod: "pod3", PodNamespace: "default", NodeName: "vmss-node", Identity: "default/test-id", Binding: "default/test-binding", Status: internalaadpodid.AssignedIDAssigned},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Fatalf("expected plan %+v, got: %+v", expected, plan)
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	cloudClient := NewTestCloudClient(config.AzureConfig{})
+	crdClient := NewTestCrdClient(nil)
+	podClient := NewTestPodClient()
+	nodeClient := NewTestNodeClient()
+	var evtRecorder TestEventRecorder
+	evtRecorder.lastEvent = new(LastEvent)
+	evtRecorder.eventChannel = make(chan bool, 100)
+
+	micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, nil)
+
+	crdClient.CreateID("test-id1", "default", aadpodid.UserAssignedMSI, "test-user-msi-resourceid", "test-user-msi-clientid", nil, "", "", "", "")
+	crdClient.CreateID("test-id2", "default", aadpodid.UserAssignedMSI, "test-user-msi-resourceid2", "test-user-msi-clientid2", nil, "", "", "", "")
+	crdClient.CreateBinding("testbinding1", "default", "test-id1", "test-select1", "")
+	nodeClient.AddNode("test-node1")
+	nodeClient.AddNode("test-node2")
+	podClient.AddPod("test-pod1", "default", "test-node1", "test-select1")
+	podClient.AddPod("test-pod2", "default", "test-node2", "test-select1")
+
+	identityStatus := func(name string) aadpodid.AzureIdentityStatus {
+		crdClient.mu.Lock()
+		defer crdClient.mu.Unlock()
+		return crdClient.idMap[getIDKey("default", name)].Status
+	}
+	condition := func(conditions []aadpodid.IdentityCondition, conditionType aadpodid.IdentityConditionType) aadpodid.IdentityCondition {
+		for _, c := range conditions {
+			if c.Type == conditionType {
+				return c
+			}
+		}
+		t.Fatalf("expected condition %s in %v", conditionType, conditions)
+		return aadpodid.IdentityCondition{}
+	}
+
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	status := identityStatus("test-id1")
+	if status.Pods != 2 || status.AvailableReplicas != 2 || !reflect.DeepEqual(status.Nodes, []string{"test-node1", "test-node2"}) {
+		t.Fatalf("expected test-id1 to be assigned to 2 pods on test-node1 and test-node2, got: %+v", status)
+	}
+	ready := condition(status.Conditions, aadpodid.IdentityReady)
+	if ready.Status != corev1.ConditionTrue || condition(status.Conditions, aadpodid.IdentityError).Status != corev1.ConditionFalse {
+		t.Fatalf("expected test-id1 to be ready without error, got: %+v", status.Conditions)
+	}
+	crdClient.mu.Lock()
+	bindingStatus := crdClient.bindingMap[getIDKey("default", "testbinding1")].Status
+	crdClient.mu.Unlock()
+	if bindingStatus.Pods != 2 || condition(bindingStatus.Conditions, aadpodid.IdentityAssigned).Status != corev1.ConditionTrue {
+		t.Fatalf("expected testbinding1 to be assigned to 2 pods, got: %+v", bindingStatus)
+	}
+	if status := identityStatus("test-id2"); status.Pods != 0 || condition(status.Conditions, aadpodid.IdentityAssigned).Reason != reasonNoMatchingPods {
+		t.Fatalf("expected test-id2 not to be used by any pod, got: %+v", status)
+	}
+
+	// a failure to assign the identity to a node is reported until the identity is assigned again
+	cloudClient.SetError(errors.New("error assigning identity"))
+	nodeClient.AddNode("test-node3")
+	podClient.AddPod("test-pod3", "default", "test-node3", "test-select1")
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	status = identityStatus("test-id1")
+	if status.Pods != 3 || status.AvailableReplicas != 2 || status.LastError != "error assigning identity" {
+		t.Fatalf("expected test-id1 to fail being assigned to test-pod3, got: %+v", status)
+	}
+	if c := condition(status.Conditions, aadpodid.IdentityReady); c.Status != corev1.ConditionFalse || c.Reason != reasonCloudProviderError {
+		t.Fatalf("expected test-id1 not to be ready, got: %+v", c)
+	}
+	if c := condition(status.Conditions, aadpodid.IdentityError); c.Status != corev1.ConditionTrue {
+		t.Fatalf("expected test-id1 to have an error, got: %+v", c)
+	}
+
+	cloudClient.UnSetError()
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	status = identityStatus("test-id1")
+	if status.Pods != 3 || status.AvailableReplicas != 3 || status.LastError != "" {
+		t.Fatalf("expected test-id1 to be assigned to all pods, got: %+v", status)
+	}
+	if c := condition(status.Conditions, aadpodid.IdentityReady); c.Status != corev1.ConditionTrue {
+		t.Fatalf("expected test-id1 to be ready again, got: %+v", c)
+	}
+}
+
+func TestPodReadiness(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	cloudClient := NewTestCloudClient(config.AzureConfig{})
+	crdClient := NewTestCrdClient(nil)
+	podClient := NewTestPodClient()
+	nodeClient := NewTestNodeClient()
+	var evtRecorder TestEventRecorder
+	evtRecorder.lastEvent = new(LastEvent)
+	evtRecorder.eventChannel = make(chan bool, 100)
+
+	micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, nil)
+	micClient.podReadinessCondition = true
+
+	crdClient.CreateID("test-id1", "default", aadpodid.UserAssignedMSI, "test-user-msi-resourceid", "test-user-msi-clientid", nil, "", "", "", "")
+	crdClient.CreateBinding("testbinding1", "default", "test-id1", "test-select1", "")
+	nodeClient.AddNode("test-node1")
+	nodeClient.AddNode("test-node2")
+	podClient.AddPod("test-pod1", "default", "test-node1", "test-select1")
+	podClient.AddPod("test-pod2", "default", "test-node1", "no-binding")
+
+	checkCondition := func(podName string, status corev1.ConditionStatus, reason string) {
+		t.Helper()
+		c := podClient.getPodCondition(podName, "default")
+		if c == nil || c.Status != status || c.Reason != reason {
+			t.Fatalf("expected %s condition of %s to be %s with reason %s, got: %+v", internalaadpodid.PodIdentityAssignedCondition, podName, status, reason, c)
+		}
+	}
+
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	checkCondition("test-pod1", corev1.ConditionTrue, reasonAssigned)
+	if c := podClient.getPodCondition("test-pod2", "default"); c != nil {
+		t.Fatalf("expected test-pod2 without binding to be left untouched, got: %+v", c)
+	}
+
+	// the condition is only set when it changes
+	updates := podClient.conditionUpdates
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if podClient.conditionUpdates != updates {
+		t.Fatalf("expected no condition update, got %d updates", podClient.conditionUpdates-updates)
+	}
+
+	// the condition is false until the identity is assigned to the node of the pod
+	cloudClient.SetError(errors.New("error assigning identity"))
+	podClient.AddPod("test-pod3", "default", "test-node2", "test-select1")
+	if err := micClient.syncKey("test-node2"); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	checkCondition("test-pod3", corev1.ConditionFalse, reasonCloudProviderError)
+
+	cloudClient.UnSetError()
+	if err := micClient.syncKey("test-node2"); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	checkCondition("test-pod3", corev1.ConditionTrue, reasonAssigned)
+
+	// pods no longer matched by a binding are no longer ready
+	crdClient.CreateBinding("testbinding1", "default", "test-id1", "test-select2", "")
+	if err := micClient.syncKey(fullSyncKey); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	checkCondition("test-pod1", corev1.ConditionFalse, reasonNoMatchingBindings)
+	checkCondition("test-pod3", corev1.ConditionFalse, reasonNoMatchingBindings)
+}
+
+func TestLeaseLock(t *testing.T) {
+	var mu sync.Mutex
+	var stored []byte
+	resourceVersion := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/coordination.k8s.io/v1/namespaces/default/leases/mic":
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+				return
+			}
+			w.Write(stored)
+		case r.Method == http.MethodPost && r.URL.Path == "/apis/coordination.k8s.io/v1/namespaces/default/leases",
+			r.Method == http.MethodPut && r.URL.Path == "/apis/coordination.k8s.io/v1/namespaces/default/leases/mic":
+			l := &lease{}
+			if err := json.NewDecoder(r.Body).Decode(l); err != nil {
+				t.Errorf("expected a lease, got error: %v", err)
+			}
+			if l.Kind != "Lease" || l.APIVersion != "coordination.k8s.io/v1" {
+				t.Errorf("expected a coordination.k8s.io/v1 Lease, got: %s %s", l.APIVersion, l.Kind)
+			}
+			if r.Method == http.MethodPut && l.ResourceVersion != strconv.Itoa(resourceVersion) {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"Conflict","code":409}`)
+				return
+			}
+			resourceVersion++
+			l.ResourceVersion = strconv.Itoa(resourceVersion)
+			stored, _ = json.Marshal(l)
+			w.Write(stored)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	lock, err := newResourceLock(LeasesResourceLock, "default", "mic", nil, &rest.Config{Host: server.URL}, resourcelock.ResourceLockConfig{Identity: "mic-1"})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if _, err := lock.Get(); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	now := v1.NewTime(time.Now().Truncate(time.Second))
+	record := resourcelock.LeaderElectionRecord{HolderIdentity: "mic-1", LeaseDurationSeconds: 15, AcquireTime: now, RenewTime: now}
+	if err := lock.Create(record); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	record.RenewTime = v1.NewTime(now.Add(time.Second))
+	if err := lock.Update(record); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	got, err := lock.Get()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if got.HolderIdentity != "mic-1" || got.LeaseDurationSeconds != 15 || !got.RenewTime.Equal(&record.RenewTime) || !got.AcquireTime.Equal(&now) {
+		t.Fatalf("expected record %+v, got: %+v", record, *got)
+	}
+
+	// a lease updated since it was read is not overwritten
+	mu.Lock()
+	resourceVersion++
+	mu.Unlock()
+	if err := lock.Update(record); !apierrors.IsConflict(err) {
+		t.Fatalf("expected conflict error, got: %v", err)
+	}
+}
+
+// testLock is an in memory leader election lock
+type testLock struct {
+	identity string
+	mu       sync.Mutex
+	record   *resourcelock.LeaderElectionRecord
+}
+
+func (l *testLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.record == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "leases"}, "mic")
+	}
+	record := *l.record
+	return &record, nil
+}
+
+func (l *testLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record = &ler
+	return nil
+}
+
+func (l *testLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record = &ler
+	return nil
+}
+
+func (l *testLock) RecordEvent(string) {}
+
+func (l *testLock) Identity() string {
+	return l.identity
+}
+
+func (l *testLock) Describe() string {
+	return "default/mic"
+}
+
+func TestReleasableLock(t *testing.T) {
+	shared := &testLock{identity: "mic-1"}
+	lock1 := &releasableLock{Interface: shared}
+	lock2 := &releasableLock{Interface: &testLock{identity: "mic-2"}}
+
+	if released, err := lock1.release(); err != nil || released {
+		t.Fatalf("expected a lock not found to not be released, got: %v, %v", released, err)
+	}
+
+	shared.record = &resourcelock.LeaderElectionRecord{HolderIdentity: "mic-2", LeaseDurationSeconds: 15, LeaderTransitions: 1}
+	if released, err := lock1.release(); err != nil || released {
+		t.Fatalf("expected a lock held by another instance to not be released, got: %v, %v", released, err)
+	}
+
+	shared.record.HolderIdentity = "mic-1"
+	if released, err := lock1.release(); err != nil || !released {
+		t.Fatalf("expected the lock to be released, got: %v, %v", released, err)
+	}
+	if shared.record.HolderIdentity != "" {
+		t.Fatalf("expected the holder of the released lock to be cleared, got: %s", shared.record.HolderIdentity)
+	}
+	if err := lock1.Update(*shared.record); err != errLockReleased {
+		t.Fatalf("expected the released lock to not be renewed, got: %v", err)
+	}
+	if record, _ := lock1.Get(); record.HolderIdentity != "" {
+		t.Fatalf("expected the released lock to not be taken over by the instance releasing it, got: %s", record.HolderIdentity)
+	}
+
+	// another instance takes over the released lock immediately
+	lock2.Interface.(*testLock).record = shared.record
+	record, err := lock2.Get()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if record.HolderIdentity != "mic-2" || record.LeaderTransitions != 2 {
+		t.Fatalf("expected the released lock to be reported as held by mic-2 after 2 transitions, got: %+v", *record)
+	}
+}
+
+func TestStopSync(t *testing.T) {
+	eventCh := make(chan internalaadpodid.EventType, 100)
+	cloudClient := NewTestCloudClient(config.AzureConfig{})
+	crdClient := NewTestCrdClient(nil)
+	podClient := NewTestPodClient()
+	nodeClient := NewTestNodeClient()
+	var evtRecorder TestEventRecorder
+	evtRecorder.lastEvent = new(LastEvent)
+	evtRecorder.eventChannel = make(chan bool, 100)
+
+	micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, nil)
+	micClient.syncStopped = make(chan struct{})
+
+	stop := make(chan struct{})
+	micClient.startSync(stop)
+	close(stop)
+
+	select {
+	case <-micClient.syncStopped:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("expected the sync loop to stop once the leadership is lost")
+	}
+	// stopping an already stopped sync loop returns immediately
+	micClient.stopSync()
+}
+
+// failingLock is a test lock which fails to renew the lease once fail is set
+type failingLock struct {
+	*testLock
+	fail int32
+}
+
+func (l *failingLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if atomic.LoadInt32(&l.fail) == 1 {
+		return errors.New("renew failed")
+	}
+	return l.testLock.Update(ler)
+}
+
+func TestRunStopsOnLostLease(t *testing.T) {
+	for _, shutdown := range []bool{false, true} {
+		eventCh := make(chan internalaadpodid.EventType, 100)
+		cloudClient := NewTestCloudClient(config.AzureConfig{})
+		crdClient := NewTestCrdClient(nil)
+		podClient := NewTestPodClient()
+		nodeClient := NewTestNodeClient()
+		var evtRecorder TestEventRecorder
+		evtRecorder.lastEvent = new(LastEvent)
+		evtRecorder.eventChannel = make(chan bool, 100)
+
+		micClient := NewMICTestClient(eventCh, cloudClient, crdClient, podClient, nodeClient, &evtRecorder, false, 4, nil)
+		micClient.syncStopped = make(chan struct{})
+		micClient.LeaderElectionConfig = &LeaderElectionConfig{Duration: time.Second}
+		lock := &failingLock{testLock: &testLock{identity: "mic-1"}}
+		micClient.resourceLock = &releasableLock{Interface: lock}
+		leaderElector, err := leaderelection.NewLeaderElector(micClient.leaderElectionConfig())
+		if err != nil {
+			t.Fatalf("expected nil error, got: %v", err)
+		}
+		micClient.leaderElector = leaderElector
+
+		done := make(chan error, 1)
+		go func() {
+			done <- micClient.Run()
+		}()
+		// the sync loop is started once the lease is acquired
+		if err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+			micClient.syncLock.Lock()
+			defer micClient.syncLock.Unlock()
+			return micClient.cancelSync != nil, nil
+		}); err != nil {
+			t.Fatalf("expected mic to acquire the lease")
+		}
+
+		expected := errLostLeaderLease
+		if shutdown {
+			// the lease released by Shutdown is not reported as lost
+			micClient.Shutdown()
+			expected = nil
+		} else {
+			atomic.StoreInt32(&lock.fail, 1)
+		}
+
+		select {
+		case err := <-done:
+			if err != expected {
+				t.Fatalf("expected Run to return %v when shutdown is %t, got: %v", expected, shutdown, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("expected Run to return once the lease is lost")
+		}
+		select {
+		case <-micClient.syncStopped:
+		default:
+			t.Fatalf("expected the sync loop to be stopped when Run returns")
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package mic
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	informerv1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+// NamespaceClient handles fetching namespace details from kubernetes
+type NamespaceClient struct {
+	informer informerv1.NamespaceInformer
+}
+
+// NewNamespaceClient returns a namespace client. onLabelsChange is called when the
+// labels of a namespace change, since the namespace selectors of the bindings can
+// then match other pods.
+func NewNamespaceClient(informer informerv1.NamespaceInformer, onLabelsChange func()) *NamespaceClient {
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNamespace, ok := oldObj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			newNamespace, ok := newObj.(*corev1.Namespace)
+			if !ok {
+				return
+			}
+			if !labels.Equals(oldNamespace.Labels, newNamespace.Labels) {
+				klog.V(6).Infof("Namespace %s labels updated", newNamespace.Name)
+				onLabelsChange()
+			}
+		},
+	})
+	return &NamespaceClient{informer: informer}
+}
+
+// Get gets the specified kubernetes namespace.
+//
+// Note that this is using a local, eventually consistent cache which may not
+// be up to date with the actual state of the cluster.
+func (c *NamespaceClient) Get(name string) (*corev1.Namespace, error) {
+	return c.informer.Lister().Get(name)
+}
+
+// Start starts syncing the underlying cache with kubernetes.
+//
+// The passed in channel should be used to signal that the client should stop
+// syncing. Close this channel when you want syncing to stop.
+func (c *NamespaceClient) Start(exit <-chan struct{}) {
+	go c.informer.Informer().Run(exit)
+	cache.WaitForCacheSync(exit, c.informer.Informer().HasSynced)
+}
@@ -0,0 +1,117 @@
+package mic
+
+import (
+	"encoding/json"
+	"sort"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+
+	"k8s.io/klog"
+)
+
+// syncPlan describes the changes a sync would make. It is logged instead of
+// applied in dry run mode.
+type syncPlan struct {
+	Nodes []nodePlan `json:"nodes"`
+}
+
+// nodePlan describes the changes to a node or vmss
+type nodePlan struct {
+	Name string `json:"name"`
+	VMSS bool   `json:"vmss"`
+	// NodeNotFound is set when the node no longer exists, in which case its
+	// assigned identities are deleted without updating the node
+	NodeNotFound             bool             `json:"nodeNotFound,omitempty"`
+	AddUserAssignedMSIIDs    []string         `json:"addUserAssignedMSIIDs,omitempty"`
+	RemoveUserAssignedMSIIDs []string         `json:"removeUserAssignedMSIIDs,omitempty"`
+	AssignedIDsToCreate      []assignedIDPlan `json:"assignedIDsToCreate,omitempty"`
+	AssignedIDsToDelete      []assignedIDPlan `json:"assignedIDsToDelete,omitempty"`
+}
+
+// assignedIDPlan describes an assigned identity to create or delete
+type assignedIDPlan struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	Pod          string `json:"pod"`
+	PodNamespace string `json:"podNamespace"`
+	NodeName     string `json:"nodeName"`
+	Identity     string `json:"identity"`
+	Binding      string `json:"binding"`
+	// Status is the current status of the assigned identity, empty if it does not exist yet
+	Status string `json:"status,omitempty"`
+}
+
+// newSyncPlan returns the plan of the changes tracked in the node map, sorted by node
+func newSyncPlan(nodeMap map[string]trackUserAssignedMSIIds) syncPlan {
+	plan := syncPlan{Nodes: []nodePlan{}}
+	for name, nodeTrackList := range nodeMap {
+		node := nodePlan{
+			Name:                name,
+			VMSS:                nodeTrackList.isvmss,
+			NodeNotFound:        nodeTrackList.nodeNotFound,
+			AssignedIDsToCreate: newAssignedIDPlans(nodeTrackList.assignedIDsToCreate),
+			AssignedIDsToDelete: newAssignedIDPlans(nodeTrackList.assignedIDsToDelete),
+		}
+		if !nodeTrackList.nodeNotFound {
+			node.AddUserAssignedMSIIDs = sortedUniqueIDs(nodeTrackList.addUserAssignedMSIIDs)
+			node.RemoveUserAssignedMSIIDs = sortedUniqueIDs(nodeTrackList.removeUserAssignedMSIIDs)
+		}
+		plan.Nodes = append(plan.Nodes, node)
+	}
+	sort.Slice(plan.Nodes, func(i, j int) bool {
+		return plan.Nodes[i].Name < plan.Nodes[j].Name
+	})
+	return plan
+}
+
+func newAssignedIDPlans(assignedIDs []aadpodid.AzureAssignedIdentity) []assignedIDPlan {
+	var plans []assignedIDPlan
+	for _, assignedID := range assignedIDs {
+		plan := assignedIDPlan{
+			Name:         assignedID.Name,
+			Namespace:    assignedID.Namespace,
+			Pod:          assignedID.Spec.Pod,
+			PodNamespace: assignedID.Spec.PodNamespace,
+			NodeName:     assignedID.Spec.NodeName,
+			Status:       assignedID.Status.Status,
+		}
+		if id := assignedID.Spec.AzureIdentityRef; id != nil {
+			plan.Identity = getIDKey(id.Namespace, id.Name)
+		}
+		if binding := assignedID.Spec.AzureBindingRef; binding != nil {
+			plan.Binding = getIDKey(binding.Namespace, binding.Name)
+		}
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].Name < plans[j].Name
+	})
+	return plans
+}
+
+func sortedUniqueIDs(idList []string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range idList {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// logSyncPlan logs the plan as a single json line, if it has any changes
+func logSyncPlan(plan syncPlan) error {
+	if len(plan.Nodes) == 0 {
+		klog.V(5).Infof("Dry run: no changes")
+		return nil
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Dry run plan: %s", data)
+	return nil
+}
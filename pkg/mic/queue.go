@@ -0,0 +1,110 @@
+package mic
+
+import (
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+const (
+	// fullSyncKey is the work queue key reconciling all the nodes. It is used
+	// for binding and identity changes, which can affect pods on any node, and
+	// for the periodic resync.
+	fullSyncKey = "*"
+	// vmssKeyPrefix prefixes the work queue keys of vmss, since identities are
+	// assigned to all the nodes of a vmss at once.
+	vmssKeyPrefix = "vmss/"
+)
+
+// getNodeKey returns the work queue key of the node, which is the vmss of the
+// node if it belongs to one and the node name otherwise.
+func (c *Client) getNodeKey(nodeName string) string {
+	vmssID, isVMSS, err := vmssFromNodeRef(c.NodeClient, nodeName)
+	if err != nil {
+		klog.Errorf("error checking if node %s is vmss. Error: %v", nodeName, err)
+		return nodeName
+	}
+	if isVMSS {
+		return vmssKeyPrefix + vmssID
+	}
+	return nodeName
+}
+
+func (c *Client) setQueue(queue workqueue.RateLimitingInterface) {
+	c.queueLock.Lock()
+	defer c.queueLock.Unlock()
+	c.queue = queue
+}
+
+// enqueueFullSync queues the reconciliation of all the nodes. Changes while not
+// syncing are ignored, since the sync starts with a full sync.
+func (c *Client) enqueueFullSync() {
+	c.queueLock.RLock()
+	defer c.queueLock.RUnlock()
+	if c.queue == nil {
+		return
+	}
+	c.queue.Add(fullSyncKey)
+}
+
+// enqueuePod queues the node of the pod for reconciliation. Pods changed while
+// not syncing are ignored, since the sync starts with a full sync. Pods which
+// cannot be matched by a binding are ignored unless a binding uses a label selector.
+func (c *Client) enqueuePod(pod *corev1.Pod) {
+	if pod.Spec.NodeName == "" {
+		// the pod will be queued again once it is scheduled
+		return
+	}
+	if atomic.LoadInt32(&c.labelSelectorsInUse) == 0 && !canMatchBindings(pod) {
+		return
+	}
+
+	c.queueLock.RLock()
+	defer c.queueLock.RUnlock()
+	if c.queue == nil {
+		return
+	}
+	c.queue.Add(c.getNodeKey(pod.Spec.NodeName))
+}
+
+// processNextWorkItem reconciles the next key of the work queue. It returns
+// false once the queue is shut down.
+func (c *Client) processNextWorkItem(queue workqueue.RateLimitingInterface) bool {
+	key, quit := queue.Get()
+	if quit {
+		return false
+	}
+	defer queue.Done(key)
+
+	if err := c.syncKey(key.(string)); err != nil {
+		klog.Errorf("failed to sync %s, requeuing. Error: %v", key, err)
+		queue.AddRateLimited(key)
+		return true
+	}
+	queue.Forget(key)
+	return true
+}
+
+// syncKey reconciles the assigned identities of the nodes with the work queue key
+func (c *Client) syncKey(key string) error {
+	if key == fullSyncKey {
+		return c.sync(nil)
+	}
+	if isGarbageKey(key) {
+		c.syncGarbageKey(key)
+		return nil
+	}
+
+	// a node is looked up at most once per sync
+	nodeKeys := make(map[string]string)
+	return c.sync(func(nodeName string) bool {
+		nodeKey, ok := nodeKeys[nodeName]
+		if !ok {
+			nodeKey = c.getNodeKey(nodeName)
+			nodeKeys[nodeName] = nodeKey
+		}
+		return nodeKey == key
+	})
+}
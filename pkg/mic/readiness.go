@@ -0,0 +1,99 @@
+package mic
+
+import (
+	"fmt"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const reasonNoMatchingBindings = "NoMatchingBindings"
+
+// podIdentities is the number of identities of a pod, and how many of them are assigned.
+type podIdentities struct {
+	total      int
+	assigned   int
+	cloudError string
+}
+
+// updatePodReadiness sets the identity assigned condition of the pods, which is true once all the
+// desired assigned identities of a pod are assigned to its node. Pods can list the condition in
+// their readiness gates so they are not ready before they can get tokens. current holds the
+// assigned identities before the sync, which are assigned to their node if their status says so.
+func (c *Client) updatePodReadiness(pods []*corev1.Pod, desired, current map[string]aadpodid.AzureAssignedIdentity) {
+	if !c.podReadinessCondition {
+		return
+	}
+
+	identities := make(map[string]*podIdentities)
+	for _, assignedID := range desired {
+		key := getIDKey(assignedID.Spec.PodNamespace, assignedID.Spec.Pod)
+		p, ok := identities[key]
+		if !ok {
+			p = &podIdentities{}
+			identities[key] = p
+		}
+		p.total++
+		if currentID, ok := current[assignedID.Name]; c.status.isApplied(assignedID.Name) || (ok && currentID.Status.Status == aadpodid.AssignedIDAssigned) {
+			p.assigned++
+			continue
+		}
+		if id := assignedID.Spec.AzureIdentityRef; id != nil && p.cloudError == "" {
+			p.cloudError = c.status.identityError(getIDKey(id.Namespace, id.Name))
+		}
+	}
+
+	now := v1.Now()
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		previous := getPodCondition(pod, aadpodid.PodIdentityAssignedCondition)
+		p, ok := identities[getIDKey(pod.Namespace, pod.Name)]
+		if !ok && previous == nil {
+			// pods never matched by a binding are left untouched
+			continue
+		}
+		condition := newPodIdentityAssignedCondition(p)
+		if previous != nil && previous.Status == condition.Status && previous.Reason == condition.Reason && previous.Message == condition.Message {
+			continue
+		}
+		condition.LastTransitionTime = now
+		if previous != nil && previous.Status == condition.Status {
+			condition.LastTransitionTime = previous.LastTransitionTime
+		}
+		if err := c.PodClient.SetPodCondition(pod, condition); err != nil {
+			klog.Errorf("Updating pod %s/%s condition %s failed with error %v", pod.Namespace, pod.Name, condition.Type, err)
+		}
+	}
+}
+
+// newPodIdentityAssignedCondition returns the identity assigned condition of a pod with the identities
+func newPodIdentityAssignedCondition(p *podIdentities) corev1.PodCondition {
+	condition := corev1.PodCondition{
+		Type:   aadpodid.PodIdentityAssignedCondition,
+		Status: corev1.ConditionFalse,
+	}
+	switch {
+	case p == nil:
+		condition.Reason, condition.Message = reasonNoMatchingBindings, "The pod is not matched by any binding"
+	case p.assigned == p.total:
+		condition.Status, condition.Reason, condition.Message = corev1.ConditionTrue, reasonAssigned, "All the identities are assigned"
+	case p.cloudError != "":
+		condition.Reason, condition.Message = reasonCloudProviderError, p.cloudError
+	default:
+		condition.Reason, condition.Message = reasonPending, fmt.Sprintf("Assignment of %d of %d identities is pending", p.total-p.assigned, p.total)
+	}
+	return condition
+}
+
+func getPodCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package mic
+
+import (
+	"fmt"
+	"strings"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// bindingSelector holds the parsed pod and namespace selectors of a binding
+type bindingSelector struct {
+	binding           aadpodid.AzureIdentityBinding
+	selector          string
+	podSelector       labels.Selector
+	namespaceSelector labels.Selector
+}
+
+// newBindingSelector parses the selectors of the binding. The Selector field is
+// a shorthand for matching the selectors of the pod and is combined with the
+// LabelSelector when both are set. An empty LabelSelector is ignored instead of
+// matching all the pods, so a binding without any selector matches no pods.
+func newBindingSelector(binding aadpodid.AzureIdentityBinding) (*bindingSelector, error) {
+	s := &bindingSelector{binding: binding, selector: binding.Spec.Selector}
+	hasLabelSelector := !isEmptyLabelSelector(binding.Spec.LabelSelector)
+	if binding.Spec.Selector == "" && !hasLabelSelector {
+		s.podSelector = labels.Nothing()
+		return s, nil
+	}
+
+	s.podSelector = labels.Everything()
+	if hasLabelSelector {
+		selector, err := v1.LabelSelectorAsSelector(binding.Spec.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %v", err)
+		}
+		s.podSelector = selector
+	}
+
+	if binding.Spec.NamespaceSelector != nil {
+		selector, err := v1.LabelSelectorAsSelector(binding.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector: %v", err)
+		}
+		s.namespaceSelector = selector
+	}
+	return s, nil
+}
+
+// isEmptyLabelSelector returns true if the label selector is not set or has no requirements
+func isEmptyLabelSelector(selector *v1.LabelSelector) bool {
+	return selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0)
+}
+
+// MatchBinding returns true if the pod matches the selectors of the binding, the same
+// way MIC matches the pods with the bindings. The namespace of the pod is only looked
+// up if the binding has a namespace selector.
+func MatchBinding(binding aadpodid.AzureIdentityBinding, pod *corev1.Pod, getNamespace func(name string) (*corev1.Namespace, error)) (bool, error) {
+	s, err := newBindingSelector(binding)
+	if err != nil {
+		return false, err
+	}
+	return s.matches(pod, getPodSelectors(pod), getNamespace)
+}
+
+// IsIdentityAllowed returns true if the identity matched by the binding can be assigned
+// to a pod of the namespace. Namespaced identities, or all identities when namespaced
+// identities are enforced, are only assigned to the pods of their namespace through the
+// bindings of their namespace.
+func IsIdentityAllowed(isNamespaced bool, id *aadpodid.AzureIdentity, binding *aadpodid.AzureIdentityBinding, podNamespace string) bool {
+	if !isNamespaced && !aadpodid.IsNamespacedIdentity(id) {
+		return true
+	}
+	return id.Namespace == binding.Namespace && binding.Namespace == podNamespace
+}
+
+// matches returns true if the pod matches the binding. podSelectors are the binding
+// selectors of the pod returned by getPodSelectors. The namespace of the pod is only
+// looked up if the binding has a namespace selector.
+func (s *bindingSelector) matches(pod *corev1.Pod, podSelectors sets.String, getNamespace func(name string) (*corev1.Namespace, error)) (bool, error) {
+	if s.selector != "" && !podSelectors.Has(s.selector) {
+		return false, nil
+	}
+	if !s.podSelector.Matches(labels.Set(pod.Labels)) {
+		return false, nil
+	}
+	if s.namespaceSelector == nil {
+		return true, nil
+	}
+	ns, err := getNamespace(pod.Namespace)
+	if err != nil {
+		return false, err
+	}
+	return s.namespaceSelector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// usesLabelSelectors returns true if a binding selects pods with a label selector,
+// so any pod can be matched by a binding
+func usesLabelSelectors(bindings []aadpodid.AzureIdentityBinding) bool {
+	for _, binding := range bindings {
+		if !isEmptyLabelSelector(binding.Spec.LabelSelector) {
+			return true
+		}
+	}
+	return false
+}
+
+// canMatchBindings returns true if the pod has binding selectors, or had identities
+// assigned and has to be reconciled to reset its identity assigned condition. When
+// no binding uses a label selector, the other pods cannot be matched by a binding.
+func canMatchBindings(pod *corev1.Pod) bool {
+	return getPodSelectors(pod).Len() > 0 || getPodCondition(pod, aadpodid.PodIdentityAssignedCondition) != nil
+}
+
+// getPodSelectors returns the binding selectors of the pod: the value of the
+// aadpodidbinding label and the comma-separated values of the bindings annotation
+func getPodSelectors(pod *corev1.Pod) sets.String {
+	podSelectors := sets.NewString()
+	if selector := pod.Labels[aadpodid.CRDLabelKey]; selector != "" {
+		podSelectors.Insert(selector)
+	}
+	for _, selector := range strings.Split(pod.Annotations[aadpodid.BindingsAnnotationKey], ",") {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			podSelectors.Insert(selector)
+		}
+	}
+	return podSelectors
+}
@@ -0,0 +1,231 @@
+package mic
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+)
+
+const (
+	reasonAssigned           = "Assigned"
+	reasonPending            = "Pending"
+	reasonNoMatchingPods     = "NoMatchingPods"
+	reasonNoError            = "NoError"
+	reasonCloudProviderError = "CloudProviderError"
+)
+
+// statusTracker records the outcome of the identity assignments of the syncs, from which
+// MIC maintains the status of the AzureIdentities and AzureIdentityBindings.
+type statusTracker struct {
+	mu sync.Mutex
+	// applied are the assigned identities whose identity was assigned to their node in the current sync.
+	applied map[string]bool
+	// identityErrors and bindingErrors hold the last Azure error of each identity and binding until
+	// the identity is successfully assigned to or removed from a node again.
+	identityErrors map[string]string
+	bindingErrors  map[string]string
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		applied:        make(map[string]bool),
+		identityErrors: make(map[string]string),
+		bindingErrors:  make(map[string]string),
+	}
+}
+
+func (s *statusTracker) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied = make(map[string]bool)
+}
+
+func (s *statusTracker) setApplied(assignedID *aadpodid.AzureAssignedIdentity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied[assignedID.Name] = true
+	s.clearLocked(assignedID)
+}
+
+func (s *statusTracker) setRemoved(assignedID *aadpodid.AzureAssignedIdentity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clearLocked(assignedID)
+}
+
+func (s *statusTracker) setError(assignedID *aadpodid.AzureAssignedIdentity, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, binding := assignedID.Spec.AzureIdentityRef, assignedID.Spec.AzureBindingRef
+	s.identityErrors[getIDKey(id.Namespace, id.Name)] = err.Error()
+	s.bindingErrors[getIDKey(binding.Namespace, binding.Name)] = err.Error()
+}
+
+func (s *statusTracker) clearLocked(assignedID *aadpodid.AzureAssignedIdentity) {
+	id, binding := assignedID.Spec.AzureIdentityRef, assignedID.Spec.AzureBindingRef
+	delete(s.identityErrors, getIDKey(id.Namespace, id.Name))
+	delete(s.bindingErrors, getIDKey(binding.Namespace, binding.Name))
+}
+
+func (s *statusTracker) isApplied(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applied[name]
+}
+
+func (s *statusTracker) identityError(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.identityErrors[key]
+}
+
+func (s *statusTracker) bindingError(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bindingErrors[key]
+}
+
+// usage is the usage of an identity or binding by the pods.
+type usage struct {
+	pods     int32
+	assigned int32
+	nodes    sets.String
+}
+
+func addUsage(usages map[string]*usage, key string, assigned bool, node string) {
+	u, ok := usages[key]
+	if !ok {
+		u = &usage{nodes: sets.NewString()}
+		usages[key] = u
+	}
+	u.pods++
+	if assigned {
+		u.assigned++
+		u.nodes.Insert(node)
+	}
+}
+
+// updateStatuses updates the status of the identities and bindings which changed, based on the
+// desired assigned identities and the outcome of the sync. current holds the assigned identities
+// before the sync, which are assigned to their node if their status says so.
+func (c *Client) updateStatuses(listIDs []aadpodid.AzureIdentity, listBindings []aadpodid.AzureIdentityBinding,
+	desired []aadpodid.AzureAssignedIdentity, current map[string]aadpodid.AzureAssignedIdentity) {
+	identityUsage := make(map[string]*usage)
+	bindingUsage := make(map[string]*usage)
+	nodeNames := make(map[string]string)
+
+	for _, assignedID := range desired {
+		assigned := c.status.isApplied(assignedID.Name)
+		if currentID, ok := current[assignedID.Name]; ok && currentID.Status.Status == aadpodid.AssignedIDAssigned {
+			assigned = true
+		}
+		node, ok := nodeNames[assignedID.Spec.NodeName]
+		if !ok {
+			node = c.getNodeOrVMSSName(assignedID.Spec.NodeName)
+			nodeNames[assignedID.Spec.NodeName] = node
+		}
+
+		id, binding := assignedID.Spec.AzureIdentityRef, assignedID.Spec.AzureBindingRef
+		addUsage(identityUsage, getIDKey(id.Namespace, id.Name), assigned, node)
+		addUsage(bindingUsage, getIDKey(binding.Namespace, binding.Name), assigned, node)
+	}
+
+	now := metav1.Now()
+	for i := range listIDs {
+		id := &listIDs[i]
+		key := getIDKey(id.Namespace, id.Name)
+		status := aadpodid.AzureIdentityStatus{ObjectMeta: id.Status.ObjectMeta}
+		status.AvailableReplicas, status.Pods, status.Nodes, status.LastError, status.Conditions =
+			newStatus(identityUsage[key], c.status.identityError(key), id.Status.Conditions, now)
+		if reflect.DeepEqual(status, id.Status) {
+			continue
+		}
+		if err := c.CRDClient.UpdateAzureIdentityStatus(id, status); err != nil {
+			klog.Errorf("Updating identity %s status failed with error %v", key, err)
+		}
+	}
+	for i := range listBindings {
+		binding := &listBindings[i]
+		key := getIDKey(binding.Namespace, binding.Name)
+		status := aadpodid.AzureIdentityBindingStatus{ObjectMeta: binding.Status.ObjectMeta}
+		status.AvailableReplicas, status.Pods, status.Nodes, status.LastError, status.Conditions =
+			newStatus(bindingUsage[key], c.status.bindingError(key), binding.Status.Conditions, now)
+		if reflect.DeepEqual(status, binding.Status) {
+			continue
+		}
+		if err := c.CRDClient.UpdateAzureIdentityBindingStatus(binding, status); err != nil {
+			klog.Errorf("Updating binding %s status failed with error %v", key, err)
+		}
+	}
+}
+
+// newStatus returns the status fields of an identity or binding with the given usage and last error.
+func newStatus(u *usage, lastError string, conditions []aadpodid.IdentityCondition, now metav1.Time) (
+	availableReplicas, pods int32, nodes []string, lastErr string, newConditions []aadpodid.IdentityCondition) {
+	if u == nil {
+		u = &usage{nodes: sets.NewString()}
+	}
+	if u.nodes.Len() > 0 {
+		nodes = u.nodes.List()
+	}
+
+	assigned := aadpodid.IdentityCondition{Type: aadpodid.IdentityAssigned, Status: corev1.ConditionTrue, Reason: reasonAssigned,
+		Message: fmt.Sprintf("Assigned to %d pods on %d nodes", u.assigned, len(nodes))}
+	switch {
+	case u.pods == 0:
+		assigned.Status, assigned.Reason, assigned.Message = corev1.ConditionFalse, reasonNoMatchingPods, "No pods are matched"
+	case u.assigned == 0:
+		assigned.Status, assigned.Reason, assigned.Message = corev1.ConditionFalse, reasonPending, fmt.Sprintf("Assignment to %d pods is pending", u.pods)
+	}
+
+	cloudError := aadpodid.IdentityCondition{Type: aadpodid.IdentityError, Status: corev1.ConditionFalse, Reason: reasonNoError}
+	if lastError != "" {
+		cloudError.Status, cloudError.Reason, cloudError.Message = corev1.ConditionTrue, reasonCloudProviderError, lastError
+	}
+
+	ready := aadpodid.IdentityCondition{Type: aadpodid.IdentityReady, Status: corev1.ConditionTrue, Reason: reasonAssigned}
+	switch {
+	case lastError != "":
+		ready.Status, ready.Reason, ready.Message = corev1.ConditionFalse, reasonCloudProviderError, lastError
+	case u.assigned < u.pods:
+		ready.Status, ready.Reason, ready.Message = corev1.ConditionFalse, reasonPending, fmt.Sprintf("Assignment to %d of %d pods is pending", u.pods-u.assigned, u.pods)
+	case u.pods == 0:
+		ready.Reason = reasonNoMatchingPods
+	}
+
+	for _, condition := range []aadpodid.IdentityCondition{ready, assigned, cloudError} {
+		newConditions = append(newConditions, withTransitionTime(condition, conditions, now))
+	}
+	return u.assigned, u.pods, nodes, lastError, newConditions
+}
+
+// withTransitionTime sets the last transition time of the condition, which is kept from the
+// previous condition of the same type unless the status changed.
+func withTransitionTime(condition aadpodid.IdentityCondition, previous []aadpodid.IdentityCondition, now metav1.Time) aadpodid.IdentityCondition {
+	condition.LastTransitionTime = now
+	for _, p := range previous {
+		if p.Type == condition.Type && p.Status == condition.Status {
+			condition.LastTransitionTime = p.LastTransitionTime
+		}
+	}
+	return condition
+}
+
+// getNodeOrVMSSName returns the name of the vmss of the node, or the node name if it is not part of a vmss.
+func (c *Client) getNodeOrVMSSName(nodeName string) string {
+	vmssID, isVMSS, err := vmssFromNodeRef(c.NodeClient, nodeName)
+	if err != nil {
+		klog.Errorf("error checking if node %s is vmss. Error: %v", nodeName, err)
+		return nodeName
+	}
+	if isVMSS {
+		return getVMSSName(vmssID)
+	}
+	return nodeName
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	auth "github.com/Azure/aad-pod-identity/pkg/auth"
+	"github.com/Azure/go-autorest/autorest/adal"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// credentialErrorReason is the reason of the events recorded when the
+	// credentials of an identity could not be read or are invalid
+	credentialErrorReason = "credential error"
+	// federatedTokenAudience is the audience of the service account tokens
+	// exchanged for AAD tokens, as expected by AAD federated credentials
+	federatedTokenAudience = "api://AzureADTokenExchange"
+)
+
+// credentialError is returned when the credentials of a service principal
+// identity could not be read from the referenced secrets or are invalid.
+type credentialError struct {
+	err error
+}
+
+func (e *credentialError) Error() string {
+	return e.err.Error()
+}
+
+// newCredentialError records the error on the identity and returns it as a credentialError
+func (s *Server) newCredentialError(id *aadpodid.AzureIdentity, err error) error {
+	err = fmt.Errorf("invalid credentials for azureidentity %s/%s: %v", id.Namespace, id.Name, err)
+	s.KubeClient.RecordIdentityEvent(id, corev1.EventTypeWarning, credentialErrorReason, err.Error())
+	return &credentialError{err: err}
+}
+
+// recordCredentialError records the error on the pod if the token could not be
+// acquired because of invalid credentials
+func (s *Server) recordCredentialError(podns, podname string, err error) {
+	if _, ok := err.(*credentialError); ok {
+		s.KubeClient.RecordPodEvent(podns, podname, corev1.EventTypeWarning, credentialErrorReason, err.Error())
+	}
+}
+
+// getServicePrincipalToken acquires a token for the service principal identity
+// using the certificate if one is referenced, otherwise using the client secret.
+func (s *Server) getServicePrincipalToken(id *aadpodid.AzureIdentity, resource string) (*adal.Token, error) {
+	tenantID, clientID := id.Spec.TenantID, id.Spec.ClientID
+
+	if id.Spec.ClientCertificate.Name != "" {
+		certificate, password, err := s.getClientCertificate(id)
+		if err != nil {
+			return nil, s.newCredentialError(id, err)
+		}
+		token, err := auth.GetServicePrincipalTokenWithCertificate(tenantID, clientID, certificate, password, resource)
+		if err != nil {
+			// errors not returned by AAD are caused by an invalid or expired certificate
+			if _, ok := err.(adal.TokenRefreshError); !ok {
+				return nil, s.newCredentialError(id, err)
+			}
+			return nil, err
+		}
+		return token, nil
+	}
+
+	clientSecret, err := s.getSecretValue(id.Spec.ClientPassword, id.Spec.ClientPasswordKey)
+	if err != nil {
+		return nil, s.newCredentialError(id, err)
+	}
+	return auth.GetServicePrincipalToken(tenantID, clientID, string(clientSecret), resource)
+}
+
+// getFederatedIdentityToken acquires a token for the federated identity by
+// exchanging a service account token of the pod for an AAD token
+func (s *Server) getFederatedIdentityToken(podns, podname, tenantID, clientID, resource string) (*adal.Token, error) {
+	assertion, err := s.KubeClient.GetServiceAccountToken(podns, podname, []string{federatedTokenAudience})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account token for pod %s/%s: %v", podns, podname, err)
+	}
+	return auth.GetServicePrincipalTokenWithClientAssertion(tenantID, clientID, assertion, resource)
+}
+
+// getClientCertificate returns the certificate and its password from the secrets
+// referenced by the identity. Secrets of type kubernetes.io/tls are supported
+// as well as secrets with a PEM or PFX encoded certificate and private key.
+func (s *Server) getClientCertificate(id *aadpodid.AzureIdentity) (certificate []byte, password string, err error) {
+	clientCertificate := id.Spec.ClientCertificate
+	secret, err := s.KubeClient.GetSecret(&clientCertificate)
+	if err != nil {
+		return nil, "", err
+	}
+	crt, isTLS := secret.Data[corev1.TLSCertKey]
+	if isTLS && id.Spec.ClientCertificateKey == "" {
+		certificate = append(append(append(certificate, crt...), '\n'), secret.Data[corev1.TLSPrivateKeyKey]...)
+	} else if certificate, err = secretValue(secret, id.Spec.ClientCertificateKey); err != nil {
+		return nil, "", err
+	}
+
+	if id.Spec.ClientCertificatePassword.Name != "" {
+		value, err := s.getSecretValue(id.Spec.ClientCertificatePassword, id.Spec.ClientCertificatePasswordKey)
+		if err != nil {
+			return nil, "", err
+		}
+		password = string(value)
+	}
+	return certificate, password, nil
+}
+
+// getSecretValue returns the value of the key in the referenced secret
+func (s *Server) getSecretValue(secretRef corev1.SecretReference, key string) ([]byte, error) {
+	secret, err := s.KubeClient.GetSecret(&secretRef)
+	if err != nil {
+		return nil, err
+	}
+	return secretValue(secret, key)
+}
+
+// secretValue returns the value of the key in the secret. If the key is not
+// specified, the secret is required to contain a single key whose value is
+// returned.
+func secretValue(secret *corev1.Secret, key string) ([]byte, error) {
+	if key != "" {
+		value, ok := secret.Data[key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in secret %s/%s", key, secret.Namespace, secret.Name)
+		}
+		return value, nil
+	}
+
+	switch len(secret.Data) {
+	case 0:
+		return nil, fmt.Errorf("secret %s/%s is empty", secret.Namespace, secret.Name)
+	case 1:
+		for _, value := range secret.Data {
+			return value, nil
+		}
+	}
+	keys := make([]string, 0, len(secret.Data))
+	for k := range secret.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return nil, fmt.Errorf("secret %s/%s contains multiple keys [%s], the key to use has to be specified", secret.Namespace, secret.Name, strings.Join(keys, ", "))
+}
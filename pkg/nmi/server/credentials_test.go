@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+
+	internalaadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/aad-pod-identity/pkg/k8s"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretValue(t *testing.T) {
+	cases := []struct {
+		name          string
+		data          map[string][]byte
+		key           string
+		expectedValue string
+		expectedErr   bool
+	}{
+		{
+			name:          "single key without key specified",
+			data:          map[string][]byte{"key1": []byte("val1")},
+			expectedValue: "val1",
+		},
+		{
+			name:          "key specified",
+			data:          map[string][]byte{"key1": []byte("val1"), "key2": []byte("val2")},
+			key:           "key2",
+			expectedValue: "val2",
+		},
+		{
+			name:        "key not found",
+			data:        map[string][]byte{"key1": []byte("val1")},
+			key:         "key2",
+			expectedErr: true,
+		},
+		{
+			name:        "multiple keys without key specified",
+			data:        map[string][]byte{"key1": []byte("val1"), "key2": []byte("val2")},
+			expectedErr: true,
+		},
+		{
+			name:        "empty secret",
+			data:        map[string][]byte{},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"}, Data: tc.data}
+		value, err := secretValue(secret, tc.key)
+		if (err != nil) != tc.expectedErr {
+			t.Errorf("%s: expected error %v, got %+v", tc.name, tc.expectedErr, err)
+		}
+		if string(value) != tc.expectedValue {
+			t.Errorf("%s: expected value %s, got %s", tc.name, tc.expectedValue, value)
+		}
+	}
+}
+
+func TestGetServicePrincipalTokenCredentialError(t *testing.T) {
+	fakeClient := fake.NewSimpleClientset()
+	fakeClient.CoreV1().Secrets("default").Create(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "clientSecret", Namespace: "default"},
+		Data:       map[string][]byte{"key1": []byte("val1"), "key2": []byte("val2")},
+	})
+
+	s := &Server{KubeClient: &k8s.KubeClient{ClientSet: fakeClient}}
+	podID := &internalaadpodid.AzureIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "identity", Namespace: "default"},
+		Spec: internalaadpodid.AzureIdentitySpec{
+			Type:              internalaadpodid.ServicePrincipal,
+			TenantID:          "tid",
+			ClientID:          "aabc0000-a83v-9h4m-000j-2c0a66b0c1f9",
+			ClientPassword:    v1.SecretReference{Name: "clientSecret", Namespace: "default"},
+			ClientPasswordKey: "key3",
+		},
+	}
+
+	_, err := s.getServicePrincipalToken(podID, "https://management.azure.com/")
+	if _, ok := err.(*credentialError); !ok {
+		t.Fatalf("expected credential error, got: %+v", err)
+	}
+}
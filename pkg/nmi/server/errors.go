@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"k8s.io/klog"
+)
+
+const (
+	// error codes returned in the error field of the response, these match the
+	// OAuth 2.0 error codes returned by IMDS and AAD
+	errorCodeInvalidRequest         = "invalid_request"
+	errorCodeAccessDenied           = "access_denied"
+	errorCodeTemporarilyUnavailable = "temporarily_unavailable"
+	errorCodeServerError            = "server_error"
+
+	// defaultRetryAfterSeconds is the retry hint returned for throttled and server errors
+	defaultRetryAfterSeconds = 5
+
+	// adalResponseBodyPrefix precedes the response body in the errors returned by adal.
+	// adal reads and closes the body of the response returned by Response(), so the body
+	// is only available in the error message.
+	adalResponseBodyPrefix = "Response body: "
+)
+
+// errorResponse is the error body returned by nmi. It has the same format as
+// the error body returned by IMDS so that azure sdks can decide whether to retry.
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// writeErrorResponse writes an IMDS compatible error with the error code
+// matching the status code.
+func writeErrorResponse(w http.ResponseWriter, statusCode int, description string) {
+	writeError(w, statusCode, errorResponse{
+		Error:            errorCodeFromStatusCode(statusCode),
+		ErrorDescription: description,
+	}, "")
+}
+
+// writeTokenErrorResponse writes the error returned while acquiring a token.
+// Errors returned by AAD or IMDS are passed through with the original status
+// code and error details.
+func writeTokenErrorResponse(w http.ResponseWriter, err error) {
+	statusCode, errResp, retryAfter := tokenErrorResponse(err)
+	writeError(w, statusCode, errResp, retryAfter)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, errResp errorResponse, retryAfter string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError {
+		if retryAfter == "" {
+			retryAfter = strconv.Itoa(defaultRetryAfterSeconds)
+		}
+		w.Header().Set("Retry-After", retryAfter)
+	}
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(errResp); err != nil {
+		klog.Errorf("failed to write error response, err: %+v", err)
+	}
+}
+
+// tokenErrorResponse returns the status code, error body and retry hint for the
+// error returned while acquiring a token.
+func tokenErrorResponse(err error) (statusCode int, errResp errorResponse, retryAfter string) {
+	refreshErr, ok := err.(adal.TokenRefreshError)
+	if !ok {
+		// errors that did not originate from AAD or IMDS, e.g. the identity
+		// is not configured for the pod or its secret could not be read
+		return http.StatusForbidden, errorResponse{Error: errorCodeAccessDenied, ErrorDescription: err.Error()}, ""
+	}
+
+	resp := refreshErr.Response()
+	if resp == nil {
+		// the request to AAD or IMDS could not be executed
+		return http.StatusInternalServerError, errorResponse{Error: errorCodeServerError, ErrorDescription: err.Error()}, ""
+	}
+
+	statusCode = resp.StatusCode
+	retryAfter = resp.Header.Get("Retry-After")
+
+	msg := refreshErr.Error()
+	if idx := strings.Index(msg, adalResponseBodyPrefix); idx >= 0 {
+		if jsonErr := json.Unmarshal([]byte(msg[idx+len(adalResponseBodyPrefix):]), &errResp); jsonErr == nil && errResp.Error != "" {
+			return statusCode, errResp, retryAfter
+		}
+	}
+	return statusCode, errorResponse{Error: errorCodeFromStatusCode(statusCode), ErrorDescription: msg}, retryAfter
+}
+
+func errorCodeFromStatusCode(statusCode int) string {
+	switch {
+	case statusCode == http.StatusForbidden:
+		return errorCodeAccessDenied
+	case statusCode == http.StatusNotFound, statusCode == http.StatusTooManyRequests:
+		// both are retried by the azure sdks
+		return errorCodeTemporarilyUnavailable
+	case statusCode >= http.StatusInternalServerError:
+		return errorCodeServerError
+	default:
+		return errorCodeInvalidRequest
+	}
+}
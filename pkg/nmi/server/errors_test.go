@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+)
+
+type testTokenRefreshError struct {
+	message string
+	resp    *http.Response
+}
+
+func (e testTokenRefreshError) Error() string {
+	return e.message
+}
+
+func (e testTokenRefreshError) Response() *http.Response {
+	return e.resp
+}
+
+func TestWriteErrorResponse(t *testing.T) {
+	cases := []struct {
+		statusCode        int
+		expectedCode      string
+		expectedRetryHint bool
+	}{
+		{statusCode: http.StatusBadRequest, expectedCode: errorCodeInvalidRequest},
+		{statusCode: http.StatusForbidden, expectedCode: errorCodeAccessDenied},
+		{statusCode: http.StatusNotFound, expectedCode: errorCodeTemporarilyUnavailable},
+		{statusCode: http.StatusTooManyRequests, expectedCode: errorCodeTemporarilyUnavailable, expectedRetryHint: true},
+		{statusCode: http.StatusInternalServerError, expectedCode: errorCodeServerError, expectedRetryHint: true},
+	}
+
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		writeErrorResponse(rr, tc.statusCode, "description")
+
+		if rr.Code != tc.statusCode {
+			t.Errorf("expected status code %d, got %d", tc.statusCode, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected application/json content type, got %s", ct)
+		}
+		if hasRetryHint := rr.Header().Get("Retry-After") != ""; hasRetryHint != tc.expectedRetryHint {
+			t.Errorf("status code %d: expected retry hint %v, got %v", tc.statusCode, tc.expectedRetryHint, hasRetryHint)
+		}
+
+		var errResp errorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &errResp); err != nil {
+			t.Fatalf("expected json error body, got %s", rr.Body.String())
+		}
+		if errResp.Error != tc.expectedCode || errResp.ErrorDescription != "description" {
+			t.Errorf("status code %d: unexpected error body %+v", tc.statusCode, errResp)
+		}
+	}
+}
+
+func TestTokenErrorResponse(t *testing.T) {
+	aadBody := `{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret is provided."}`
+	throttledHeader := http.Header{}
+	throttledHeader.Set("Retry-After", "30")
+
+	cases := []struct {
+		name               string
+		err                error
+		expectedStatusCode int
+		expectedCode       string
+		expectedRetryAfter string
+	}{
+		{
+			name:               "error not originating from adal",
+			err:                errors.New("azureidentity is not configured for the pod"),
+			expectedStatusCode: http.StatusForbidden,
+			expectedCode:       errorCodeAccessDenied,
+		},
+		{
+			name:               "adal request failure",
+			err:                testTokenRefreshError{message: "adal: Failed to execute the refresh request"},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedCode:       errorCodeServerError,
+		},
+		{
+			name: "aad error is passed through",
+			err: testTokenRefreshError{
+				message: fmt.Sprintf("adal: Refresh request failed. Status Code = '401'. Response body: %s", aadBody),
+				resp:    &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{}},
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedCode:       "invalid_client",
+		},
+		{
+			name: "throttled request keeps retry hint",
+			err: testTokenRefreshError{
+				message: "adal: Refresh request failed. Status Code = '429'. Response body: too many requests",
+				resp:    &http.Response{StatusCode: http.StatusTooManyRequests, Header: throttledHeader},
+			},
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedCode:       errorCodeTemporarilyUnavailable,
+			expectedRetryAfter: "30",
+		},
+	}
+
+	for _, tc := range cases {
+		statusCode, errResp, retryAfter := tokenErrorResponse(tc.err)
+		if statusCode != tc.expectedStatusCode {
+			t.Errorf("%s: expected status code %d, got %d", tc.name, tc.expectedStatusCode, statusCode)
+		}
+		if errResp.Error != tc.expectedCode {
+			t.Errorf("%s: expected error code %s, got %s", tc.name, tc.expectedCode, errResp.Error)
+		}
+		if errResp.ErrorDescription == "" {
+			t.Errorf("%s: expected error description to be set", tc.name)
+		}
+		if retryAfter != tc.expectedRetryAfter {
+			t.Errorf("%s: expected retry after %q, got %q", tc.name, tc.expectedRetryAfter, retryAfter)
+		}
+	}
+}
+
+// TestTokenErrorResponseFromAdal pins the format of the errors returned by adal, which
+// reads and closes the response body, so the AAD error is parsed from the error message.
+func TestTokenErrorResponseFromAdal(t *testing.T) {
+	aadBody := `{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret is provided."}`
+	aad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(aadBody))
+	}))
+	defer aad.Close()
+
+	oauthConfig, err := adal.NewOAuthConfig(aad.URL, "tenantid")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	spt, err := adal.NewServicePrincipalToken(*oauthConfig, "clientid", "secret", "https://management.azure.com/")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	refreshErr := spt.Refresh()
+	if refreshErr == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	statusCode, errResp, _ := tokenErrorResponse(refreshErr)
+	if statusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, statusCode)
+	}
+	expected := errorResponse{Error: "invalid_client", ErrorDescription: "AADSTS7000215: Invalid client secret is provided."}
+	if errResp != expected {
+		t.Errorf("expected the aad error %+v to be parsed from %q, got %+v", expected, refreshErr.Error(), errResp)
+	}
+}
@@ -52,6 +52,10 @@ type Server struct {
 	ListPodIDsRetryAttemptsForAssigned int
 	ListPodIDsRetryIntervalInSeconds   int
 	Reporter                           *metrics.Reporter
+
+	EnableTokenCache                   bool
+	TokenCacheRefreshIntervalInSeconds int
+	tokenCache                         *tokenCache
 }
 
 // NMIResponse is the response returned to caller
@@ -82,6 +86,14 @@ func NewServer(isNamespaced bool, micNamespace string, blockInstanceMetadata boo
 func (s *Server) Run() error {
 	go s.updateIPTableRules()
 
+	if s.EnableTokenCache {
+		s.tokenCache = newTokenCache(s.Reporter)
+		// tokens of an identity are no longer served from the cache once
+		// any of its assignments is removed
+		s.KubeClient.AddAssignedIDDeleteHandler(s.tokenCache.evictAssignedID)
+		go s.tokenCache.run(time.Duration(s.TokenCacheRefreshIntervalInSeconds) * time.Second)
+	}
+
 	mux := http.NewServeMux()
 	mux.Handle("/metadata/identity/oauth2/token", appHandler(s.msiHandler))
 	mux.Handle("/metadata/identity/oauth2/token/", appHandler(s.msiHandler))
@@ -180,7 +192,7 @@ func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				err = errors.New("Unknown error")
 			}
 			klog.Errorf("Panic processing request: %+v, file: %s, line: %d, stacktrace: '%s' %s res.status=%d", r, file, line, stack, tracker, http.StatusInternalServerError)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		}
 	}()
 	rw := newResponseWriter(w)
@@ -188,45 +200,45 @@ func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	latency := time.Since(start)
 	klog.Infof("Status (%d) took %d ns", rw.statusCode, latency.Nanoseconds())
 
-	_, resource := parseRequestClientIDAndResource(r)
+	rqResource := parseTokenRequest(r).Resource
 
 	if appHandlerReporter != nil {
 		appHandlerReporter.ReportOperationAndStatus(
 			r.URL.Path,
 			strconv.Itoa(rw.statusCode),
 			ns,
-			resource,
+			rqResource,
 			metrics.NMIOperationsDurationM.M(metrics.SinceInSeconds(start)))
 	}
 }
 
 func (s *Server) hostHandler(w http.ResponseWriter, r *http.Request) (ns string) {
 	hostIP := parseRemoteAddr(r.RemoteAddr)
-	rqClientID, rqResource := parseRequestClientIDAndResource(r)
+	tokenRequest := parseTokenRequest(r)
 
 	podns, podname := parseRequestHeader(r)
 	if podns == "" || podname == "" {
 		klog.Error("missing podname and podns from request")
-		http.Error(w, "missing 'podname' and 'podns' from request header", http.StatusBadRequest)
+		writeErrorResponse(w, http.StatusBadRequest, "missing 'podname' and 'podns' from request header")
 		return
 	}
 	// set the ns so it can be used for metrics
 	ns = podns
 	if hostIP != localhost {
 		klog.Errorf("request remote address is not from a host")
-		http.Error(w, "request remote address is not from a host", http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, "request remote address is not from a host")
 		return
 	}
-	if !validateResourceParamExists(rqResource) {
-		klog.Warning("parameter resource cannot be empty")
-		http.Error(w, "parameter resource cannot be empty", http.StatusBadRequest)
+	if err := tokenRequest.validate(); err != nil {
+		klog.Warning(err)
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	podIDs, identityInCreatedStateFound, err := s.listPodIDsWithRetry(r.Context(), s.KubeClient, podns, podname, rqClientID)
+	podIDs, identityInCreatedStateFound, err := s.listPodIDsWithRetry(r.Context(), s.KubeClient, podns, podname, tokenRequest)
 	if err != nil {
 		msg := fmt.Sprintf("no AzureAssignedIdentity found for pod:%s/%s in desired state", podns, podname)
 		klog.Errorf("%s, %+v", msg, err)
-		http.Error(w, msg, getErrorResponseStatusCode(identityInCreatedStateFound))
+		writeErrorResponse(w, getErrorResponseStatusCode(identityInCreatedStateFound), msg)
 		return
 	}
 
@@ -248,10 +260,11 @@ func (s *Server) hostHandler(w http.ResponseWriter, r *http.Request) (ns string)
 		}
 	}
 	podIDs = filterPodIdentities
-	token, clientID, err := getTokenForMatchingID(s.KubeClient, rqClientID, rqResource, podIDs)
+	token, clientID, err := s.getTokenForMatchingID(tokenRequest, podns, podname, podIDs)
 	if err != nil {
 		klog.Errorf("failed to get service principal token for pod:%s/%s, err: %+v", podns, podname, err)
-		http.Error(w, err.Error(), http.StatusForbidden)
+		s.recordCredentialError(podns, podname, err)
+		writeTokenErrorResponse(w, err)
 		return
 	}
 	nmiResp := NMIResponse{
@@ -261,7 +274,7 @@ func (s *Server) hostHandler(w http.ResponseWriter, r *http.Request) (ns string)
 	response, err := json.Marshal(nmiResp)
 	if err != nil {
 		klog.Errorf("failed to marshal service principal token and clientid for pod:%s/%s, err: %+v", podns, podname, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	w.Write(response)
@@ -303,28 +316,23 @@ func (s *Server) isMIC(podNS, rsName string) bool {
 	return false
 }
 
-func (s *Server) getTokenForExceptedPod(rqClientID, rqResource string) ([]byte, int, error) {
-	var token *adal.Token
-	var err error
-	// ClientID is empty, so we are going to use System assigned MSI
-	if rqClientID == "" {
-		klog.Infof("Fetching token for system assigned MSI")
-		token, err = auth.GetServicePrincipalTokenFromMSI(rqResource)
-	} else { // User assigned identity usage.
-		klog.Infof("Fetching token for user assigned MSI for resource: %s", rqResource)
-		token, err = auth.GetServicePrincipalTokenFromMSIWithUserAssignedID(rqClientID, rqResource)
-	}
-	if err != nil {
-		klog.Errorf("Failed to get service principal token, err: %+v", err)
-		// TODO: return the right status code based on the error we got from adal.
-		return nil, http.StatusForbidden, err
-	}
-	response, err := json.Marshal(newMSIResponse(*token))
-	if err != nil {
-		klog.Errorf("Failed to marshal service principal token, err: %+v", err)
-		return nil, http.StatusInternalServerError, err
-	}
-	return response, http.StatusOK, nil
+func (s *Server) getTokenForExceptedPod(rq tokenRequest) (*adal.Token, error) {
+	// User assigned identity usage, the identity is selected by the
+	// parameter set in the request.
+	switch {
+	case rq.ClientID != "":
+		klog.Infof("Fetching token for user assigned MSI with clientid:%s for resource: %s", utils.RedactClientID(rq.ClientID), rq.Resource)
+		return auth.GetServicePrincipalTokenFromMSIWithUserAssignedID(rq.ClientID, rq.Resource)
+	case rq.ObjectID != "":
+		klog.Infof("Fetching token for user assigned MSI with objectid:%s for resource: %s", rq.ObjectID, rq.Resource)
+		return auth.GetServicePrincipalTokenFromMSIWithObjectID(rq.ObjectID, rq.Resource)
+	case rq.ResourceID != "":
+		klog.Infof("Fetching token for user assigned MSI with resourceid:%s for resource: %s", rq.ResourceID, rq.Resource)
+		return auth.GetServicePrincipalTokenFromMSIWithResourceID(rq.ResourceID, rq.Resource)
+	}
+	// No identity is selected, so we are going to use System assigned MSI
+	klog.Infof("Fetching token for system assigned MSI")
+	return auth.GetServicePrincipalTokenFromMSI(rq.Resource)
 }
 
 // msiHandler uses the remote address to identify the pod ip and uses it
@@ -334,22 +342,22 @@ func (s *Server) getTokenForExceptedPod(rqClientID, rqResource string) ([]byte,
 // configured id.
 func (s *Server) msiHandler(w http.ResponseWriter, r *http.Request) (ns string) {
 	podIP := parseRemoteAddr(r.RemoteAddr)
-	rqClientID, rqResource := parseRequestClientIDAndResource(r)
+	tokenRequest := parseTokenRequest(r)
 
 	if podIP == "" {
 		klog.Error("request remote address is empty")
-		http.Error(w, "request remote address is empty", http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, "request remote address is empty")
 		return
 	}
-	if !validateResourceParamExists(rqResource) {
-		klog.Warning("parameter resource cannot be empty")
-		http.Error(w, "parameter resource cannot be empty", http.StatusBadRequest)
+	if err := tokenRequest.validate(); err != nil {
+		klog.Warning(err)
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	podns, podname, rsName, selectors, err := s.KubeClient.GetPodInfo(podIP)
 	if err != nil {
 		klog.Errorf("missing podname for podip:%s, %+v", podIP, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	// set ns for using in metrics
@@ -357,53 +365,60 @@ func (s *Server) msiHandler(w http.ResponseWriter, r *http.Request) (ns string)
 	exceptionList, err := s.KubeClient.ListPodIdentityExceptions(podns)
 	if err != nil {
 		klog.Errorf("getting list of azurepodidentityexceptions in %s namespace failed with error: %+v", podns, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// If its mic, then just directly get the token and pass back.
 	if pod.IsPodExcepted(selectors.MatchLabels, *exceptionList) || s.isMIC(podns, rsName) {
 		klog.Infof("Exception pod %s/%s token handling", podns, podname)
-		response, errorCode, err := s.getTokenForExceptedPod(rqClientID, rqResource)
+		token, err := s.getTokenForExceptedPod(tokenRequest)
+		if err != nil {
+			klog.Errorf("failed to get service principal token for pod:%s/%s, err: %+v", podns, podname, err)
+			writeTokenErrorResponse(w, err)
+			return
+		}
+		response, err := json.Marshal(newMSIResponse(*token))
 		if err != nil {
-			klog.Errorf("failed to get service principal token for pod:%s/%s.  Error code: %d. Error: %+v", podns, podname, errorCode, err)
-			http.Error(w, err.Error(), errorCode)
+			klog.Errorf("failed to marshal service principal token for pod:%s/%s, err: %+v", podns, podname, err)
+			writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 		w.Write(response)
 		return
 	}
 
-	podIDs, identityInCreatedStateFound, err := s.listPodIDsWithRetry(r.Context(), s.KubeClient, podns, podname, rqClientID)
+	podIDs, identityInCreatedStateFound, err := s.listPodIDsWithRetry(r.Context(), s.KubeClient, podns, podname, tokenRequest)
 	if err != nil {
 		msg := fmt.Sprintf("no AzureAssignedIdentity found for pod:%s/%s in assigned state", podns, podname)
 		klog.Errorf("%s, %+v", msg, err)
-		http.Error(w, msg, getErrorResponseStatusCode(identityInCreatedStateFound))
+		writeErrorResponse(w, getErrorResponseStatusCode(identityInCreatedStateFound), msg)
 		return
 	}
 
-	token, _, err := getTokenForMatchingID(s.KubeClient, rqClientID, rqResource, podIDs)
+	token, _, err := s.getTokenForMatchingID(tokenRequest, podns, podname, podIDs)
 	if err != nil {
 		klog.Errorf("failed to get service principal token for pod:%s/%s, %+v", podns, podname, err)
-		http.Error(w, err.Error(), http.StatusForbidden)
+		s.recordCredentialError(podns, podname, err)
+		writeTokenErrorResponse(w, err)
 		return
 	}
 	response, err := json.Marshal(newMSIResponse(*token))
 	if err != nil {
 		klog.Errorf("failed to marshal service principal token for pod:%s/%s, %+v", podns, podname, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	w.Write(response)
 	return
 }
 
-func getTokenForMatchingID(kubeClient k8s.Client, rqClientID, rqResource string, podIDs []aadpodid.AzureIdentity) (token *adal.Token, clientID string, err error) {
-	rqHasClientID := len(rqClientID) != 0
+func (s *Server) getTokenForMatchingID(rq tokenRequest, podns, podname string, podIDs []aadpodid.AzureIdentity) (token *adal.Token, clientID string, err error) {
+	rqResource := rq.Resource
 	for _, v := range podIDs {
 		clientID := v.Spec.ClientID
-		if rqHasClientID && !strings.EqualFold(rqClientID, clientID) {
-			klog.Warningf("clientid mismatch, requested:%s available:%s", rqClientID, clientID)
+		if !rq.matchesIdentity(&v) {
+			klog.Warningf("identity mismatch, requested:%s available clientid:%s", rq, utils.RedactClientID(clientID))
 			continue
 		}
 
@@ -411,21 +426,30 @@ func getTokenForMatchingID(kubeClient k8s.Client, rqClientID, rqResource string,
 		switch idType {
 		case aadpodid.UserAssignedMSI:
 			klog.Infof("matched identityType:%v clientid:%s resource:%s", idType, utils.RedactClientID(clientID), rqResource)
-			token, err := auth.GetServicePrincipalTokenFromMSIWithUserAssignedID(clientID, rqResource)
+			token, err := s.getToken(newTokenCacheKey(idType, clientID, "", rqResource), func() (*adal.Token, error) {
+				return auth.GetServicePrincipalTokenFromMSIWithUserAssignedID(clientID, rqResource)
+			})
 			return token, clientID, err
 		case aadpodid.ServicePrincipal:
 			tenantid := v.Spec.TenantID
 			klog.Infof("matched identityType:%v tenantid:%s clientid:%s resource:%s", idType, tenantid, utils.RedactClientID(clientID), rqResource)
-			secret, err := kubeClient.GetSecret(&v.Spec.ClientPassword)
+			podID := v
+			key := newServicePrincipalTokenCacheKey(clientID, tenantid, rqResource, v.Namespace, v.Name)
+			token, err := s.getToken(key, func() (*adal.Token, error) {
+				return s.getServicePrincipalToken(&podID, rqResource)
+			})
+			return token, clientID, err
+		case aadpodid.FederatedIdentity:
+			tenantid := v.Spec.TenantID
+			klog.Infof("matched identityType:%v tenantid:%s clientid:%s resource:%s", idType, tenantid, utils.RedactClientID(clientID), rqResource)
+			serviceAccount, err := s.KubeClient.GetServiceAccountName(podns, podname)
 			if err != nil {
 				return nil, clientID, err
 			}
-			clientSecret := ""
-			for _, v := range secret.Data {
-				clientSecret = string(v)
-				break
-			}
-			token, err := auth.GetServicePrincipalToken(tenantid, clientID, clientSecret, rqResource)
+			key := newFederatedTokenCacheKey(clientID, tenantid, rqResource, podns, serviceAccount)
+			token, err := s.getToken(key, func() (*adal.Token, error) {
+				return s.getFederatedIdentityToken(podns, podname, tenantid, clientID, rqResource)
+			})
 			return token, clientID, err
 		default:
 			return nil, clientID, fmt.Errorf("unsupported identity type %+v", idType)
@@ -436,6 +460,15 @@ func getTokenForMatchingID(kubeClient k8s.Client, rqClientID, rqResource string,
 	return nil, "", fmt.Errorf("azureidentity is not configured for the pod")
 }
 
+// getToken returns the token from the token cache if enabled, otherwise
+// it fetches a new token.
+func (s *Server) getToken(key tokenCacheKey, fetch tokenFetcher) (*adal.Token, error) {
+	if s.tokenCache == nil {
+		return fetch()
+	}
+	return s.tokenCache.getToken(key, fetch)
+}
+
 func parseRequestHeader(r *http.Request) (podns string, podname string) {
 	podns = r.Header.Get("podns")
 	podname = r.Header.Get("podname")
@@ -455,13 +488,79 @@ func parseRemoteAddr(addr string) string {
 	return hostname
 }
 
-func parseRequestClientIDAndResource(r *http.Request) (clientID string, resource string) {
+// tokenRequest contains the parameters of the token request. Similar to IMDS,
+// a user assigned identity can be selected using either the client id, the
+// object id or the resource id of the identity.
+type tokenRequest struct {
+	ClientID   string
+	ObjectID   string
+	ResourceID string
+	Resource   string
+}
+
+func parseTokenRequest(r *http.Request) (rq tokenRequest) {
 	vals := r.URL.Query()
 	if vals != nil {
-		clientID = vals.Get("client_id")
-		resource = vals.Get("resource")
+		rq.ClientID = vals.Get("client_id")
+		rq.ObjectID = vals.Get("object_id")
+		rq.ResourceID = vals.Get("msi_res_id")
+		if rq.ResourceID == "" {
+			rq.ResourceID = vals.Get("mi_res_id")
+		}
+		rq.Resource = vals.Get("resource")
+	}
+	return rq
+}
+
+// validate returns an error if the request is missing the resource or selects
+// the identity using more than one parameter
+func (rq tokenRequest) validate() error {
+	if !validateResourceParamExists(rq.Resource) {
+		return errors.New("parameter resource cannot be empty")
+	}
+	selectors := 0
+	for _, v := range []string{rq.ClientID, rq.ObjectID, rq.ResourceID} {
+		if v != "" {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return errors.New("only one of client_id, object_id and msi_res_id can be specified")
+	}
+	return nil
+}
+
+// hasIdentitySelector returns true if the request selects a specific identity
+func (rq tokenRequest) hasIdentitySelector() bool {
+	return rq.ClientID != "" || rq.ObjectID != "" || rq.ResourceID != ""
+}
+
+// matchesIdentity returns true if the identity is selected by the request.
+// All identities match a request without an identity selector.
+func (rq tokenRequest) matchesIdentity(id *aadpodid.AzureIdentity) bool {
+	if rq.ClientID != "" && !strings.EqualFold(rq.ClientID, id.Spec.ClientID) {
+		return false
 	}
-	return clientID, resource
+	if rq.ObjectID != "" && !strings.EqualFold(rq.ObjectID, id.Spec.ObjectID) {
+		return false
+	}
+	if rq.ResourceID != "" && !strings.EqualFold(rq.ResourceID, id.Spec.ResourceID) {
+		return false
+	}
+	return true
+}
+
+// String returns the identity selector of the request for logging
+func (rq tokenRequest) String() string {
+	switch {
+	case rq.ClientID != "":
+		return fmt.Sprintf("clientid:%s", utils.RedactClientID(rq.ClientID))
+	case rq.ObjectID != "":
+		return fmt.Sprintf("objectid:%s", rq.ObjectID)
+	case rq.ResourceID != "":
+		return fmt.Sprintf("resourceid:%s", rq.ResourceID)
+	}
+	return "none"
 }
 
 // defaultPathHandler creates a new request and returns the response body and code
@@ -470,7 +569,7 @@ func (s *Server) defaultPathHandler(w http.ResponseWriter, r *http.Request) (ns
 	req, err := http.NewRequest(r.Method, r.URL.String(), r.Body)
 	if err != nil || req == nil {
 		klog.Errorf("failed creating a new request for %s, err: %+v", r.URL.String(), err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	host := fmt.Sprintf("%s:%s", s.MetadataIP, s.MetadataPort)
@@ -483,7 +582,7 @@ func (s *Server) defaultPathHandler(w http.ResponseWriter, r *http.Request) (ns
 	resp, err := client.Do(req)
 	if err != nil {
 		klog.Errorf("failed executing request for %s, err: %+v", req.URL.String(), err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	defer func() {
@@ -494,7 +593,8 @@ func (s *Server) defaultPathHandler(w http.ResponseWriter, r *http.Request) (ns
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		klog.Errorf("failed io operation of reading response body for %s, %+v", req.URL.String(), err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 	w.Write(body)
 	return
@@ -502,7 +602,7 @@ func (s *Server) defaultPathHandler(w http.ResponseWriter, r *http.Request) (ns
 
 // forbiddenHandler responds to any request with HTTP 403 Forbidden
 func forbiddenHandler(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "Request blocked by AAD Pod Identity NMI", http.StatusForbidden)
+	writeErrorResponse(w, http.StatusForbidden, "Request blocked by AAD Pod Identity NMI")
 }
 
 func copyHeader(dst, src http.Header) {
@@ -532,18 +632,25 @@ func handleTermination() {
 }
 
 // listPodIDsWithRetry returns a list of matched identities in Assigned state, boolean indicating if at least an identity was found in Created state and error if any
-func (s *Server) listPodIDsWithRetry(ctx context.Context, kubeClient k8s.Client, podns, podname, rqClientID string) ([]aadpodid.AzureIdentity, bool, error) {
+func (s *Server) listPodIDsWithRetry(ctx context.Context, kubeClient k8s.Client, podns, podname string, rq tokenRequest) ([]aadpodid.AzureIdentity, bool, error) {
 	attempt := 0
 	var err error
 	var idStateMap map[string][]aadpodid.AzureIdentity
 
+	// the identities are listed again as soon as any identity assigned to the pod
+	// changes, so the request returns once the identity reaches Assigned state
+	podIDsChanged, stopWatch := kubeClient.WatchPodIds(podns, podname)
+	defer stopWatch()
+	retryInterval := time.Duration(s.ListPodIDsRetryIntervalInSeconds) * time.Second
+	retry := time.After(retryInterval)
+
 	// this loop will run to ensure we have assigned identities before we return. If there are no assigned identities in created state within 80s (16 retries * 5s wait) then we return an error.
 	// If we get an assigned identity in created state within 80s, then loop will continue until 100s to find assigned identity in assigned state.
 	// Retry interval for CREATED state is set to 80s because avg time for identity to be assigned to the node is 35-37s.
 	for attempt < s.ListPodIDsRetryAttemptsForCreated+s.ListPodIDsRetryAttemptsForAssigned {
 		idStateMap, err = kubeClient.ListPodIds(podns, podname)
 		if err == nil {
-			if len(rqClientID) == 0 {
+			if !rq.hasIdentitySelector() {
 				// check to ensure backward compatability with assignedIDs that have no state
 				// assigned identites created with old version of mic will not contain a state. So first we check to see if an assigned identity with
 				// no state exists that matches req client id.
@@ -559,23 +666,23 @@ func (s *Server) listPodIDsWithRetry(ctx context.Context, kubeClient k8s.Client,
 						podns, podname, s.ListPodIDsRetryAttemptsForCreated, s.ListPodIDsRetryIntervalInSeconds, err)
 				}
 			} else {
-				// if client id exists in request, we need to ensure the identity with this client
+				// if the request selects an identity, we need to ensure the selected identity
 				// exists and is in Assigned state
 				// check to ensure backward compatability with assignedIDs that have no state
 				for _, podID := range idStateMap[""] {
-					if strings.EqualFold(rqClientID, podID.Spec.ClientID) {
+					if rq.matchesIdentity(&podID) {
 						klog.Warningf("found assignedIDs with no state for pod:%s/%s. AssignedIDs created with old version of mic.", podns, podname)
 						return idStateMap[""], true, nil
 					}
 				}
 				for _, podID := range idStateMap[aadpodid.AssignedIDAssigned] {
-					if strings.EqualFold(rqClientID, podID.Spec.ClientID) {
+					if rq.matchesIdentity(&podID) {
 						return idStateMap[aadpodid.AssignedIDAssigned], true, nil
 					}
 				}
 				var foundMatch bool
 				for _, podID := range idStateMap[aadpodid.AssignedIDCreated] {
-					if strings.EqualFold(rqClientID, podID.Spec.ClientID) {
+					if rq.matchesIdentity(&podID) {
 						foundMatch = true
 						break
 					}
@@ -586,14 +693,18 @@ func (s *Server) listPodIDsWithRetry(ctx context.Context, kubeClient k8s.Client,
 				}
 			}
 		}
-		attempt++
 
 		select {
-		case <-time.After(time.Duration(s.ListPodIDsRetryIntervalInSeconds) * time.Second):
+		case <-podIDsChanged:
+			klog.V(4).Infof("assigned ids for pod:%s/%s changed, retrying attempt: %d", podns, podname, attempt)
+			continue
+		case <-retry:
 		case <-ctx.Done():
 			err = ctx.Err()
 			return nil, true, err
 		}
+		attempt++
+		retry = time.After(retryInterval)
 		klog.V(4).Infof("failed to get assigned ids for pod:%s/%s in ASSIGNED state, retrying attempt: %d", podns, podname, attempt)
 	}
 	return nil, true, fmt.Errorf("getting assigned identities for pod %s/%s in ASSIGNED state failed after %d attempts, retry duration [%d]s. Error: %v",
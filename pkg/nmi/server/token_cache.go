@@ -0,0 +1,240 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/aad-pod-identity/pkg/metrics"
+	utils "github.com/Azure/aad-pod-identity/pkg/utils"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"go.opencensus.io/stats"
+	"k8s.io/klog"
+)
+
+const (
+	// tokenMinValidity is the minimum remaining lifetime of a cached token for it
+	// to be served. adal clients refresh tokens that expire within 5 minutes, so
+	// this has to stay above that to avoid handing out tokens that are refreshed
+	// again right away.
+	tokenMinValidity = 10 * time.Minute
+	// tokenRefreshWindow is the remaining lifetime below which the background
+	// refresher fetches a new token for a cache entry.
+	tokenRefreshWindow = 15 * time.Minute
+	// tokenIdleTimeout is the duration after which entries that have not been
+	// served are no longer proactively refreshed and are dropped once expired.
+	tokenIdleTimeout = 1 * time.Hour
+)
+
+// tokenFetcher acquires a new token from IMDS/AAD
+type tokenFetcher func() (*adal.Token, error)
+
+// tokenCacheKey identifies a token in the cache
+type tokenCacheKey struct {
+	idType   aadpodid.IdentityType
+	clientID string
+	tenantID string
+	resource string
+	// identity is the namespace/name of the AzureIdentity, only set for service
+	// principals, whose tokens are acquired with the secret of the identity
+	identity string
+	// namespace and serviceAccount are only set for federated identities, whose
+	// tokens are acquired with the service account token of the pod
+	namespace      string
+	serviceAccount string
+}
+
+type tokenCacheEntry struct {
+	token    *adal.Token
+	fetch    tokenFetcher
+	lastUsed time.Time
+}
+
+// tokenFetch is a fetch in flight, whose result is shared by the requests for the
+// same key made while it is in flight
+type tokenFetch struct {
+	done  chan struct{}
+	token *adal.Token
+	err   error
+}
+
+// tokenCache is an in-memory cache of tokens acquired by nmi. Tokens are served
+// from the cache until they are close to expiry and are refreshed in the
+// background before they expire.
+type tokenCache struct {
+	mu       sync.Mutex
+	entries  map[tokenCacheKey]*tokenCacheEntry
+	inflight map[tokenCacheKey]*tokenFetch
+	reporter *metrics.Reporter
+	now      func() time.Time
+}
+
+func newTokenCache(reporter *metrics.Reporter) *tokenCache {
+	return &tokenCache{
+		entries:  make(map[tokenCacheKey]*tokenCacheEntry),
+		inflight: make(map[tokenCacheKey]*tokenFetch),
+		reporter: reporter,
+		now:      time.Now,
+	}
+}
+
+func newTokenCacheKey(idType aadpodid.IdentityType, clientID, tenantID, resource string) tokenCacheKey {
+	return tokenCacheKey{
+		idType:   idType,
+		clientID: strings.ToLower(clientID),
+		tenantID: strings.ToLower(tenantID),
+		resource: resource,
+	}
+}
+
+// newServicePrincipalTokenCacheKey returns the key of the tokens of a service principal
+// identity. Identities with the same client id can reference different secrets, so the
+// tokens are only shared by the pods using the same identity.
+func newServicePrincipalTokenCacheKey(clientID, tenantID, resource, idNamespace, idName string) tokenCacheKey {
+	key := newTokenCacheKey(aadpodid.ServicePrincipal, clientID, tenantID, resource)
+	key.identity = idNamespace + "/" + idName
+	return key
+}
+
+// newFederatedTokenCacheKey returns the key of the tokens of a federated identity. The
+// federated credential only trusts the service account tokens of some subjects, so the
+// tokens are only shared by the pods with the same namespace and service account.
+func newFederatedTokenCacheKey(clientID, tenantID, resource, namespace, serviceAccount string) tokenCacheKey {
+	key := newTokenCacheKey(aadpodid.FederatedIdentity, clientID, tenantID, resource)
+	key.namespace = namespace
+	key.serviceAccount = serviceAccount
+	return key
+}
+
+// getToken returns the cached token for the key if it is still valid, otherwise
+// it acquires a new token using fetch and caches it. Concurrent requests for a
+// key missing from the cache wait for a single fetch. The expires_in of a cached
+// token is the remaining lifetime of the token.
+func (c *tokenCache) getToken(key tokenCacheKey, fetch tokenFetcher) (*adal.Token, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && c.isValid(entry.token, now, tokenMinValidity) {
+		entry.lastUsed = now
+		token := *entry.token
+		c.mu.Unlock()
+		// the token is served later than it was acquired, so its lifetime is shorter
+		token.ExpiresIn = json.Number(strconv.FormatInt(int64(token.Expires().Sub(now)/time.Second), 10))
+		c.report(metrics.NMITokenCacheHitCountM)
+		return &token, nil
+	}
+	c.report(metrics.NMITokenCacheMissCountM)
+	if inflight, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-inflight.done
+		if inflight.err != nil {
+			return nil, inflight.err
+		}
+		token := *inflight.token
+		return &token, nil
+	}
+	inflight := &tokenFetch{done: make(chan struct{})}
+	c.inflight[key] = inflight
+	c.mu.Unlock()
+
+	inflight.token, inflight.err = fetch()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if inflight.err == nil {
+		c.entries[key] = &tokenCacheEntry{token: inflight.token, fetch: fetch, lastUsed: now}
+	}
+	c.mu.Unlock()
+	close(inflight.done)
+
+	if inflight.err != nil {
+		return nil, inflight.err
+	}
+	token := *inflight.token
+	return &token, nil
+}
+
+// evictClientID removes all the cached tokens acquired for the client id
+func (c *tokenCache) evictClientID(clientID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if strings.EqualFold(key.clientID, clientID) {
+			klog.V(5).Infof("evicting cached token for clientid:%s resource:%s", utils.RedactClientID(key.clientID), key.resource)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evictAssignedID removes the cached tokens for the identity referenced by the
+// deleted assigned identity
+func (c *tokenCache) evictAssignedID(assignedID *aadpodid.AzureAssignedIdentity) {
+	if assignedID == nil || assignedID.Spec.AzureIdentityRef == nil {
+		return
+	}
+	c.evictClientID(assignedID.Spec.AzureIdentityRef.Spec.ClientID)
+}
+
+// refresh acquires new tokens for the entries that are about to expire and
+// drops the entries that are idle and expired.
+func (c *tokenCache) refresh() {
+	now := c.now()
+
+	c.mu.Lock()
+	toRefresh := make(map[tokenCacheKey]tokenFetcher)
+	for key, entry := range c.entries {
+		if c.isValid(entry.token, now, tokenRefreshWindow) {
+			continue
+		}
+		if now.Sub(entry.lastUsed) > tokenIdleTimeout {
+			if !c.isValid(entry.token, now, 0) {
+				delete(c.entries, key)
+			}
+			continue
+		}
+		toRefresh[key] = entry.fetch
+	}
+	c.mu.Unlock()
+
+	for key, fetch := range toRefresh {
+		token, err := fetch()
+		if err != nil {
+			klog.Errorf("failed to refresh cached token for clientid:%s resource:%s, err: %+v", utils.RedactClientID(key.clientID), key.resource, err)
+			continue
+		}
+		c.mu.Lock()
+		// the entry could have been evicted while the token was being refreshed
+		if entry, ok := c.entries[key]; ok {
+			entry.token = token
+		}
+		c.mu.Unlock()
+	}
+}
+
+// run refreshes the cache every interval
+func (c *tokenCache) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.refresh()
+	}
+}
+
+func (c *tokenCache) isValid(token *adal.Token, now time.Time, minValidity time.Duration) bool {
+	if token == nil {
+		return false
+	}
+	return token.Expires().After(now.Add(minValidity))
+}
+
+func (c *tokenCache) report(m *stats.Int64Measure) {
+	if c.reporter != nil {
+		c.reporter.Report(m.M(1))
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/go-autorest/autorest/adal"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type testFetcher struct {
+	calls   int
+	expires time.Time
+	err     error
+}
+
+func (f *testFetcher) fetch() (*adal.Token, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &adal.Token{
+		AccessToken: "token",
+		ExpiresOn:   newJSONNumber(f.expires),
+	}, nil
+}
+
+func newJSONNumber(t time.Time) json.Number {
+	return json.Number(strconv.FormatInt(t.Unix(), 10))
+}
+
+func TestTokenCacheHitAndMiss(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(nil)
+	c.now = func() time.Time { return now }
+
+	f := &testFetcher{expires: now.Add(time.Hour)}
+	key := newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid", "", "https://management.azure.com/")
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.getToken(key, f.fetch); err != nil {
+			t.Fatalf("expected nil error, got: %+v", err)
+		}
+	}
+	if f.calls != 1 {
+		t.Fatalf("expected 1 fetch, got %d", f.calls)
+	}
+
+	// different resource is a different cache entry
+	otherKey := newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid", "", "https://vault.azure.net")
+	if _, err := c.getToken(otherKey, f.fetch); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if f.calls != 2 {
+		t.Fatalf("expected 2 fetches, got %d", f.calls)
+	}
+
+	// token close to expiry is not served from the cache
+	c.now = func() time.Time { return now.Add(time.Hour - tokenMinValidity) }
+	if _, err := c.getToken(key, f.fetch); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if f.calls != 3 {
+		t.Fatalf("expected 3 fetches, got %d", f.calls)
+	}
+}
+
+func TestTokenCacheExpiresIn(t *testing.T) {
+	// expires_on has a precision of a second
+	now := time.Now().Truncate(time.Second)
+	c := newTokenCache(nil)
+	c.now = func() time.Time { return now }
+
+	f := &testFetcher{expires: now.Add(time.Hour)}
+	key := newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid", "", "https://management.azure.com/")
+	if _, err := c.getToken(key, f.fetch); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+
+	for _, elapsed := range []time.Duration{time.Minute, 40 * time.Minute} {
+		c.now = func() time.Time { return now.Add(elapsed) }
+		token, err := c.getToken(key, f.fetch)
+		if err != nil {
+			t.Fatalf("expected nil error, got: %+v", err)
+		}
+		expected := strconv.FormatInt(int64((time.Hour-elapsed)/time.Second), 10)
+		if f.calls != 1 || token.ExpiresIn.String() != expected {
+			t.Fatalf("expected the cached token to expire in %s seconds after %s, got %s with %d fetches", expected, elapsed, token.ExpiresIn, f.calls)
+		}
+	}
+}
+
+func TestTokenCacheFetchError(t *testing.T) {
+	c := newTokenCache(nil)
+	f := &testFetcher{err: errors.New("fetch failed")}
+	key := newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid", "", "resource")
+
+	if _, err := c.getToken(key, f.fetch); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(c.entries) != 0 {
+		t.Fatalf("expected no cache entries, got %d", len(c.entries))
+	}
+}
+
+func TestTokenCacheConcurrentMisses(t *testing.T) {
+	now := time.Now()
+	var mu sync.Mutex
+	requests := 0
+	c := newTokenCache(nil)
+	c.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		return now
+	}
+
+	calls := 0
+	release := make(chan struct{})
+	fetch := func() (*adal.Token, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return &adal.Token{AccessToken: "token", ExpiresOn: newJSONNumber(now.Add(time.Hour))}, nil
+	}
+	key := newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid", "", "resource")
+
+	const concurrency = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := c.getToken(key, fetch)
+			if err == nil && token.AccessToken != "token" {
+				err = errors.New("unexpected token " + token.AccessToken)
+			}
+			errs <- err
+		}()
+	}
+	// wait for all the requests to miss the cache before the token is fetched
+	for {
+		mu.Lock()
+		started := requests
+		mu.Unlock()
+		if started == concurrency {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected nil error, got: %+v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 fetch for concurrent misses, got %d", calls)
+	}
+	if len(c.inflight) != 0 {
+		t.Fatalf("expected no fetch in flight, got %d", len(c.inflight))
+	}
+}
+
+func TestTokenCacheServicePrincipalKey(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(nil)
+	c.now = func() time.Time { return now }
+
+	f := &testFetcher{expires: now.Add(time.Hour)}
+	// identities with the same client id can reference different secrets
+	for _, name := range []string{"id1", "id2", "id1"} {
+		key := newServicePrincipalTokenCacheKey("clientid", "tenantid", "resource", "default", name)
+		if _, err := c.getToken(key, f.fetch); err != nil {
+			t.Fatalf("expected nil error, got: %+v", err)
+		}
+	}
+	if f.calls != 2 {
+		t.Fatalf("expected 2 fetches, got %d", f.calls)
+	}
+}
+
+func TestTokenCacheRefresh(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(nil)
+	c.now = func() time.Time { return now }
+
+	f := &testFetcher{expires: now.Add(time.Hour)}
+	key := newTokenCacheKey(aadpodid.ServicePrincipal, "clientid", "tenantid", "resource")
+	if _, err := c.getToken(key, f.fetch); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+
+	// token is not yet in the refresh window
+	c.refresh()
+	if f.calls != 1 {
+		t.Fatalf("expected 1 fetch, got %d", f.calls)
+	}
+
+	// token is in the refresh window, so it should be refreshed in the background
+	c.now = func() time.Time { return now.Add(time.Hour - tokenRefreshWindow + time.Minute) }
+	f.expires = now.Add(2 * time.Hour)
+	c.refresh()
+	if f.calls != 2 {
+		t.Fatalf("expected 2 fetches, got %d", f.calls)
+	}
+	if _, err := c.getToken(key, f.fetch); err != nil {
+		t.Fatalf("expected nil error, got: %+v", err)
+	}
+	if f.calls != 2 {
+		t.Fatalf("expected refreshed token to be served from cache, got %d fetches", f.calls)
+	}
+
+	// idle entries are not refreshed and dropped once expired
+	c.now = func() time.Time { return now.Add(3 * time.Hour) }
+	c.refresh()
+	if f.calls != 2 {
+		t.Fatalf("expected idle entry not to be refreshed, got %d fetches", f.calls)
+	}
+	if len(c.entries) != 0 {
+		t.Fatalf("expected expired idle entry to be dropped, got %d entries", len(c.entries))
+	}
+}
+
+func TestTokenCacheEvictAssignedID(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(nil)
+	c.now = func() time.Time { return now }
+
+	f := &testFetcher{expires: now.Add(time.Hour)}
+	c.getToken(newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid1", "", "resource1"), f.fetch)
+	c.getToken(newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid1", "", "resource2"), f.fetch)
+	c.getToken(newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid2", "", "resource1"), f.fetch)
+
+	assignedID := &aadpodid.AzureAssignedIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "assignedid"},
+		Spec: aadpodid.AzureAssignedIdentitySpec{
+			AzureIdentityRef: &aadpodid.AzureIdentity{
+				Spec: aadpodid.AzureIdentitySpec{ClientID: "CLIENTID1"},
+			},
+		},
+	}
+	c.evictAssignedID(assignedID)
+
+	if len(c.entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %d", len(c.entries))
+	}
+	if _, ok := c.entries[newTokenCacheKey(aadpodid.UserAssignedMSI, "clientid2", "", "resource1")]; !ok {
+		t.Fatal("expected token for clientid2 to remain cached")
+	}
+}
@@ -1,6 +1,7 @@
 package pod
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -8,9 +9,10 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	informersv1 "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 
@@ -20,44 +22,60 @@ import (
 // Client represents new pod client
 type Client struct {
 	PodWatcher informersv1.PodInformer
+	ClientSet  kubernetes.Interface
 }
 
 // ClientInt represents pod client interface
 type ClientInt interface {
 	GetPods() (pods []*v1.Pod, err error)
+	SetPodCondition(pod *v1.Pod, condition v1.PodCondition) error
 	Start(exit <-chan struct{})
 }
 
-// NewPodClient returns new pod client
-func NewPodClient(i informers.SharedInformerFactory, eventCh chan aadpodid.EventType) (c ClientInt) {
+// NewPodClient returns new pod client. onChange is called with the pods
+// that were added, deleted or moved to another node, or whose labels changed.
+// Updated pods are passed both before and after the update.
+func NewPodClient(i informers.SharedInformerFactory, clientSet kubernetes.Interface, onChange func(pod *v1.Pod)) (c ClientInt) {
 	podInformer := i.Core().V1().Pods()
-	addPodHandler(podInformer, eventCh)
+	addPodHandler(podInformer, onChange)
 
 	return &Client{
 		PodWatcher: podInformer,
+		ClientSet:  clientSet,
 	}
 }
 
-func addPodHandler(i informersv1.PodInformer, eventCh chan aadpodid.EventType) {
+func addPodHandler(i informersv1.PodInformer, onChange func(pod *v1.Pod)) {
 	i.Informer().AddEventHandler(
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
 				klog.V(6).Infof("Pod Created")
-				eventCh <- aadpodid.PodCreated
-
+				onChange(obj.(*v1.Pod))
 			},
 			DeleteFunc: func(obj interface{}) {
 				klog.V(6).Infof("Pod Deleted")
-				eventCh <- aadpodid.PodDeleted
-
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				pod, ok := obj.(*v1.Pod)
+				if !ok {
+					klog.Errorf("could not cast %T to pod", obj)
+					return
+				}
+				onChange(pod)
 			},
 			UpdateFunc: func(OldObj, newObj interface{}) {
-				// We are only interested in updates to pod if the node changes.
-				// Having this check will ensure that mic sync loop does not do extra work
-				// for every pod update.
-				if (OldObj.(*v1.Pod)).Spec.NodeName != (newObj.(*v1.Pod)).Spec.NodeName {
+				// We are only interested in updates to pod if the node, the labels or the
+				// bindings annotation change. Having this check will ensure that mic sync
+				// loop does not do extra work for every pod update.
+				oldPod, newPod := OldObj.(*v1.Pod), newObj.(*v1.Pod)
+				if oldPod.Spec.NodeName != newPod.Spec.NodeName || !labels.Equals(oldPod.Labels, newPod.Labels) ||
+					oldPod.Annotations[aadpodid.BindingsAnnotationKey] != newPod.Annotations[aadpodid.BindingsAnnotationKey] {
 					klog.V(6).Infof("Pod Updated")
-					eventCh <- aadpodid.PodUpdated
+					// the old pod is passed too, since it may be on another node or be the
+					// only one of the two to select a binding
+					onChange(oldPod)
+					onChange(newPod)
 				}
 			},
 		},
@@ -81,16 +99,11 @@ func (c *Client) Start(exit <-chan struct{}) {
 	klog.Info("Pod watcher started !!")
 }
 
-// GetPods returns list of all pods
+// GetPods returns list of all pods. Bindings can select pods by any label,
+// so pods without the aadpodidbinding label are included and filtered by mic.
 func (c *Client) GetPods() (pods []*v1.Pod, err error) {
 	begin := time.Now()
-	crdReq, err := labels.NewRequirement(aadpodid.CRDLabelKey, selection.Exists, nil)
-	if err != nil {
-		klog.Error(err)
-		return nil, err
-	}
-	crdSelector := labels.NewSelector().Add(*crdReq)
-	listPods, err := c.PodWatcher.Lister().List(crdSelector)
+	listPods, err := c.PodWatcher.Lister().List(labels.Everything())
 	if err != nil {
 		return nil, err
 	}
@@ -98,6 +111,24 @@ func (c *Client) GetPods() (pods []*v1.Pod, err error) {
 	return listPods, nil
 }
 
+// SetPodCondition adds or replaces the condition of the same type in the pod status.
+// The condition is patched, since the conditions are merged by type, so the other
+// conditions and status fields of the pod are left untouched.
+func (c *Client) SetPodCondition(pod *v1.Pod, condition v1.PodCondition) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.PodCondition{condition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	begin := time.Now()
+	_, err = c.ClientSet.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.StrategicMergePatchType, patch, "status")
+	stats.Update(stats.PodStatusUpdate, time.Since(begin))
+	return err
+}
+
 // IsPodExcepted returns true if pod label is part of exception crd
 func IsPodExcepted(podLabels map[string]string, exceptionList []aadpodid.AzurePodIdentityException) bool {
 	return len(exceptionList) > 0 && labelInExceptionList(podLabels, exceptionList)
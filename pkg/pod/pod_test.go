@@ -1,12 +1,16 @@
 package pod
 
 import (
+	"encoding/json"
 	"testing"
 
 	internalaadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/klog"
 )
 
@@ -30,6 +34,10 @@ func (c TestPodClient) GetPods() (pods []*corev1.Pod, err error) {
 	return c.pods, nil
 }
 
+func (c TestPodClient) SetPodCondition(pod *corev1.Pod, condition corev1.PodCondition) error {
+	return nil
+}
+
 func (c *TestPodClient) AddPod(podName string, podNs string, nodeName string, binding string) {
 	labels := make(map[string]string)
 	labels[internalaadpodid.CRDLabelKey] = binding
@@ -118,3 +126,37 @@ func TestIsPodExcepted(t *testing.T) {
 		}
 	}
 }
+
+func TestSetPodCondition(t *testing.T) {
+	clientSet := fake.NewSimpleClientset()
+	c := &Client{ClientSet: clientSet}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"}}
+	condition := corev1.PodCondition{
+		Type:   internalaadpodid.PodIdentityAssignedCondition,
+		Status: corev1.ConditionTrue,
+		Reason: "Assigned",
+	}
+
+	// the fake client set does not apply patches, the patch is only checked
+	clientSet.PrependReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, pod, nil
+	})
+	if err := c.SetPodCondition(pod, condition); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	actions := clientSet.Actions()
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 action, got: %v", actions)
+	}
+	patch, ok := actions[0].(k8stesting.PatchAction)
+	if !ok || patch.GetSubresource() != "status" || patch.GetNamespace() != "default" || patch.GetName() != "pod1" {
+		t.Fatalf("expected a patch of the status of default/pod1, got: %+v", actions[0])
+	}
+	var patched corev1.Pod
+	if err := json.Unmarshal(patch.GetPatch(), &patched); err != nil {
+		t.Fatalf("expected a pod patch, got: %s", patch.GetPatch())
+	}
+	if len(patched.Status.Conditions) != 1 || patched.Status.Conditions[0] != condition {
+		t.Fatalf("expected the patch to only contain the condition %+v, got: %+v", condition, patched.Status)
+	}
+}
@@ -39,6 +39,7 @@ const (
 	TotalIDDel              StatsType = "Total time to delete assigned IDs"
 	TotalIDAdd              StatsType = "Total time to add assigned IDs"
 	TotalCreateOrUpdate     StatsType = "Total time to assign or remove IDs"
+	PodStatusUpdate         StatsType = "Pod status update"
 
 	EventRecord StatsType = "Event recording"
 )
@@ -121,6 +122,8 @@ func PrintSync() {
 
 		Print(TotalCreateOrUpdate)
 
+		Print(PodStatusUpdate)
+
 		Print(EventRecord)
 		Print(Total)
 	}
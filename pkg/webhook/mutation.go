@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/mic"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// MutatePath is the path of the mutating webhook
+const MutatePath = "/mutate"
+
+const (
+	// azureClientIDEnv and azureTenantIDEnv are the environment variables of the identity
+	// read by the default credential chain of the Azure SDKs
+	azureClientIDEnv = "AZURE_CLIENT_ID"
+	azureTenantIDEnv = "AZURE_TENANT_ID"
+)
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// patchOperation is a JSON patch operation
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// podReadinessGates is the part of the pod holding its readiness gates, which are read
+// from the raw pod since the pod type does not have them
+type podReadinessGates struct {
+	Spec struct {
+		ReadinessGates []struct {
+			ConditionType string `json:"conditionType"`
+		} `json:"readinessGates"`
+	} `json:"spec"`
+}
+
+// mutate injects the binding selector and the environment variables of the identity named
+// by the identity annotation into the pods being created
+func (s *Server) mutate(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if req.Operation != admissionv1beta1.Create || req.Kind.Kind != "Pod" {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		klog.Errorf("failed to decode pod %s/%s. Error: %v", req.Namespace, req.Name, err)
+		return failure(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+	}
+	identityRef := pod.Annotations[aadpodid.IdentityAnnotationKey]
+	if identityRef == "" {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+
+	patch, rejected := s.podPatch(pod, req.Object.Raw, identityRef)
+	if rejected != nil {
+		klog.V(2).Infof("rejected pod %s/%s%s with identity %s: %s", pod.Namespace, pod.Name, pod.GenerateName, identityRef, rejected.Result.Message)
+		return rejected
+	}
+	if len(patch) == 0 {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return failure(http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+	}
+	klog.V(2).Infof("injecting identity %s into pod %s/%s%s", identityRef, pod.Namespace, pod.Name, pod.GenerateName)
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	return &admissionv1beta1.AdmissionResponse{Allowed: true, Patch: data, PatchType: &patchType}
+}
+
+// podPatch returns the JSON patch injecting the identity into the pod, or the response
+// rejecting the pod if the identity does not exist or none of its bindings can match the pod.
+func (s *Server) podPatch(pod *corev1.Pod, raw []byte, identityRef string) ([]patchOperation, *admissionv1beta1.AdmissionResponse) {
+	namespace, name := pod.Namespace, identityRef
+	if i := strings.Index(identityRef, "/"); i >= 0 {
+		namespace, name = identityRef[:i], identityRef[i+1:]
+	}
+	v1ID, err := s.clientSet.AadpodidentityV1().AzureIdentities(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, failure(http.StatusBadRequest, metav1.StatusReasonBadRequest,
+			fmt.Sprintf("AzureIdentity %s/%s of the %s annotation not found", namespace, name, aadpodid.IdentityAnnotationKey))
+	}
+	if err != nil {
+		return nil, failure(http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+	}
+	id := aadpodv1.ConvertV1IdentityToInternalIdentity(*v1ID)
+
+	selector, matched, err := s.findBindingSelector(pod, &id)
+	if err != nil {
+		return nil, failure(http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+	}
+	if !matched {
+		return nil, failure(http.StatusBadRequest, metav1.StatusReasonBadRequest,
+			fmt.Sprintf("no AzureIdentityBinding of AzureIdentity %s/%s can match the pod", namespace, name))
+	}
+
+	var patch []patchOperation
+	if selector != "" {
+		patch = append(patch, selectorPatch(pod, selector)...)
+	}
+	env := []corev1.EnvVar{{Name: azureClientIDEnv, Value: id.Spec.ClientID}}
+	if id.Spec.TenantID != "" {
+		env = append(env, corev1.EnvVar{Name: azureTenantIDEnv, Value: id.Spec.TenantID})
+	}
+	patch = append(patch, envPatch("/spec/initContainers", pod.Spec.InitContainers, env)...)
+	patch = append(patch, envPatch("/spec/containers", pod.Spec.Containers, env)...)
+	if s.injectReadinessGate {
+		gatePatch, err := readinessGatePatch(raw)
+		if err != nil {
+			return nil, failure(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+		}
+		patch = append(patch, gatePatch...)
+	}
+	return patch, nil
+}
+
+// findBindingSelector returns the selector to add to the pod so it is matched by a binding
+// of the identity, with the same matching as MIC. No selector is returned if the pod is
+// already matched, and matched is false if none of the bindings can match the pod.
+func (s *Server) findBindingSelector(pod *corev1.Pod, id *aadpodid.AzureIdentity) (selector string, matched bool, err error) {
+	list, err := s.clientSet.AadpodidentityV1().AzureIdentityBindings(id.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return "", false, err
+	}
+	var bindings []aadpodid.AzureIdentityBinding
+	for _, v1Binding := range list.Items {
+		binding := aadpodv1.ConvertV1BindingToInternalBinding(v1Binding)
+		if binding.Spec.AzureIdentity == id.Name && mic.IsIdentityAllowed(s.forceNamespaced, id, &binding, pod.Namespace) {
+			bindings = append(bindings, binding)
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Name < bindings[j].Name })
+
+	// the namespace is looked up at most once
+	var namespace *corev1.Namespace
+	getNamespace := func(name string) (*corev1.Namespace, error) {
+		if namespace != nil {
+			return namespace, nil
+		}
+		ns, err := s.kubeClient.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		namespace = ns
+		return ns, nil
+	}
+
+	// like MIC, the bindings which fail to be matched are ignored
+	matches := func(binding aadpodid.AzureIdentityBinding, pod *corev1.Pod) bool {
+		matched, err := mic.MatchBinding(binding, pod, getNamespace)
+		if err != nil {
+			klog.Errorf("failed to match pod %s/%s%s with binding %s/%s. Error: %v", pod.Namespace, pod.Name, pod.GenerateName, binding.Namespace, binding.Name, err)
+		}
+		return matched
+	}
+	for _, binding := range bindings {
+		if matches(binding, pod) {
+			return "", true, nil
+		}
+	}
+	for _, binding := range bindings {
+		if binding.Spec.Selector == "" {
+			continue
+		}
+		candidate := pod.DeepCopy()
+		addSelector(candidate, binding.Spec.Selector)
+		if matches(binding, candidate) {
+			return binding.Spec.Selector, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// addSelector adds the binding selector to the pod, as the aadpodidbinding label or in the
+// bindings annotation if the pod already has the label
+func addSelector(pod *corev1.Pod, selector string) {
+	if _, ok := pod.Labels[aadpodid.CRDLabelKey]; !ok {
+		if pod.Labels == nil {
+			pod.Labels = make(map[string]string)
+		}
+		pod.Labels[aadpodid.CRDLabelKey] = selector
+		return
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	if selectors := pod.Annotations[aadpodid.BindingsAnnotationKey]; selectors != "" {
+		selector = selectors + "," + selector
+	}
+	pod.Annotations[aadpodid.BindingsAnnotationKey] = selector
+}
+
+// selectorPatch returns the patch adding the binding selector to the pod, like addSelector
+func selectorPatch(pod *corev1.Pod, selector string) []patchOperation {
+	if _, ok := pod.Labels[aadpodid.CRDLabelKey]; !ok {
+		if pod.Labels == nil {
+			return []patchOperation{{Op: "add", Path: "/metadata/labels", Value: map[string]string{aadpodid.CRDLabelKey: selector}}}
+		}
+		return []patchOperation{{Op: "add", Path: "/metadata/labels/" + jsonPointerEscaper.Replace(aadpodid.CRDLabelKey), Value: selector}}
+	}
+	// the pod annotations exist, since the pod has the identity annotation
+	path := "/metadata/annotations/" + jsonPointerEscaper.Replace(aadpodid.BindingsAnnotationKey)
+	if selectors := pod.Annotations[aadpodid.BindingsAnnotationKey]; selectors != "" {
+		return []patchOperation{{Op: "replace", Path: path, Value: selectors + "," + selector}}
+	}
+	return []patchOperation{{Op: "add", Path: path, Value: selector}}
+}
+
+// envPatch returns the patch adding the environment variables to the containers, which keep
+// the variables they already set
+func envPatch(path string, containers []corev1.Container, env []corev1.EnvVar) []patchOperation {
+	var patch []patchOperation
+	for i, container := range containers {
+		existing := make(map[string]bool)
+		for _, e := range container.Env {
+			existing[e.Name] = true
+		}
+		var missing []corev1.EnvVar
+		for _, e := range env {
+			if !existing[e.Name] {
+				missing = append(missing, e)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		envPath := fmt.Sprintf("%s/%d/env", path, i)
+		if container.Env == nil {
+			patch = append(patch, patchOperation{Op: "add", Path: envPath, Value: missing})
+			continue
+		}
+		for _, e := range missing {
+			patch = append(patch, patchOperation{Op: "add", Path: envPath + "/-", Value: e})
+		}
+	}
+	return patch
+}
+
+// readinessGatePatch returns the patch adding the identity assigned readiness gate to the raw pod
+func readinessGatePatch(raw []byte) ([]patchOperation, error) {
+	gates := podReadinessGates{}
+	if err := json.Unmarshal(raw, &gates); err != nil {
+		return nil, err
+	}
+	for _, gate := range gates.Spec.ReadinessGates {
+		if gate.ConditionType == aadpodid.PodIdentityAssignedCondition {
+			return nil, nil
+		}
+	}
+	gate := map[string]string{"conditionType": aadpodid.PodIdentityAssignedCondition}
+	if len(gates.Spec.ReadinessGates) == 0 {
+		return []patchOperation{{Op: "add", Path: "/spec/readinessGates", Value: []map[string]string{gate}}}, nil
+	}
+	return []patchOperation{{Op: "add", Path: "/spec/readinessGates/-", Value: gate}}, nil
+}
+
+// failure returns the response rejecting the request with the status code, reason and message
+func failure(code int32, reason metav1.StatusReason, message string) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{Result: &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Reason:  reason,
+		Message: message,
+	}}
+}
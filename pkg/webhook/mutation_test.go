@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned/fake"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMutatePod(t *testing.T) {
+	id := &aadpodv1.AzureIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "id", Namespace: "default"},
+		Spec:       aadpodv1.AzureIdentitySpec{Type: aadpodv1.UserAssignedMSI, ResourceID: testResourceID, ClientID: testClientID, TenantID: "tenant"},
+	}
+	binding := &aadpodv1.AzureIdentityBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "binding", Namespace: "default"},
+		Spec:       aadpodv1.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select"},
+	}
+	// bindings are tried by name, app-binding only matches the pods with the app label
+	appBinding := &aadpodv1.AzureIdentityBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-binding", Namespace: "default"},
+		Spec: aadpodv1.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "app-select",
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+	}
+	clientSet := fake.NewSimpleClientset(id, binding, appBinding)
+	kubeClient := kubefake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+	)
+
+	newPod := func(identity string, labels map[string]string, env []corev1.EnvVar) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "pod-", Labels: labels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Env: env}}},
+		}
+		if identity != "" {
+			pod.Annotations = map[string]string{aadpodid.IdentityAnnotationKey: identity}
+		}
+		return pod
+	}
+	clientIDEnv := map[string]interface{}{"name": azureClientIDEnv, "value": testClientID}
+	tenantIDEnv := map[string]interface{}{"name": azureTenantIDEnv, "value": "tenant"}
+	gate := map[string]interface{}{"conditionType": aadpodid.PodIdentityAssignedCondition}
+
+	cases := []struct {
+		name                string
+		namespace           string
+		pod                 *corev1.Pod
+		readinessGates      []interface{}
+		forceNamespaced     bool
+		injectReadinessGate bool
+		allowed             bool
+		patch               []interface{}
+	}{
+		{
+			name:      "pod without identity annotation",
+			namespace: "default",
+			pod:       newPod("", nil, nil),
+			allowed:   true,
+		},
+		{
+			name:                "pod without labels",
+			namespace:           "default",
+			pod:                 newPod("id", nil, nil),
+			injectReadinessGate: true,
+			allowed:             true,
+			patch: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/metadata/labels", "value": map[string]interface{}{aadpodid.CRDLabelKey: "select"}},
+				map[string]interface{}{"op": "add", "path": "/spec/containers/0/env", "value": []interface{}{clientIDEnv, tenantIDEnv}},
+				map[string]interface{}{"op": "add", "path": "/spec/readinessGates", "value": []interface{}{gate}},
+			},
+		},
+		{
+			name:      "pod with another binding label and an environment variable",
+			namespace: "default",
+			pod: newPod("id", map[string]string{aadpodid.CRDLabelKey: "other", "app": "web"},
+				[]corev1.EnvVar{{Name: azureClientIDEnv, Value: "custom"}}),
+			allowed: true,
+			patch: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/metadata/annotations/aadpodidentity.k8s.io~1bindings", "value": "app-select"},
+				map[string]interface{}{"op": "add", "path": "/spec/containers/0/env/-", "value": tenantIDEnv},
+			},
+		},
+		{
+			name:                "pod matched by a binding with a readiness gate",
+			namespace:           "default",
+			pod:                 newPod("id", map[string]string{aadpodid.CRDLabelKey: "select"}, nil),
+			readinessGates:      []interface{}{map[string]interface{}{"conditionType": "other"}},
+			injectReadinessGate: true,
+			allowed:             true,
+			patch: []interface{}{
+				map[string]interface{}{"op": "add", "path": "/spec/containers/0/env", "value": []interface{}{clientIDEnv, tenantIDEnv}},
+				map[string]interface{}{"op": "add", "path": "/spec/readinessGates/-", "value": gate},
+			},
+		},
+		{
+			name:      "identity not found",
+			namespace: "default",
+			pod:       newPod("missing", nil, nil),
+		},
+		{
+			name:      "identity of another namespace",
+			namespace: "team",
+			pod:       newPod("default/id", map[string]string{aadpodid.CRDLabelKey: "select"}, []corev1.EnvVar{{Name: azureClientIDEnv}, {Name: azureTenantIDEnv}}),
+			allowed:   true,
+		},
+		{
+			name:            "identity of another namespace with forced namespaced identities",
+			namespace:       "team",
+			pod:             newPod("default/id", nil, nil),
+			forceNamespaced: true,
+		},
+	}
+
+	for i, tc := range cases {
+		server := NewServer(clientSet, kubeClient, tc.forceNamespaced, tc.injectReadinessGate)
+		raw := rawObject(t, tc.pod)
+		if tc.readinessGates != nil {
+			var obj map[string]interface{}
+			if err := json.Unmarshal(raw.Raw, &obj); err != nil {
+				t.Fatalf("expected nil error, got: %v", err)
+			}
+			obj["spec"].(map[string]interface{})["readinessGates"] = tc.readinessGates
+			raw = rawObject(t, obj)
+		}
+		resp := reviewPath(t, server, MutatePath, &admissionv1beta1.AdmissionRequest{
+			UID:       types.UID(tc.name),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: tc.namespace,
+			Operation: admissionv1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw.Raw},
+		})
+		if resp.Allowed != tc.allowed {
+			t.Errorf("case %d (%s): expected allowed %v, got: %+v", i, tc.name, tc.allowed, resp.Result)
+			continue
+		}
+		if !tc.allowed {
+			if resp.Result == nil || resp.Result.Reason != metav1.StatusReasonBadRequest {
+				t.Errorf("case %d (%s): expected the pod to be rejected as a bad request, got: %+v", i, tc.name, resp.Result)
+			}
+			continue
+		}
+		var patch []interface{}
+		if resp.Patch != nil {
+			if resp.PatchType == nil || *resp.PatchType != admissionv1beta1.PatchTypeJSONPatch {
+				t.Errorf("case %d (%s): expected a JSON patch, got: %v", i, tc.name, resp.PatchType)
+			}
+			if err := json.Unmarshal(resp.Patch, &patch); err != nil {
+				t.Fatalf("case %d (%s): expected nil error, got: %v", i, tc.name, err)
+			}
+		}
+		if !reflect.DeepEqual(patch, tc.patch) {
+			t.Errorf("case %d (%s): expected patch %v, got: %s", i, tc.name, tc.patch, resp.Patch)
+		}
+	}
+}
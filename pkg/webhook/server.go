@@ -0,0 +1,195 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+// ValidatePath is the path of the validating webhook
+const ValidatePath = "/validate"
+
+// Server validates the aadpodidentity resources created or updated through the API server,
+// and injects the identities named by their annotation into the pods being created
+type Server struct {
+	clientSet           versioned.Interface
+	kubeClient          kubernetes.Interface
+	forceNamespaced     bool
+	injectReadinessGate bool
+}
+
+// NewServer returns a new webhook server. The client set is used to check that the
+// identities of the bindings exist and to look up the identities and bindings of the
+// pods, and the kube client to look up the namespaces of the pods. injectReadinessGate
+// adds the identity assigned readiness gate to the pods an identity is injected into.
+func NewServer(clientSet versioned.Interface, kubeClient kubernetes.Interface, forceNamespaced, injectReadinessGate bool) *Server {
+	return &Server{
+		clientSet:           clientSet,
+		kubeClient:          kubeClient,
+		forceNamespaced:     forceNamespaced,
+		injectReadinessGate: injectReadinessGate,
+	}
+}
+
+// ListenAndServeTLS serves the webhook on the port with the certificate and key files.
+// It only returns on error.
+func (s *Server) ListenAndServeTLS(port, certFile, keyFile string) error {
+	mux := http.NewServeMux()
+	mux.Handle(ValidatePath, s)
+	mux.Handle(MutatePath, s)
+	klog.Infof("Starting webhook on port %s", port)
+	return http.ListenAndServeTLS(":"+port, certFile, keyFile, mux)
+}
+
+// ServeHTTP responds to the admission review of the request, which is validated or
+// mutated depending on the path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	review := admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("invalid admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Path == MutatePath {
+		review.Response = s.mutate(review.Request)
+	} else {
+		review.Response = s.review(review.Request)
+	}
+	review.Response.UID = review.Request.UID
+	resp, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// review validates the object of the admission request
+func (s *Server) review(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+
+	var allErrs field.ErrorList
+	var err error
+	kind := schema.GroupKind{Group: req.Kind.Group, Kind: req.Kind.Kind}
+	switch req.Kind.Kind {
+	case "AzureIdentity":
+		allErrs, err = s.validateAzureIdentity(req)
+	case "AzureIdentityBinding":
+		allErrs, err = s.validateAzureIdentityBinding(req)
+	case "AzurePodIdentityException":
+		allErrs, err = s.validateAzurePodIdentityException(req)
+	default:
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	}
+	if err != nil {
+		klog.Errorf("failed to decode %s %s/%s. Error: %v", req.Kind.Kind, req.Namespace, req.Name, err)
+		return failure(http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+	}
+	if len(allErrs) > 0 {
+		klog.V(2).Infof("rejected %s %s/%s: %v", req.Kind.Kind, req.Namespace, req.Name, allErrs.ToAggregate())
+		status := apierrors.NewInvalid(kind, req.Name, allErrs).Status()
+		return &admissionv1beta1.AdmissionResponse{Result: &status}
+	}
+	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+}
+
+func (s *Server) validateAzureIdentity(req *admissionv1beta1.AdmissionRequest) (field.ErrorList, error) {
+	id := aadpodv1.AzureIdentity{}
+	if err := json.Unmarshal(req.Object.Raw, &id); err != nil {
+		return nil, err
+	}
+	if req.Operation == admissionv1beta1.Update {
+		old := aadpodv1.AzureIdentity{}
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return nil, err
+		}
+		// identities created before the webhook can still be updated, as long as their spec is unchanged
+		if reflect.DeepEqual(old.Spec, id.Spec) && old.Annotations[aadpodid.BehaviorKey] == id.Annotations[aadpodid.BehaviorKey] {
+			return nil, nil
+		}
+	}
+	internalID := aadpodv1.ConvertV1IdentityToInternalIdentity(id)
+	return ValidateAzureIdentity(&internalID), nil
+}
+
+func (s *Server) validateAzureIdentityBinding(req *admissionv1beta1.AdmissionRequest) (field.ErrorList, error) {
+	binding := aadpodv1.AzureIdentityBinding{}
+	if err := json.Unmarshal(req.Object.Raw, &binding); err != nil {
+		return nil, err
+	}
+	identityExists := s.identityExists
+	if req.Operation == admissionv1beta1.Update {
+		old := aadpodv1.AzureIdentityBinding{}
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(old.Spec, binding.Spec) {
+			return nil, nil
+		}
+		// the identity can be deleted before its bindings, which can still be updated
+		if old.Spec.AzureIdentity == binding.Spec.AzureIdentity {
+			identityExists = nil
+		}
+	}
+	if binding.Namespace == "" {
+		binding.Namespace = req.Namespace
+	}
+	internalBinding := aadpodv1.ConvertV1BindingToInternalBinding(binding)
+	return ValidateAzureIdentityBinding(&internalBinding, s.forceNamespaced, identityExists), nil
+}
+
+func (s *Server) validateAzurePodIdentityException(req *admissionv1beta1.AdmissionRequest) (field.ErrorList, error) {
+	exception := aadpodv1.AzurePodIdentityException{}
+	if err := json.Unmarshal(req.Object.Raw, &exception); err != nil {
+		return nil, err
+	}
+	if req.Operation == admissionv1beta1.Update {
+		old := aadpodv1.AzurePodIdentityException{}
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return nil, err
+		}
+		if reflect.DeepEqual(old.Spec, exception.Spec) {
+			return nil, nil
+		}
+	}
+	internalException := aadpodv1.ConvertV1PodIdentityExceptionToInternalPodIdentityException(exception)
+	return ValidateAzurePodIdentityException(&internalException), nil
+}
+
+// identityExists returns true if the identity exists in the namespace
+func (s *Server) identityExists(namespace, name string) (bool, error) {
+	_, err := s.clientSet.AadpodidentityV1().AzureIdentities(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
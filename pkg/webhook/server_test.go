@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	aadpodv1 "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity/v1"
+	"github.com/Azure/aad-pod-identity/pkg/client/clientset/versioned/fake"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func reviewRequest(t *testing.T, server *Server, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	return reviewPath(t, server, ValidatePath, req)
+}
+
+func reviewPath(t *testing.T, server *Server, path string, req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	body, err := json.Marshal(admissionv1beta1.AdmissionReview{Request: req})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status code 200, got: %d", recorder.Code)
+	}
+	review := admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if review.Response == nil || review.Response.UID != req.UID {
+		t.Fatalf("expected a response for request %s, got: %+v", req.UID, review.Response)
+	}
+	return review.Response
+}
+
+func rawObject(t *testing.T, obj interface{}) runtime.RawExtension {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestServeHTTP(t *testing.T) {
+	id := &aadpodv1.AzureIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "id", Namespace: "default"},
+		Spec:       aadpodv1.AzureIdentitySpec{Type: aadpodv1.UserAssignedMSI, ResourceID: testResourceID, ClientID: testClientID},
+	}
+	server := NewServer(fake.NewSimpleClientset(id), kubefake.NewSimpleClientset(), false, false)
+
+	identityKind := metav1.GroupVersionKind{Group: "aadpodidentity.k8s.io", Version: "v1", Kind: "AzureIdentity"}
+	bindingKind := metav1.GroupVersionKind{Group: "aadpodidentity.k8s.io", Version: "v1", Kind: "AzureIdentityBinding"}
+
+	resp := reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("1"), Kind: identityKind, Operation: admissionv1beta1.Create, Object: rawObject(t, id)})
+	if !resp.Allowed {
+		t.Errorf("expected the valid identity to be allowed, got: %+v", resp.Result)
+	}
+
+	invalidID := id.DeepCopy()
+	invalidID.Spec.ResourceID = "invalid"
+	resp = reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("2"), Kind: identityKind, Operation: admissionv1beta1.Create, Object: rawObject(t, invalidID)})
+	if resp.Allowed || resp.Result == nil || resp.Result.Reason != metav1.StatusReasonInvalid {
+		t.Errorf("expected the identity with an invalid resource id to be rejected, got: %+v", resp)
+	}
+
+	// identities created before the webhook can be updated without changing their spec
+	updatedID := invalidID.DeepCopy()
+	updatedID.Labels = map[string]string{"team": "a"}
+	resp = reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("3"), Kind: identityKind, Operation: admissionv1beta1.Update,
+		Object: rawObject(t, updatedID), OldObject: rawObject(t, invalidID)})
+	if !resp.Allowed {
+		t.Errorf("expected the update of the identity labels to be allowed, got: %+v", resp.Result)
+	}
+
+	binding := &aadpodv1.AzureIdentityBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "binding", Namespace: "default"},
+		Spec:       aadpodv1.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select"},
+	}
+	resp = reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("4"), Kind: bindingKind, Namespace: "default", Operation: admissionv1beta1.Create, Object: rawObject(t, binding)})
+	if !resp.Allowed {
+		t.Errorf("expected the binding of an existing identity to be allowed, got: %+v", resp.Result)
+	}
+
+	missingBinding := binding.DeepCopy()
+	missingBinding.Spec.AzureIdentity = "missing"
+	resp = reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("5"), Kind: bindingKind, Namespace: "default", Operation: admissionv1beta1.Create, Object: rawObject(t, missingBinding)})
+	if resp.Allowed {
+		t.Errorf("expected the binding of a missing identity to be rejected")
+	}
+
+	// the identity of a binding can be deleted before the binding is updated
+	updatedBinding := missingBinding.DeepCopy()
+	updatedBinding.Spec.Selector = "other"
+	resp = reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("6"), Kind: bindingKind, Namespace: "default", Operation: admissionv1beta1.Update,
+		Object: rawObject(t, updatedBinding), OldObject: rawObject(t, missingBinding)})
+	if !resp.Allowed {
+		t.Errorf("expected the update of a binding keeping its missing identity to be allowed, got: %+v", resp.Result)
+	}
+
+	resp = reviewRequest(t, server, &admissionv1beta1.AdmissionRequest{UID: types.UID("7"), Kind: identityKind, Operation: admissionv1beta1.Delete})
+	if !resp.Allowed {
+		t.Errorf("expected deletes to be allowed, got: %+v", resp.Result)
+	}
+}
@@ -0,0 +1,158 @@
+package webhook
+
+import (
+	"regexp"
+	"strings"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+	"github.com/Azure/aad-pod-identity/pkg/cloudprovider"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// managedIdentityProvider is the provider of the user assigned identities
+	managedIdentityProvider = "Microsoft.ManagedIdentity"
+	// userAssignedIdentitiesType is the resource type of the user assigned identities
+	userAssignedIdentitiesType = "userAssignedIdentities"
+)
+
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidateAzureIdentity validates the identity. The fields required depend on the
+// type of the identity.
+func ValidateAzureIdentity(id *aadpodid.AzureIdentity) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if behavior, ok := id.Annotations[aadpodid.BehaviorKey]; ok && behavior != aadpodid.BehaviorNamespaced {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metadata", "annotations").Key(aadpodid.BehaviorKey), behavior, []string{aadpodid.BehaviorNamespaced}))
+	}
+
+	switch id.Spec.Type {
+	case aadpodid.UserAssignedMSI:
+		allErrs = append(allErrs, validateResourceID(id.Spec.ResourceID, specPath.Child("resourceid"))...)
+		allErrs = append(allErrs, validateClientID(id.Spec.ClientID, specPath.Child("clientid"))...)
+	case aadpodid.ServicePrincipal:
+		allErrs = append(allErrs, validateClientID(id.Spec.ClientID, specPath.Child("clientid"))...)
+		allErrs = append(allErrs, validateTenantID(id.Spec.TenantID, specPath.Child("tenantid"))...)
+		if id.Spec.ClientPassword.Name == "" && id.Spec.ClientCertificate.Name == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("clientpassword"), "a client password or a client certificate is required for a service principal"))
+		}
+		if id.Spec.ClientCertificatePassword.Name != "" && id.Spec.ClientCertificate.Name == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("clientcertificate"), "a client certificate is required with a client certificate password"))
+		}
+	case aadpodid.FederatedIdentity:
+		allErrs = append(allErrs, validateClientID(id.Spec.ClientID, specPath.Child("clientid"))...)
+		allErrs = append(allErrs, validateTenantID(id.Spec.TenantID, specPath.Child("tenantid"))...)
+	default:
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("type"), id.Spec.Type, []string{"0", "1", "2"}))
+	}
+	return allErrs
+}
+
+// ValidateAzureIdentityBinding validates the binding. identityExists returns true if
+// the identity of the binding exists in the namespace of the binding, and is only
+// called if the binding has an identity. Namespace selectors are not allowed when
+// forceNamespaced is set, since the pods of a binding are in its namespace.
+func ValidateAzureIdentityBinding(binding *aadpodid.AzureIdentityBinding, forceNamespaced bool, identityExists func(namespace, name string) (bool, error)) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	identityPath := specPath.Child("azureidentity")
+	if binding.Spec.AzureIdentity == "" {
+		allErrs = append(allErrs, field.Required(identityPath, ""))
+	} else if identityExists != nil {
+		exists, err := identityExists(binding.Namespace, binding.Spec.AzureIdentity)
+		if err != nil {
+			allErrs = append(allErrs, field.InternalError(identityPath, err))
+		} else if !exists {
+			allErrs = append(allErrs, field.NotFound(identityPath, binding.Spec.AzureIdentity))
+		}
+	}
+
+	if binding.Spec.Selector != "" {
+		for _, msg := range validation.IsValidLabelValue(binding.Spec.Selector) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("selector"), binding.Spec.Selector, msg))
+		}
+	}
+	if binding.Spec.LabelSelector != nil {
+		labelSelectorPath := specPath.Child("labelselector")
+		// an empty label selector would select all the pods, it is ignored by mic
+		if len(binding.Spec.LabelSelector.MatchLabels) == 0 && len(binding.Spec.LabelSelector.MatchExpressions) == 0 {
+			allErrs = append(allErrs, field.Invalid(labelSelectorPath, metav1.FormatLabelSelector(binding.Spec.LabelSelector), "must have matchLabels or matchExpressions"))
+		} else {
+			allErrs = append(allErrs, validateLabelSelector(binding.Spec.LabelSelector, labelSelectorPath)...)
+		}
+	}
+	if binding.Spec.NamespaceSelector != nil {
+		if forceNamespaced {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("namespaceselector"), "namespace selectors are not allowed when namespaced identities are enforced"))
+		} else {
+			allErrs = append(allErrs, validateLabelSelector(binding.Spec.NamespaceSelector, specPath.Child("namespaceselector"))...)
+		}
+	}
+	return allErrs
+}
+
+// ValidateAzurePodIdentityException validates the pod identity exception, whose pod
+// labels have to be valid labels.
+func ValidateAzurePodIdentityException(exception *aadpodid.AzurePodIdentityException) field.ErrorList {
+	var allErrs field.ErrorList
+	podLabelsPath := field.NewPath("spec", "podLabels")
+
+	if len(exception.Spec.PodLabels) == 0 {
+		allErrs = append(allErrs, field.Required(podLabelsPath, "at least one pod label is required"))
+	}
+	for key, value := range exception.Spec.PodLabels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(podLabelsPath, key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(podLabelsPath.Key(key), value, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateResourceID validates the resource id of a user assigned identity
+func validateResourceID(resourceID string, fldPath *field.Path) field.ErrorList {
+	if resourceID == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	r, err := cloudprovider.ParseResourceID(resourceID)
+	if err != nil {
+		return field.ErrorList{field.Invalid(fldPath, resourceID,
+			"must be of the format /subscriptions/<subscription>/resourcegroups/<resource group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<name>")}
+	}
+	if !strings.EqualFold(r.Provider, managedIdentityProvider) || !strings.EqualFold(r.ResourceType, userAssignedIdentitiesType) {
+		return field.ErrorList{field.Invalid(fldPath, resourceID, "must be the resource id of a user assigned identity")}
+	}
+	return nil
+}
+
+func validateClientID(clientID string, fldPath *field.Path) field.ErrorList {
+	if clientID == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	if !guidPattern.MatchString(clientID) {
+		return field.ErrorList{field.Invalid(fldPath, clientID, "must be a GUID")}
+	}
+	return nil
+}
+
+func validateTenantID(tenantID string, fldPath *field.Path) field.ErrorList {
+	if tenantID == "" {
+		return field.ErrorList{field.Required(fldPath, "")}
+	}
+	return nil
+}
+
+func validateLabelSelector(selector *metav1.LabelSelector, fldPath *field.Path) field.ErrorList {
+	if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, metav1.FormatLabelSelector(selector), err.Error())}
+	}
+	return nil
+}
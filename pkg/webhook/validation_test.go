@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"testing"
+
+	aadpodid "github.com/Azure/aad-pod-identity/pkg/apis/aadpodidentity"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	testResourceID = "/subscriptions/00000000-0000-0000-0000-000000000000/resourcegroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/id"
+	testClientID   = "11111111-1111-1111-1111-111111111111"
+)
+
+func TestValidateAzureIdentity(t *testing.T) {
+	cases := []struct {
+		name   string
+		id     aadpodid.AzureIdentity
+		fields []string
+	}{
+		{
+			name: "valid user assigned identity",
+			id:   aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI, ResourceID: testResourceID, ClientID: testClientID}},
+		},
+		{
+			name: "user assigned identity resource id in another case",
+			id: aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI, ClientID: testClientID,
+				ResourceID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/microsoft.managedidentity/userassignedidentities/id"}},
+		},
+		{
+			name:   "user assigned identity without resource id and client id",
+			id:     aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI}},
+			fields: []string{"spec.resourceid", "spec.clientid"},
+		},
+		{
+			name:   "malformed resource id",
+			id:     aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI, ResourceID: "/subscriptions/sub/identity", ClientID: testClientID}},
+			fields: []string{"spec.resourceid"},
+		},
+		{
+			name: "resource id of a virtual machine",
+			id: aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI, ClientID: testClientID,
+				ResourceID: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm"}},
+			fields: []string{"spec.resourceid"},
+		},
+		{
+			name:   "client id not a guid",
+			id:     aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI, ResourceID: testResourceID, ClientID: "client-id"}},
+			fields: []string{"spec.clientid"},
+		},
+		{
+			name: "valid service principal",
+			id: aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.ServicePrincipal, ClientID: testClientID, TenantID: "tenant",
+				ClientPassword: api.SecretReference{Name: "secret", Namespace: "default"}}},
+		},
+		{
+			name:   "service principal without credentials",
+			id:     aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.ServicePrincipal, ClientID: testClientID}},
+			fields: []string{"spec.tenantid", "spec.clientpassword"},
+		},
+		{
+			name: "service principal with a certificate password without certificate",
+			id: aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.ServicePrincipal, ClientID: testClientID, TenantID: "tenant",
+				ClientPassword:            api.SecretReference{Name: "secret", Namespace: "default"},
+				ClientCertificatePassword: api.SecretReference{Name: "password", Namespace: "default"}}},
+			fields: []string{"spec.clientcertificate"},
+		},
+		{
+			name: "valid federated identity",
+			id:   aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: aadpodid.FederatedIdentity, ClientID: testClientID, TenantID: "tenant"}},
+		},
+		{
+			name:   "unknown type",
+			id:     aadpodid.AzureIdentity{Spec: aadpodid.AzureIdentitySpec{Type: 3}},
+			fields: []string{"spec.type"},
+		},
+		{
+			name: "unknown behavior",
+			id: aadpodid.AzureIdentity{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{aadpodid.BehaviorKey: "namespace"}},
+				Spec:       aadpodid.AzureIdentitySpec{Type: aadpodid.UserAssignedMSI, ResourceID: testResourceID, ClientID: testClientID},
+			},
+			fields: []string{"metadata.annotations[aadpodidentity.k8s.io/Behavior]"},
+		},
+	}
+
+	for _, tc := range cases {
+		checkErrorFields(t, tc.name, ValidateAzureIdentity(&tc.id), tc.fields)
+	}
+}
+
+func TestValidateAzureIdentityBinding(t *testing.T) {
+	identityExists := func(namespace, name string) (bool, error) {
+		return namespace == "default" && name == "id", nil
+	}
+	cases := []struct {
+		name            string
+		binding         aadpodid.AzureIdentityBinding
+		forceNamespaced bool
+		fields          []string
+	}{
+		{
+			name:    "valid binding",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select"}},
+		},
+		{
+			name:    "binding without identity",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{Selector: "select"}},
+			fields:  []string{"spec.azureidentity"},
+		},
+		{
+			name:    "identity in another namespace",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "other"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select"}},
+			fields:  []string{"spec.azureidentity"},
+		},
+		{
+			name:    "invalid selector",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select me"}},
+			fields:  []string{"spec.selector"},
+		},
+		{
+			name: "invalid label selector",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id",
+				LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Like"}}}}},
+			fields: []string{"spec.labelselector"},
+		},
+		{
+			name: "empty label selector",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id",
+				LabelSelector: &metav1.LabelSelector{}}},
+			fields: []string{"spec.labelselector"},
+		},
+		{
+			name: "namespace selector",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select",
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}}},
+		},
+		{
+			name: "namespace selector with namespaced identities enforced",
+			binding: aadpodid.AzureIdentityBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: aadpodid.AzureIdentityBindingSpec{AzureIdentity: "id", Selector: "select",
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}}},
+			forceNamespaced: true,
+			fields:          []string{"spec.namespaceselector"},
+		},
+	}
+
+	for _, tc := range cases {
+		checkErrorFields(t, tc.name, ValidateAzureIdentityBinding(&tc.binding, tc.forceNamespaced, identityExists), tc.fields)
+	}
+}
+
+func TestValidateAzurePodIdentityException(t *testing.T) {
+	cases := []struct {
+		name      string
+		exception aadpodid.AzurePodIdentityException
+		fields    []string
+	}{
+		{
+			name:      "valid exception",
+			exception: aadpodid.AzurePodIdentityException{Spec: aadpodid.AzurePodIdentityExceptionSpec{PodLabels: map[string]string{"app": "custom"}}},
+		},
+		{
+			name:   "exception without pod labels",
+			fields: []string{"spec.podLabels"},
+		},
+		{
+			name:      "invalid pod label",
+			exception: aadpodid.AzurePodIdentityException{Spec: aadpodid.AzurePodIdentityExceptionSpec{PodLabels: map[string]string{"app": "custom app"}}},
+			fields:    []string{"spec.podLabels[app]"},
+		},
+	}
+
+	for _, tc := range cases {
+		checkErrorFields(t, tc.name, ValidateAzurePodIdentityException(&tc.exception), tc.fields)
+	}
+}
+
+func checkErrorFields(t *testing.T, name string, allErrs field.ErrorList, fields []string) {
+	if len(allErrs) != len(fields) {
+		t.Errorf("%s: expected errors for %v, got: %v", name, fields, allErrs)
+		return
+	}
+	for i, err := range allErrs {
+		if err.Field != fields[i] {
+			t.Errorf("%s: expected error for %s, got: %v", name, fields[i], err)
+		}
+	}
+}
@@ -15,7 +15,7 @@ import (
 
 // GetAll will return a list of AzureAssignedIdentity deployed on a Kubernetes cluster
 func GetAll() (*aadpodid.AzureAssignedIdentityList, error) {
-	cmd := exec.Command("kubectl", "get", "AzureAssignedIdentity", "-ojson")
+	cmd := exec.Command("kubectl", "get", "AzureAssignedIdentity", "--all-namespaces", "-ojson")
 	util.PrintCommand(cmd)
 	out, err := cmd.CombinedOutput()
 	if err != nil {